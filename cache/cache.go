@@ -0,0 +1,368 @@
+// Package cache stores Cochl Sense inference results keyed by the content of
+// the analyzed audio, so repeated analyses of the same file can skip the
+// upload and inference round trip. Entries live in an in-memory LRU and can
+// optionally be persisted to a directory on disk.
+package cache
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cochlearai/cochl-mcp-server/client"
+)
+
+// Key identifies a cached analysis. It is the hex SHA-256 digest of the audio
+// content and the analysis options.
+type Key string
+
+// NewKey derives a Key from audio data and the options that affect its
+// analysis. options must be JSON-serializable.
+func NewKey(data []byte, options any) (Key, error) {
+	opts, err := json.Marshal(options)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal cache options: %w", err)
+	}
+
+	h := sha256.New()
+	h.Write(data)
+	h.Write([]byte{0})
+	h.Write(opts)
+	return Key(hex.EncodeToString(h.Sum(nil))), nil
+}
+
+// Entry is a cached analysis.
+type Entry struct {
+	Results   []client.InferenceResult `json:"results"`
+	CreatedAt time.Time                `json:"created_at"`
+}
+
+type Option func(*Cache)
+
+// WithMaxEntries bounds the number of entries kept in memory. The least
+// recently used entry is evicted first.
+func WithMaxEntries(n int) Option {
+	return func(c *Cache) {
+		c.maxEntries = n
+	}
+}
+
+// WithTTL sets how long an entry stays valid after it is stored. Zero means
+// entries never expire.
+func WithTTL(ttl time.Duration) Option {
+	return func(c *Cache) {
+		c.ttl = ttl
+	}
+}
+
+// WithDiskDir persists entries as JSON files in dir, so they survive
+// restarts. maxBytes bounds the total size of the files; the least recently
+// used are removed first. Zero means no size limit.
+func WithDiskDir(dir string, maxBytes int64) Option {
+	return func(c *Cache) {
+		c.dir = dir
+		c.maxDiskBytes = maxBytes
+	}
+}
+
+// DefaultDir returns the directory used for the on-disk store when none is
+// configured.
+func DefaultDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "cochl-mcp-server", "results"), nil
+}
+
+type Cache struct {
+	mu           sync.Mutex
+	ll           *list.List
+	items        map[Key]*list.Element
+	maxEntries   int
+	ttl          time.Duration
+	dir          string
+	maxDiskBytes int64
+	now          func() time.Time
+
+	// files indexes the entries on disk, so the size limit is enforced
+	// without listing the directory. diskBytes is the total of their sizes.
+	files     map[Key]*diskFile
+	diskBytes int64
+}
+
+// diskFile is an entry on disk.
+type diskFile struct {
+	size int64
+	// used is when the entry was last stored or read.
+	used time.Time
+}
+
+type item struct {
+	key   Key
+	entry Entry
+}
+
+func New(opts ...Option) (*Cache, error) {
+	c := &Cache{
+		ll:         list.New(),
+		items:      make(map[Key]*list.Element),
+		maxEntries: 100,
+		now:        time.Now,
+		files:      make(map[Key]*diskFile),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.dir != "" {
+		if err := os.MkdirAll(c.dir, 0o700); err != nil {
+			return nil, fmt.Errorf("failed to create cache directory: %w", err)
+		}
+		if err := c.loadIndex(); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// loadIndex indexes the entries already on disk, as last used when they
+// were written.
+func (c *Cache) loadIndex() error {
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return fmt.Errorf("failed to list cache directory: %w", err)
+	}
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), ".json")
+		if e.IsDir() || !ok {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		c.files[Key(name)] = &diskFile{size: info.Size(), used: info.ModTime()}
+		c.diskBytes += info.Size()
+	}
+	return nil
+}
+
+// Get returns the entry stored for key, looking on disk when it is not in
+// memory. Expired entries are removed and reported as missing.
+func (c *Cache) Get(key Key) (*Entry, bool) {
+	c.mu.Lock()
+	if el, ok := c.items[key]; ok {
+		it := el.Value.(*item)
+		if c.expired(it.entry) {
+			c.removeElement(el)
+			c.unindex(key)
+			c.mu.Unlock()
+			c.removeFile(key)
+			return nil, false
+		}
+		c.ll.MoveToFront(el)
+		c.touch(key)
+		entry := it.entry
+		c.mu.Unlock()
+		return &entry, true
+	}
+	_, onDisk := c.files[key]
+	c.mu.Unlock()
+	if !onDisk {
+		return nil, false
+	}
+
+	entry, ok := c.readFile(key)
+	if !ok {
+		return nil, false
+	}
+	if c.expired(*entry) {
+		c.forget(key)
+		return nil, false
+	}
+
+	c.mu.Lock()
+	c.add(key, *entry)
+	c.touch(key)
+	c.mu.Unlock()
+	return entry, true
+}
+
+// Put stores results for key, replacing any existing entry. The file of the
+// entry is written, and older ones removed, without holding the lock.
+func (c *Cache) Put(key Key, results []client.InferenceResult) {
+	entry := Entry{Results: results, CreatedAt: c.now()}
+	c.mu.Lock()
+	c.add(key, entry)
+	c.mu.Unlock()
+
+	size, ok := c.writeFile(key, entry)
+	if !ok {
+		return
+	}
+
+	c.mu.Lock()
+	c.unindex(key)
+	c.files[key] = &diskFile{size: size, used: c.now()}
+	c.diskBytes += size
+	evicted := c.evict()
+	c.mu.Unlock()
+
+	for _, k := range evicted {
+		c.removeFile(k)
+	}
+}
+
+func (c *Cache) add(key Key, entry Entry) {
+	if c.maxEntries <= 0 {
+		return
+	}
+
+	if el, ok := c.items[key]; ok {
+		el.Value.(*item).entry = entry
+		c.ll.MoveToFront(el)
+		return
+	}
+
+	c.items[key] = c.ll.PushFront(&item{key: key, entry: entry})
+	for c.ll.Len() > c.maxEntries {
+		c.removeElement(c.ll.Back())
+	}
+}
+
+func (c *Cache) removeElement(el *list.Element) {
+	c.ll.Remove(el)
+	delete(c.items, el.Value.(*item).key)
+}
+
+func (c *Cache) expired(entry Entry) bool {
+	return c.ttl > 0 && c.now().Sub(entry.CreatedAt) > c.ttl
+}
+
+func (c *Cache) path(key Key) string {
+	return filepath.Join(c.dir, string(key)+".json")
+}
+
+func (c *Cache) readFile(key Key) (*Entry, bool) {
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			// Evicted since it was looked up.
+			c.forget(key)
+			return nil, false
+		}
+		slog.Warn("Failed to read cache file", "key", key, "error", err)
+		return nil, false
+	}
+
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		slog.Warn("Removing corrupt cache file", "key", key, "error", err)
+		c.forget(key)
+		return nil, false
+	}
+	return &entry, true
+}
+
+// writeFile stores entry on disk and returns the size of its file.
+func (c *Cache) writeFile(key Key, entry Entry) (int64, bool) {
+	if c.dir == "" {
+		return 0, false
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		slog.Warn("Failed to marshal cache entry", "key", key, "error", err)
+		return 0, false
+	}
+
+	// Write to a temporary file first so readers never see a partial entry.
+	tmp, err := os.CreateTemp(c.dir, string(key)+"-*.tmp")
+	if err != nil {
+		slog.Warn("Failed to write cache file", "key", key, "error", err)
+		return 0, false
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), c.path(key))
+	}
+	if err != nil {
+		slog.Warn("Failed to write cache file", "key", key, "error", err)
+		os.Remove(tmp.Name())
+		return 0, false
+	}
+	return int64(len(data)), true
+}
+
+// forget removes the entry of key from disk.
+func (c *Cache) forget(key Key) {
+	c.mu.Lock()
+	c.unindex(key)
+	c.mu.Unlock()
+	c.removeFile(key)
+}
+
+func (c *Cache) removeFile(key Key) {
+	if c.dir == "" {
+		return
+	}
+	if err := os.Remove(c.path(key)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		slog.Warn("Failed to remove cache file", "key", key, "error", err)
+	}
+}
+
+// touch marks the file of key as used. c.mu must be held.
+func (c *Cache) touch(key Key) {
+	if f, ok := c.files[key]; ok {
+		f.used = c.now()
+	}
+}
+
+// unindex drops the file of key from the index. c.mu must be held.
+func (c *Cache) unindex(key Key) {
+	if f, ok := c.files[key]; ok {
+		c.diskBytes -= f.size
+		delete(c.files, key)
+	}
+}
+
+// evict drops the least recently used files from the index until they fit
+// in maxDiskBytes, and returns their keys for removal. c.mu must be held.
+func (c *Cache) evict() []Key {
+	if c.maxDiskBytes <= 0 || c.diskBytes <= c.maxDiskBytes {
+		return nil
+	}
+
+	keys := make([]Key, 0, len(c.files))
+	for k := range c.files {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return c.files[keys[i]].used.Before(c.files[keys[j]].used)
+	})
+
+	var evicted []Key
+	for _, k := range keys {
+		if c.diskBytes <= c.maxDiskBytes {
+			break
+		}
+		c.unindex(k)
+		evicted = append(evicted, k)
+	}
+	return evicted
+}
@@ -0,0 +1,170 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cochlearai/cochl-mcp-server/client"
+)
+
+func testResults(name string) []client.InferenceResult {
+	return []client.InferenceResult{
+		{StartTime: 0, EndTime: 1000, Tags: []client.Tags{{Name: name, Probability: 0.9}}},
+	}
+}
+
+func mustKey(t *testing.T, data string, options any) Key {
+	t.Helper()
+	k, err := NewKey([]byte(data), options)
+	if err != nil {
+		t.Fatalf("failed to create key: %v", err)
+	}
+	return k
+}
+
+func TestNewKey(t *testing.T) {
+	a := mustKey(t, "audio", map[string]string{"format": "wav"})
+	if b := mustKey(t, "audio", map[string]string{"format": "wav"}); a != b {
+		t.Error("expected identical input to produce identical keys")
+	}
+	if b := mustKey(t, "other audio", map[string]string{"format": "wav"}); a == b {
+		t.Error("expected different audio to produce different keys")
+	}
+	if b := mustKey(t, "audio", map[string]string{"format": "mp3"}); a == b {
+		t.Error("expected different options to produce different keys")
+	}
+	if len(a) != 64 {
+		t.Errorf("expected hex SHA-256 key, got %q", a)
+	}
+}
+
+func TestLRUEviction(t *testing.T) {
+	c, err := New(WithMaxEntries(2))
+	if err != nil {
+		t.Fatalf("failed to create cache: %v", err)
+	}
+
+	k1, k2, k3 := mustKey(t, "1", nil), mustKey(t, "2", nil), mustKey(t, "3", nil)
+	c.Put(k1, testResults("Speech"))
+	c.Put(k2, testResults("Music"))
+
+	// Touch k1 so k2 becomes the least recently used entry.
+	if _, ok := c.Get(k1); !ok {
+		t.Fatal("expected k1 to be cached")
+	}
+	c.Put(k3, testResults("Siren"))
+
+	if _, ok := c.Get(k2); ok {
+		t.Error("expected k2 to be evicted")
+	}
+	for _, k := range []Key{k1, k3} {
+		if _, ok := c.Get(k); !ok {
+			t.Errorf("expected %s to be cached", k)
+		}
+	}
+}
+
+func TestTTL(t *testing.T) {
+	dir := t.TempDir()
+	c, err := New(WithTTL(time.Hour), WithDiskDir(dir, 0))
+	if err != nil {
+		t.Fatalf("failed to create cache: %v", err)
+	}
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+
+	k := mustKey(t, "audio", nil)
+	c.Put(k, testResults("Speech"))
+
+	now = now.Add(59 * time.Minute)
+	entry, ok := c.Get(k)
+	if !ok {
+		t.Fatal("expected entry before TTL")
+	}
+	if !reflect.DeepEqual(entry.Results, testResults("Speech")) {
+		t.Errorf("unexpected results: %+v", entry.Results)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, ok := c.Get(k); ok {
+		t.Error("expected entry to expire")
+	}
+	if _, err := os.Stat(filepath.Join(dir, string(k)+".json")); !os.IsNotExist(err) {
+		t.Errorf("expected expired cache file to be removed, got %v", err)
+	}
+}
+
+func TestDiskPersistence(t *testing.T) {
+	dir := t.TempDir()
+	k := mustKey(t, "audio", nil)
+
+	first, err := New(WithDiskDir(dir, 0))
+	if err != nil {
+		t.Fatalf("failed to create cache: %v", err)
+	}
+	first.Put(k, testResults("Dog_bark"))
+
+	// A fresh cache on the same directory simulates a restart.
+	second, err := New(WithDiskDir(dir, 0))
+	if err != nil {
+		t.Fatalf("failed to create cache: %v", err)
+	}
+	entry, ok := second.Get(k)
+	if !ok {
+		t.Fatal("expected entry to be loaded from disk")
+	}
+	if !reflect.DeepEqual(entry.Results, testResults("Dog_bark")) {
+		t.Errorf("unexpected results: %+v", entry.Results)
+	}
+}
+
+func TestDiskSizeLimit(t *testing.T) {
+	dir := t.TempDir()
+	// Each entry takes 137 bytes, so two fit.
+	c, err := New(WithMaxEntries(0), WithDiskDir(dir, 300))
+	if err != nil {
+		t.Fatalf("failed to create cache: %v", err)
+	}
+	// Each use is a minute after the last, so eviction order is
+	// deterministic.
+	now := time.Now()
+	c.now = func() time.Time {
+		now = now.Add(time.Minute)
+		return now
+	}
+
+	keys := []Key{mustKey(t, "1", nil), mustKey(t, "2", nil), mustKey(t, "3", nil)}
+	for _, k := range keys[:2] {
+		c.Put(k, testResults("Speech"))
+	}
+	// Reading the first entry makes the second the least recently used.
+	if _, ok := c.Get(keys[0]); !ok {
+		t.Fatal("expected the first entry on disk")
+	}
+	c.Put(keys[2], testResults("Speech"))
+
+	if _, ok := c.Get(keys[1]); ok {
+		t.Error("expected least recently used entry to be removed from disk")
+	}
+	if _, err := os.Stat(filepath.Join(dir, string(keys[1])+".json")); !os.IsNotExist(err) {
+		t.Errorf("expected the file of the evicted entry to be removed, got %v", err)
+	}
+	for _, k := range []Key{keys[0], keys[2]} {
+		if _, ok := c.Get(k); !ok {
+			t.Errorf("expected entry %s to be kept on disk", k)
+		}
+	}
+
+	// A restarted cache indexes the files already on disk.
+	reopened, err := New(WithMaxEntries(0), WithDiskDir(dir, 300))
+	if err != nil {
+		t.Fatalf("failed to create cache: %v", err)
+	}
+	if reopened.diskBytes != c.diskBytes || len(reopened.files) != 2 {
+		t.Errorf("got %d files of %d bytes after reopening, want %d files of %d bytes",
+			len(reopened.files), reopened.diskBytes, len(c.files), c.diskBytes)
+	}
+}
@@ -1,11 +1,21 @@
 package client
 
 import (
+	"context"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"resty.dev/v3"
 
+	"github.com/cochlearai/cochl-mcp-server/metrics"
 	"github.com/cochlearai/cochl-mcp-server/util/restcli"
 )
 
@@ -35,8 +45,64 @@ type Tags struct {
 type RespInferenceResult struct {
 	Data  []InferenceResult `json:"data"`
 	State string            `json:"state"`
+	// Page is set when the API returns results incrementally, one page at a
+	// time. Data then holds only the segments of this page.
+	Page *ResultPage `json:"page,omitempty"`
+	// RetryAfter is how long the API asked to wait before polling again,
+	// from the Retry-After header of a pending response, or zero.
+	RetryAfter time.Duration `json:"-"`
 }
 
+// ResultPage locates a page of results among the Total segments available so
+// far. NextToken requests the segments that follow it.
+type ResultPage struct {
+	Offset    int    `json:"offset"`
+	Count     int    `json:"count"`
+	Total     int    `json:"total"`
+	NextToken string `json:"next_token,omitempty"`
+}
+
+// HasMore reports whether more segments are available right away.
+func (p *ResultPage) HasMore() bool {
+	return p.Offset+p.Count < p.Total
+}
+
+// CochlSense is the set of Cochl Sense audio session operations used by the
+// tools. CochlSenseClient is the production implementation.
+type CochlSense interface {
+	CreateSession(ctx context.Context, fileName, contentType string, duration float64, fileSize int) (*RespCreateSession, error)
+	CreateStreamSession(ctx context.Context, contentType string) (*RespCreateSession, error)
+	UploadChunk(ctx context.Context, sessionID string, chunkSequence int, chunk []byte) (*RespUploadChunk, error)
+	GetInferenceResult(ctx context.Context, sessionID, nextToken string) (*RespInferenceResult, error)
+	CloseStream(ctx context.Context, sessionID string) error
+	DeleteSession(ctx context.Context, sessionID string) error
+	CheckConnection(ctx context.Context) error
+}
+
+var (
+	// ErrNoProjectKey means CheckConnection was called without a project
+	// key.
+	ErrNoProjectKey = errors.New("no project key is set")
+	// ErrInvalidProjectKey means the API rejected the project key.
+	ErrInvalidProjectKey = errors.New("the Cochl Sense API rejected the project key")
+)
+
+// APIError is an unexpected response of the API to CheckConnection.
+type APIError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("the Cochl Sense API answered with status %d: %s", e.StatusCode, e.Body)
+}
+
+// checkSessionID names a session that does not exist. Requesting its results
+// is the lightest request that is authenticated.
+const checkSessionID = "connection-check"
+
+var _ CochlSense = (*CochlSenseClient)(nil)
+
 type CochlSenseClient struct {
 	Client *resty.Client
 }
@@ -54,7 +120,23 @@ func NewCochlSense(key string, baseUrl, version string) *CochlSenseClient {
 	}
 }
 
-func (c *CochlSenseClient) CreateSession(fileName, contentType string, duration float64, fileSize int) (*RespCreateSession, error) {
+// BaseURL returns the API base URL the client sends requests to.
+func (c *CochlSenseClient) BaseURL() string {
+	return c.Client.BaseURL()
+}
+
+// KeyID identifies the project key of the client without revealing it, or
+// is empty if no key is set.
+func (c *CochlSenseClient) KeyID() string {
+	key := c.Client.Header().Get("X-Api-Key")
+	if key == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:8])
+}
+
+func (c *CochlSenseClient) CreateSession(ctx context.Context, fileName, contentType string, duration float64, fileSize int) (*RespCreateSession, error) {
 	param := restcli.Params{
 		Body: map[string]any{
 			"type":         "file",
@@ -66,7 +148,8 @@ func (c *CochlSenseClient) CreateSession(fileName, contentType string, duration
 	}
 
 	var result RespCreateSession
-	res, err := restcli.Post(c.Client, "/audio_sessions/", &param, &result)
+	res, err := restcli.Post(ctx, c.Client, "/audio_sessions/", &param, &result)
+	countAPIError("create_session", res, err)
 	if err != nil {
 		return nil, err
 	}
@@ -78,7 +161,32 @@ func (c *CochlSenseClient) CreateSession(fileName, contentType string, duration
 	return &result, nil
 }
 
-func (c *CochlSenseClient) UploadChunk(sessionID string, chunkSequence int, chunk []byte) (*RespUploadChunk, error) {
+// CreateStreamSession creates a session for live audio of the given content
+// type, uploaded chunk by chunk until CloseStream is called. Its results are
+// paged and grow as chunks are analyzed.
+func (c *CochlSenseClient) CreateStreamSession(ctx context.Context, contentType string) (*RespCreateSession, error) {
+	param := restcli.Params{
+		Body: map[string]any{
+			"type":         "stream",
+			"content_type": contentType,
+		},
+	}
+
+	var result RespCreateSession
+	res, err := restcli.Post(ctx, c.Client, "/audio_sessions/", &param, &result)
+	countAPIError("create_session", res, err)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode() != 200 {
+		return nil, fmt.Errorf("failed to create stream session: %v", res.String())
+	}
+
+	return &result, nil
+}
+
+func (c *CochlSenseClient) UploadChunk(ctx context.Context, sessionID string, chunkSequence int, chunk []byte) (*RespUploadChunk, error) {
 	base64Chunk := base64.StdEncoding.EncodeToString(chunk)
 	param := restcli.Params{
 		Body: map[string]any{
@@ -87,7 +195,8 @@ func (c *CochlSenseClient) UploadChunk(sessionID string, chunkSequence int, chun
 	}
 
 	var result RespUploadChunk
-	res, err := restcli.Put(c.Client, fmt.Sprintf("/audio_sessions/%s/chunks/%d", sessionID, chunkSequence), &param, &result)
+	res, err := restcli.Put(ctx, c.Client, fmt.Sprintf("/audio_sessions/%s/chunks/%d", sessionID, chunkSequence), &param, &result)
+	countAPIError("upload_chunk", res, err)
 	if err != nil {
 		return nil, err
 	}
@@ -99,9 +208,18 @@ func (c *CochlSenseClient) UploadChunk(sessionID string, chunkSequence int, chun
 	return &result, nil
 }
 
-func (c *CochlSenseClient) GetInferenceResult(sessionID string) (*RespInferenceResult, error) {
+// GetInferenceResult returns the results of a session. If the API pages its
+// results, nextToken, from the previous page, selects the segments to return;
+// an empty token starts from the first segment.
+func (c *CochlSenseClient) GetInferenceResult(ctx context.Context, sessionID, nextToken string) (*RespInferenceResult, error) {
+	var params *restcli.Params
+	if nextToken != "" {
+		params = &restcli.Params{Queries: map[string]string{"next_token": nextToken}}
+	}
+
 	var result RespInferenceResult
-	res, err := restcli.Get(c.Client, fmt.Sprintf("/audio_sessions/%s/results", sessionID), nil, &result)
+	res, err := restcli.Get(ctx, c.Client, fmt.Sprintf("/audio_sessions/%s/results", sessionID), params, &result)
+	countAPIError("get_results", res, err)
 	if err != nil {
 		return nil, err
 	}
@@ -109,12 +227,36 @@ func (c *CochlSenseClient) GetInferenceResult(sessionID string) (*RespInferenceR
 	if res.StatusCode() != 200 {
 		return nil, fmt.Errorf("failed to get inference result: %v", res.String())
 	}
+	result.RetryAfter = parseRetryAfter(res.Header().Get("Retry-After"), time.Now())
 
 	return &result, nil
 }
 
-func (c *CochlSenseClient) DeleteSession(sessionID string) error {
-	res, err := restcli.Delete(c.Client, fmt.Sprintf("/audio_sessions/%s", sessionID), nil)
+// CloseStream makes a stream session read-only: no more chunks are accepted
+// and its state becomes "done" once the uploaded audio is analyzed.
+func (c *CochlSenseClient) CloseStream(ctx context.Context, sessionID string) error {
+	param := restcli.Params{
+		Body: map[string]any{
+			"make_readonly": true,
+		},
+	}
+
+	res, err := restcli.Patch(ctx, c.Client, fmt.Sprintf("/audio_sessions/%s", sessionID), &param)
+	countAPIError("close_stream", res, err)
+	if err != nil {
+		return err
+	}
+
+	if res.StatusCode() != 200 {
+		return fmt.Errorf("failed to close stream session: %v", res.String())
+	}
+
+	return nil
+}
+
+func (c *CochlSenseClient) DeleteSession(ctx context.Context, sessionID string) error {
+	res, err := restcli.Delete(ctx, c.Client, fmt.Sprintf("/audio_sessions/%s", sessionID), nil)
+	countAPIError("delete_session", res, err)
 	if err != nil {
 		return err
 	}
@@ -125,3 +267,70 @@ func (c *CochlSenseClient) DeleteSession(sessionID string) error {
 
 	return nil
 }
+
+// CheckConnection verifies that the API is reachable and accepts the project
+// key, without creating a session: it requests the results of a session that
+// does not exist, which is answered with a JSON "not found" error once the
+// key is accepted. Other 404 responses, such as those of a proxy or a wrong
+// base URL, are errors. It returns ErrNoProjectKey or ErrInvalidProjectKey if the key is
+// missing or rejected, an *APIError for any other unexpected response, and
+// the network error if the API is unreachable.
+func (c *CochlSenseClient) CheckConnection(ctx context.Context) error {
+	res, err := restcli.Get(ctx, c.Client, fmt.Sprintf("/audio_sessions/%s/results", checkSessionID), nil)
+	if err != nil {
+		metrics.APIErrors.Inc("check_connection", "network")
+		return err
+	}
+
+	switch status := res.StatusCode(); {
+	case status == http.StatusOK,
+		status == http.StatusNotFound && sessionNotFound(res.String()):
+		return nil
+	case status == http.StatusUnauthorized, status == http.StatusForbidden:
+		if c.Client.Header().Get("X-Api-Key") == "" {
+			return ErrNoProjectKey
+		}
+		return ErrInvalidProjectKey
+	default:
+		metrics.APIErrors.Inc("check_connection", strconv.Itoa(res.StatusCode()))
+		return &APIError{StatusCode: res.StatusCode(), Body: res.String()}
+	}
+}
+
+// sessionNotFound reports whether body is the API's JSON error for a session
+// that does not exist.
+func sessionNotFound(body string) bool {
+	var e struct {
+		Error   string `json:"error"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal([]byte(body), &e); err != nil {
+		return false
+	}
+	return strings.Contains(strings.ToLower(e.Error+" "+e.Message), "not found")
+}
+
+// parseRetryAfter converts a Retry-After header, in seconds or as an HTTP
+// date, into a delay. It returns zero for a missing or invalid header.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+	return 0
+}
+
+// countAPIError counts a failed request in the metrics package.
+func countAPIError(operation string, res *resty.Response, err error) {
+	switch {
+	case err != nil:
+		metrics.APIErrors.Inc(operation, "network")
+	case res.StatusCode() != 200:
+		metrics.APIErrors.Inc(operation, strconv.Itoa(res.StatusCode()))
+	}
+}
@@ -0,0 +1,488 @@
+// Package fakesense implements an in-process fake of the Cochl Sense audio
+// session API for tests and the server's offline mock mode. It serves the
+// same routes as the real API under /sense/api/v1/audio_sessions and can be
+// scripted to return canned results, delay responses or fail specific
+// requests.
+package fakesense
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cochlearai/cochl-mcp-server/client"
+)
+
+// Endpoint identifies one of the audio session routes.
+type Endpoint string
+
+const (
+	EndpointCreateSession Endpoint = "create_session"
+	EndpointUploadChunk   Endpoint = "upload_chunk"
+	EndpointGetResults    Endpoint = "get_results"
+	EndpointUpdateSession Endpoint = "update_session"
+	EndpointDeleteSession Endpoint = "delete_session"
+)
+
+// Response is a scripted reply for a single request. A zero Status means the
+// request is handled normally after Delay.
+type Response struct {
+	Status int
+	Body   string
+	Delay  time.Duration
+}
+
+// Session records what a client sent for one audio session.
+type Session struct {
+	ID          string
+	Type        string
+	ContentType string
+	FileName    string
+	TotalSize   int
+	FileLength  float64
+	Chunks      [][]byte
+	Polls       int
+	// ReadOnly is set once a stream session is closed.
+	ReadOnly bool
+	Deleted  bool
+}
+
+// StreamDuration returns the seconds of audio uploaded to a stream session of
+// raw 16-bit PCM, as described by its "audio/x-raw" content type, or zero for
+// other sessions.
+func (s *Session) StreamDuration() float64 {
+	rate, channels := 0, 1
+	params := strings.Split(s.ContentType, ";")
+	if strings.TrimSpace(params[0]) != "audio/x-raw" {
+		return 0
+	}
+	for _, param := range params[1:] {
+		key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+		switch key {
+		case "rate":
+			rate, _ = strconv.Atoi(value)
+		case "channels":
+			channels, _ = strconv.Atoi(value)
+		}
+	}
+	if rate <= 0 || channels <= 0 {
+		return 0
+	}
+	return float64(len(s.Data())) / float64(rate*channels*2)
+}
+
+// Data returns the concatenated contents of all uploaded chunks.
+func (s *Session) Data() []byte {
+	var data []byte
+	for _, c := range s.Chunks {
+		data = append(data, c...)
+	}
+	return data
+}
+
+type Option func(*Backend)
+
+// WithResults sets the inference results returned for every session once it
+// is done.
+func WithResults(results ...client.InferenceResult) Option {
+	return func(b *Backend) {
+		b.results = results
+	}
+}
+
+// WithResultFunc computes the inference results of each session from what
+// was uploaded to it. It takes precedence over WithResults.
+func WithResultFunc(fn func(s Session) []client.InferenceResult) Option {
+	return func(b *Backend) {
+		b.resultFunc = fn
+	}
+}
+
+// WithPendingPolls makes the results endpoint report a "pending" state for
+// the first n polls of each session.
+func WithPendingPolls(n int) Option {
+	return func(b *Backend) {
+		b.pendingPolls = n
+	}
+}
+
+// WithPagedResults makes the results endpoint page its results, at most
+// pageSize segments per response, and release them incrementally: after n
+// pending polls, n*pageSize segments are available. The next_token of a
+// page is the offset of its end; this format is specific to the fake, since
+// the tokens of the real API are opaque.
+func WithPagedResults(pageSize int) Option {
+	return func(b *Backend) {
+		b.pageSize = pageSize
+	}
+}
+
+// WithRetryAfter makes pending results responses ask the client, with a
+// Retry-After header, to wait d before polling again.
+func WithRetryAfter(d time.Duration) Option {
+	return func(b *Backend) {
+		b.retryAfter = d
+	}
+}
+
+// WithDelay delays every response of endpoint by d.
+func WithDelay(endpoint Endpoint, d time.Duration) Option {
+	return func(b *Backend) {
+		b.delays[endpoint] = d
+	}
+}
+
+// WithAPIKey makes the backend reject requests whose X-Api-Key header does
+// not match key.
+func WithAPIKey(key string) Option {
+	return func(b *Backend) {
+		b.apiKey = key
+	}
+}
+
+// Backend is an http.Handler serving the fake audio session API.
+type Backend struct {
+	mu           sync.Mutex
+	mux          *http.ServeMux
+	nextID       int
+	sessions     map[string]*Session
+	order        []string
+	scripts      map[Endpoint][]Response
+	delays       map[Endpoint]time.Duration
+	results      []client.InferenceResult
+	resultFunc   func(s Session) []client.InferenceResult
+	pendingPolls int
+	retryAfter   time.Duration
+	pageSize     int
+	apiKey       string
+}
+
+func NewBackend(opts ...Option) *Backend {
+	b := &Backend{
+		mux:      http.NewServeMux(),
+		sessions: make(map[string]*Session),
+		scripts:  make(map[Endpoint][]Response),
+		delays:   make(map[Endpoint]time.Duration),
+		results:  []client.InferenceResult{},
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+
+	const prefix = "/sense/api/v1/audio_sessions"
+	b.mux.HandleFunc("POST "+prefix+"/{$}", b.handle(EndpointCreateSession, b.createSession))
+	b.mux.HandleFunc("PUT "+prefix+"/{id}/chunks/{seq}", b.handle(EndpointUploadChunk, b.uploadChunk))
+	b.mux.HandleFunc("GET "+prefix+"/{id}/results", b.handle(EndpointGetResults, b.getResults))
+	b.mux.HandleFunc("PATCH "+prefix+"/{id}", b.handle(EndpointUpdateSession, b.updateSession))
+	b.mux.HandleFunc("DELETE "+prefix+"/{id}", b.handle(EndpointDeleteSession, b.deleteSession))
+	return b
+}
+
+func (b *Backend) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.mux.ServeHTTP(w, r)
+}
+
+// Script queues responses for endpoint. Each request to endpoint consumes one
+// response; once the queue is empty the endpoint behaves normally again.
+func (b *Backend) Script(endpoint Endpoint, responses ...Response) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.scripts[endpoint] = append(b.scripts[endpoint], responses...)
+}
+
+// Sessions returns a snapshot of every session created so far, in creation
+// order.
+func (b *Backend) Sessions() []Session {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	sessions := make([]Session, 0, len(b.order))
+	for _, id := range b.order {
+		s := *b.sessions[id]
+		s.Chunks = append([][]byte(nil), s.Chunks...)
+		sessions = append(sessions, s)
+	}
+	return sessions
+}
+
+type handlerFunc func(w http.ResponseWriter, r *http.Request) (int, any)
+
+func (b *Backend) handle(endpoint Endpoint, fn handlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		b.mu.Lock()
+		delay := b.delays[endpoint]
+		var scripted *Response
+		if queue := b.scripts[endpoint]; len(queue) > 0 {
+			scripted = &queue[0]
+			b.scripts[endpoint] = queue[1:]
+		}
+		b.mu.Unlock()
+
+		if scripted != nil {
+			delay += scripted.Delay
+		}
+		if delay > 0 {
+			select {
+			case <-time.After(delay):
+			case <-r.Context().Done():
+				return
+			}
+		}
+
+		if scripted != nil && scripted.Status != 0 {
+			w.WriteHeader(scripted.Status)
+			w.Write([]byte(scripted.Body))
+			return
+		}
+
+		if b.apiKey != "" && r.Header.Get("X-Api-Key") != b.apiKey {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid project key"})
+			return
+		}
+
+		status, body := fn(w, r)
+		writeJSON(w, status, body)
+	}
+}
+
+func (b *Backend) createSession(w http.ResponseWriter, r *http.Request) (int, any) {
+	var req struct {
+		Type        string  `json:"type"`
+		ContentType string  `json:"content_type"`
+		TotalSize   int     `json:"total_size"`
+		FileName    string  `json:"file_name"`
+		FileLength  float64 `json:"file_length"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return http.StatusBadRequest, errorBody("invalid request body: %v", err)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.nextID++
+	s := &Session{
+		ID:          fmt.Sprintf("session-%d", b.nextID),
+		Type:        req.Type,
+		ContentType: req.ContentType,
+		FileName:    req.FileName,
+		TotalSize:   req.TotalSize,
+		FileLength:  req.FileLength,
+	}
+	b.sessions[s.ID] = s
+	b.order = append(b.order, s.ID)
+
+	return http.StatusOK, client.RespCreateSession{
+		SessionID:     s.ID,
+		ChunkSequence: 0,
+		WindowSize:    1000,
+		WindowHop:     500,
+	}
+}
+
+func (b *Backend) uploadChunk(w http.ResponseWriter, r *http.Request) (int, any) {
+	seq, err := strconv.Atoi(r.PathValue("seq"))
+	if err != nil {
+		return http.StatusBadRequest, errorBody("invalid chunk sequence %q", r.PathValue("seq"))
+	}
+
+	var req struct {
+		Data string `json:"data"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return http.StatusBadRequest, errorBody("invalid request body: %v", err)
+	}
+	chunk, err := base64.StdEncoding.DecodeString(req.Data)
+	if err != nil {
+		return http.StatusBadRequest, errorBody("chunk data is not valid base64: %v", err)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s, ok := b.sessions[r.PathValue("id")]
+	if !ok || s.Deleted {
+		return http.StatusNotFound, errorBody("session not found")
+	}
+	if s.ReadOnly {
+		return http.StatusBadRequest, errorBody("session is read-only")
+	}
+	if seq != len(s.Chunks) {
+		return http.StatusBadRequest, errorBody("expected chunk sequence %d, got %d", len(s.Chunks), seq)
+	}
+	s.Chunks = append(s.Chunks, chunk)
+
+	return http.StatusOK, client.RespUploadChunk{
+		ChunkSequence: seq + 1,
+		SessionID:     s.ID,
+	}
+}
+
+func (b *Backend) getResults(w http.ResponseWriter, r *http.Request) (int, any) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s, ok := b.sessions[r.PathValue("id")]
+	if !ok || s.Deleted {
+		return http.StatusNotFound, errorBody("session not found")
+	}
+	s.Polls++
+
+	if s.Type == "stream" {
+		return http.StatusOK, b.streamPage(r, s)
+	}
+
+	pending := s.Polls <= b.pendingPolls
+	if pending && b.retryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(b.retryAfter/time.Second)))
+	}
+	state := "done"
+	if pending {
+		state = "pending"
+	}
+
+	results := b.results
+	if b.resultFunc != nil {
+		results = b.resultFunc(*s)
+	}
+
+	if b.pageSize > 0 {
+		return http.StatusOK, b.page(r, s, results, state)
+	}
+	if pending {
+		results = []client.InferenceResult{}
+	}
+	return http.StatusOK, client.RespInferenceResult{
+		Data:  results,
+		State: state,
+	}
+}
+
+// page returns the page of results selected by the next_token query
+// parameter, an offset into those released so far.
+func (b *Backend) page(r *http.Request, s *Session, results []client.InferenceResult, state string) client.RespInferenceResult {
+	available := len(results)
+	if state == "pending" {
+		available = min(available, (s.Polls-1)*b.pageSize)
+	}
+
+	offset, _ := strconv.Atoi(r.URL.Query().Get("next_token"))
+	offset = min(max(offset, 0), available)
+	end := min(offset+b.pageSize, available)
+
+	return client.RespInferenceResult{
+		Data:  append([]client.InferenceResult{}, results[offset:end]...),
+		State: state,
+		Page: &client.ResultPage{
+			Offset:    offset,
+			Count:     end - offset,
+			Total:     available,
+			NextToken: strconv.Itoa(end),
+		},
+	}
+}
+
+// streamPage returns a page of the results of a stream session. While the
+// stream is open, a segment is released once audio past its end has been
+// uploaded; once it is closed, every segment is.
+func (b *Backend) streamPage(r *http.Request, s *Session) client.RespInferenceResult {
+	results := b.results
+	if b.resultFunc != nil {
+		results = b.resultFunc(*s)
+	}
+
+	state := "done"
+	available := len(results)
+	if !s.ReadOnly {
+		state = "pending"
+		streamed := int(s.StreamDuration() * 1000)
+		available = 0
+		for available < len(results) && results[available].EndTime < streamed {
+			available++
+		}
+	}
+
+	offset, _ := strconv.Atoi(r.URL.Query().Get("next_token"))
+	offset = min(max(offset, 0), available)
+	end := available
+	if b.pageSize > 0 {
+		end = min(offset+b.pageSize, available)
+	}
+
+	return client.RespInferenceResult{
+		Data:  append([]client.InferenceResult{}, results[offset:end]...),
+		State: state,
+		Page: &client.ResultPage{
+			Offset:    offset,
+			Count:     end - offset,
+			Total:     available,
+			NextToken: strconv.Itoa(end),
+		},
+	}
+}
+
+func (b *Backend) updateSession(w http.ResponseWriter, r *http.Request) (int, any) {
+	var req struct {
+		MakeReadOnly bool `json:"make_readonly"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return http.StatusBadRequest, errorBody("invalid request body: %v", err)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s, ok := b.sessions[r.PathValue("id")]
+	if !ok || s.Deleted {
+		return http.StatusNotFound, errorBody("session not found")
+	}
+	if req.MakeReadOnly {
+		s.ReadOnly = true
+	}
+
+	return http.StatusOK, map[string]string{}
+}
+
+func (b *Backend) deleteSession(w http.ResponseWriter, r *http.Request) (int, any) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s, ok := b.sessions[r.PathValue("id")]
+	if !ok || s.Deleted {
+		return http.StatusNotFound, errorBody("session not found")
+	}
+	s.Deleted = true
+
+	return http.StatusOK, map[string]string{}
+}
+
+func errorBody(format string, args ...any) map[string]string {
+	return map[string]string{"error": fmt.Sprintf(format, args...)}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// Server is a Backend listening on a local httptest server. Its URL can be
+// used as the Cochl Sense base URL.
+type Server struct {
+	*Backend
+	*httptest.Server
+}
+
+func NewServer(opts ...Option) *Server {
+	b := NewBackend(opts...)
+	return &Server{
+		Backend: b,
+		Server:  httptest.NewServer(b),
+	}
+}
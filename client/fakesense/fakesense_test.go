@@ -0,0 +1,183 @@
+package fakesense
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cochlearai/cochl-mcp-server/client"
+)
+
+// segments returns n one-second segments tagged by their index.
+func segments(n int) []client.InferenceResult {
+	results := make([]client.InferenceResult, n)
+	for i := range results {
+		results[i] = client.InferenceResult{
+			StartTime: i * 1000,
+			EndTime:   (i + 1) * 1000,
+			Tags:      []client.Tags{{Name: "Speech", Probability: float64(i) / 10}},
+		}
+	}
+	return results
+}
+
+func newTestClient(t *testing.T, opts ...Option) (*Server, *client.CochlSenseClient) {
+	t.Helper()
+	fake := NewServer(opts...)
+	t.Cleanup(fake.Close)
+	return fake, client.NewCochlSense("test-key", fake.URL, "test")
+}
+
+func TestSessionLifecycle(t *testing.T) {
+	want := segments(2)
+	fake, c := newTestClient(t, WithAPIKey("test-key"), WithResults(want...))
+	ctx := context.Background()
+
+	session, err := c.CreateSession(ctx, "a.wav", "audio/wav", 2, 4)
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	if _, err := c.UploadChunk(ctx, session.SessionID, 1, []byte("data")); err == nil || !strings.Contains(err.Error(), "expected chunk sequence 0") {
+		t.Errorf("got %v, want an out of sequence chunk refused", err)
+	}
+	if _, err := c.UploadChunk(ctx, session.SessionID, session.ChunkSequence, []byte("data")); err != nil {
+		t.Fatalf("failed to upload chunk: %v", err)
+	}
+
+	result, err := c.GetInferenceResult(ctx, session.SessionID, "")
+	if err != nil {
+		t.Fatalf("failed to get results: %v", err)
+	}
+	if result.State != "done" || !reflect.DeepEqual(result.Data, want) {
+		t.Errorf("got %s results %+v, want done %+v", result.State, result.Data, want)
+	}
+
+	if err := c.DeleteSession(ctx, session.SessionID); err != nil {
+		t.Fatalf("failed to delete session: %v", err)
+	}
+	if _, err := c.GetInferenceResult(ctx, session.SessionID, ""); err == nil || !strings.Contains(err.Error(), "session not found") {
+		t.Errorf("got %v, want a deleted session not found", err)
+	}
+
+	sessions := fake.Sessions()
+	if len(sessions) != 1 {
+		t.Fatalf("expected 1 session, got %d", len(sessions))
+	}
+	s := sessions[0]
+	if s.Type != "file" || s.FileName != "a.wav" || s.TotalSize != 4 || string(s.Data()) != "data" || s.Polls != 1 || !s.Deleted {
+		t.Errorf("unexpected session record: %+v", s)
+	}
+
+	// Requests with another project key are refused.
+	other := client.NewCochlSense("wrong-key", fake.URL, "test")
+	if _, err := other.CreateSession(ctx, "b.wav", "audio/wav", 1, 1); err == nil || !strings.Contains(err.Error(), "invalid project key") {
+		t.Errorf("got %v, want the wrong key refused", err)
+	}
+}
+
+func TestStreamLifecycle(t *testing.T) {
+	fake, c := newTestClient(t, WithResults(segments(3)...))
+	ctx := context.Background()
+
+	session, err := c.CreateStreamSession(ctx, "audio/x-raw; rate=8000; format=s16le; channels=1")
+	if err != nil {
+		t.Fatalf("failed to create stream session: %v", err)
+	}
+	// 1.5 s of audio releases the segment ending at 1 s.
+	if _, err := c.UploadChunk(ctx, session.SessionID, 0, make([]byte, 8000*2*3/2)); err != nil {
+		t.Fatalf("failed to upload chunk: %v", err)
+	}
+	result, err := c.GetInferenceResult(ctx, session.SessionID, "")
+	if err != nil {
+		t.Fatalf("failed to get results: %v", err)
+	}
+	if result.State != "pending" || len(result.Data) != 1 {
+		t.Errorf("got %s with %d segments, want pending with 1", result.State, len(result.Data))
+	}
+
+	if err := c.CloseStream(ctx, session.SessionID); err != nil {
+		t.Fatalf("failed to close stream: %v", err)
+	}
+	if _, err := c.UploadChunk(ctx, session.SessionID, 1, make([]byte, 2)); err == nil || !strings.Contains(err.Error(), "read-only") {
+		t.Errorf("got %v, want uploads to a closed stream refused", err)
+	}
+	result, err = c.GetInferenceResult(ctx, session.SessionID, result.Page.NextToken)
+	if err != nil {
+		t.Fatalf("failed to get results: %v", err)
+	}
+	if result.State != "done" || len(result.Data) != 2 || result.Page.Offset != 1 {
+		t.Errorf("got %s with %d segments from %d, want the 2 remaining ones done", result.State, len(result.Data), result.Page.Offset)
+	}
+	if sessions := fake.Sessions(); !sessions[0].ReadOnly || sessions[0].StreamDuration() != 1.5 {
+		t.Errorf("unexpected session record: %+v", sessions[0])
+	}
+}
+
+func TestPagedResults(t *testing.T) {
+	want := segments(5)
+	_, c := newTestClient(t, WithResults(want...), WithPagedResults(2), WithPendingPolls(1))
+	ctx := context.Background()
+
+	session, err := c.CreateSession(ctx, "a.wav", "audio/wav", 5, 1)
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+
+	// No segment is released before the first pending poll.
+	result, err := c.GetInferenceResult(ctx, session.SessionID, "")
+	if err != nil {
+		t.Fatalf("failed to get results: %v", err)
+	}
+	if result.State != "pending" || len(result.Data) != 0 || result.Page.HasMore() {
+		t.Errorf("got %s page %+v, want an empty pending page", result.State, result.Page)
+	}
+
+	var got []client.InferenceResult
+	var tokens []string
+	token := ""
+	for {
+		result, err := c.GetInferenceResult(ctx, session.SessionID, token)
+		if err != nil {
+			t.Fatalf("failed to get results: %v", err)
+		}
+		got = append(got, result.Data...)
+		token = result.Page.NextToken
+		tokens = append(tokens, token)
+		if !result.Page.HasMore() {
+			break
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if want := []string{"2", "4", "5"}; !reflect.DeepEqual(tokens, want) {
+		t.Errorf("got tokens %v, want the end offsets %v", tokens, want)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	_, c := newTestClient(t, WithRetryAfter(3*time.Second), WithPendingPolls(1))
+	ctx := context.Background()
+
+	session, err := c.CreateSession(ctx, "a.wav", "audio/wav", 1, 1)
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	for _, want := range []struct {
+		state      string
+		retryAfter time.Duration
+	}{
+		{"pending", 3 * time.Second},
+		{"done", 0},
+	} {
+		result, err := c.GetInferenceResult(ctx, session.SessionID, "")
+		if err != nil {
+			t.Fatalf("failed to get results: %v", err)
+		}
+		if result.State != want.state || result.RetryAfter != want.retryAfter {
+			t.Errorf("got %s with Retry-After %v, want %s with %v", result.State, result.RetryAfter, want.state, want.retryAfter)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package mocksense
+
+import (
+	"encoding/binary"
+	"math"
+	"sort"
+
+	"github.com/cochlearai/cochl-mcp-server/client"
+)
+
+const (
+	windowSizeMs = 1000
+	windowHopMs  = 500
+
+	// silenceDB is the window loudness below which nothing is detected.
+	silenceDB = -45.0
+	// floorDB is the loudness of digital silence, so onsets after it are
+	// measured from a finite level.
+	floorDB = -100.0
+)
+
+// window holds the features of one analysis window. Energy is the RMS level
+// in dBFS and zcr the zero-crossing rate, or -1 when the samples could not be
+// decoded.
+type window struct {
+	startMs, endMs int
+	energy         float64
+	zcr            float64
+}
+
+// Analyze derives deterministic inference results from audio data by
+// splitting it into overlapping windows and tagging each one from its
+// loudness and zero-crossing rate. PCM WAV data is decoded; for compressed
+// formats the byte-level variance of the encoded stream stands in for
+// loudness. The results are plausible, not accurate.
+func Analyze(data []byte, contentType string, duration float64) []client.InferenceResult {
+	durationMs := int(math.Round(duration * 1000))
+	if durationMs <= 0 {
+		return []client.InferenceResult{}
+	}
+
+	var windows []window
+	if contentType == "audio/wav" {
+		windows = pcmWindows(data, durationMs)
+	}
+	if windows == nil {
+		windows = byteWindows(data, durationMs)
+	}
+
+	results := make([]client.InferenceResult, 0, len(windows))
+	for i, w := range windows {
+		var prev *window
+		if i > 0 {
+			prev = &windows[i-1]
+		}
+		results = append(results, client.InferenceResult{
+			StartTime: w.startMs,
+			EndTime:   w.endMs,
+			Tags:      []client.Tags{tagWindow(w, prev)},
+		})
+	}
+	return results
+}
+
+// tagWindow tags w given the window before it, which is nil for the first
+// window: audio that starts loud has no onset to detect.
+func tagWindow(w window, prev *window) client.Tags {
+	if w.energy < silenceDB {
+		return client.Tags{Name: "Others", Probability: 0}
+	}
+
+	// Map loudness from the silence threshold up to 0 dBFS onto 0.5-0.99.
+	p := 0.5 + 0.49*(w.energy-silenceDB)/-silenceDB
+	p = math.Round(math.Min(math.Max(p, 0.5), 0.99)*1000) / 1000
+
+	var name string
+	switch {
+	case prev != nil && w.energy-prev.energy > 12:
+		name = "Knock"
+	case w.zcr < 0 && w.energy > -15:
+		name = "Music"
+	case w.zcr < 0:
+		name = "Speech"
+	case w.zcr < 0.02:
+		name = "Music"
+	case w.zcr < 0.15:
+		name = "Speech"
+	default:
+		name = "Whistle"
+	}
+	return client.Tags{Name: name, Probability: p}
+}
+
+// windowBounds returns the start and end of every analysis window covering
+// durationMs.
+func windowBounds(durationMs int) [][2]int {
+	var bounds [][2]int
+	for start := 0; start < durationMs; start += windowHopMs {
+		end := min(start+windowSizeMs, durationMs)
+		bounds = append(bounds, [2]int{start, end})
+		if end == durationMs {
+			break
+		}
+	}
+	return bounds
+}
+
+// pcmWindows decodes integer PCM samples from a WAV file. It returns nil if
+// the file is not a WAV with a PCM format it understands.
+func pcmWindows(data []byte, durationMs int) []window {
+	samples, sampleRate := decodePCM(data)
+	if samples == nil || sampleRate == 0 {
+		return nil
+	}
+
+	var windows []window
+	for _, b := range windowBounds(durationMs) {
+		from := min(b[0]*sampleRate/1000, len(samples))
+		to := min(b[1]*sampleRate/1000, len(samples))
+		windows = append(windows, window{
+			startMs: b[0],
+			endMs:   b[1],
+			energy:  rmsDB(samples[from:to]),
+			zcr:     zeroCrossingRate(samples[from:to]),
+		})
+	}
+	return windows
+}
+
+// decodePCM returns the first channel of a PCM WAV file as samples scaled to
+// [-1, 1], along with the sample rate.
+func decodePCM(data []byte) ([]float64, int) {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+		return nil, 0
+	}
+
+	var (
+		format, channels, bits uint16
+		sampleRate             uint32
+		pcm                    []byte
+	)
+	for off := 12; off+8 <= len(data); {
+		id := string(data[off : off+4])
+		size := int(binary.LittleEndian.Uint32(data[off+4 : off+8]))
+		body := data[off+8 : min(off+8+size, len(data))]
+
+		switch id {
+		case "fmt ":
+			if len(body) < 16 {
+				return nil, 0
+			}
+			format = binary.LittleEndian.Uint16(body[0:2])
+			channels = binary.LittleEndian.Uint16(body[2:4])
+			sampleRate = binary.LittleEndian.Uint32(body[4:8])
+			bits = binary.LittleEndian.Uint16(body[14:16])
+		case "data":
+			pcm = body
+		}
+		off += 8 + size + size%2
+	}
+
+	// 1 is integer PCM, 0xFFFE is WAVE_FORMAT_EXTENSIBLE which is almost
+	// always integer PCM as well.
+	if (format != 1 && format != 0xFFFE) || channels == 0 || pcm == nil {
+		return nil, 0
+	}
+
+	bytesPerSample := int(bits) / 8
+	if bytesPerSample < 1 || bytesPerSample > 4 {
+		return nil, 0
+	}
+	frameSize := bytesPerSample * int(channels)
+	scale := math.Pow(2, float64(bits-1))
+
+	samples := make([]float64, 0, len(pcm)/frameSize)
+	for off := 0; off+frameSize <= len(pcm); off += frameSize {
+		var v int32
+		switch bytesPerSample {
+		case 1:
+			v = int32(pcm[off]) - 128
+		case 2:
+			v = int32(int16(binary.LittleEndian.Uint16(pcm[off:])))
+		case 3:
+			v = int32(uint32(pcm[off])<<8|uint32(pcm[off+1])<<16|uint32(pcm[off+2])<<24) >> 8
+		case 4:
+			v = int32(binary.LittleEndian.Uint32(pcm[off:]))
+		}
+		samples = append(samples, float64(v)/scale)
+	}
+	return samples, int(sampleRate)
+}
+
+func rmsDB(samples []float64) float64 {
+	if len(samples) == 0 {
+		return floorDB
+	}
+	var sum float64
+	for _, s := range samples {
+		sum += s * s
+	}
+	return math.Max(20*math.Log10(math.Sqrt(sum/float64(len(samples)))), floorDB)
+}
+
+func zeroCrossingRate(samples []float64) float64 {
+	if len(samples) < 2 {
+		return 0
+	}
+	var crossings int
+	for i := 1; i < len(samples); i++ {
+		if (samples[i-1] < 0) != (samples[i] < 0) {
+			crossings++
+		}
+	}
+	return float64(crossings) / float64(len(samples)-1)
+}
+
+// byteWindows estimates loudness of compressed audio from the spread of byte
+// values in the part of the stream proportional to each window. Louder and
+// busier passages take more bits to encode, so their bytes look more random.
+func byteWindows(data []byte, durationMs int) []window {
+	bounds := windowBounds(durationMs)
+	spreads := make([]float64, len(bounds))
+	for i, b := range bounds {
+		from := len(data) * b[0] / durationMs
+		to := len(data) * b[1] / durationMs
+		spreads[i] = byteSpread(data[from:to])
+	}
+
+	// Normalize the spreads so the busiest passage of any file lands near
+	// 0 dBFS and the quietest just above the silence threshold.
+	sorted := append([]float64(nil), spreads...)
+	sort.Float64s(sorted)
+	lo, hi := sorted[0], sorted[len(sorted)-1]
+
+	windows := make([]window, len(bounds))
+	for i, b := range bounds {
+		rel := 1.0
+		if hi > lo {
+			rel = (spreads[i] - lo) / (hi - lo)
+		}
+		windows[i] = window{
+			startMs: b[0],
+			endMs:   b[1],
+			energy:  silenceDB + 5 + rel*(-silenceDB-10),
+			zcr:     -1,
+		}
+	}
+	return windows
+}
+
+func byteSpread(data []byte) float64 {
+	if len(data) == 0 {
+		return 0
+	}
+	var sum, sumSq float64
+	for _, b := range data {
+		sum += float64(b)
+		sumSq += float64(b) * float64(b)
+	}
+	n := float64(len(data))
+	return math.Sqrt(sumSq/n - (sum/n)*(sum/n))
+}
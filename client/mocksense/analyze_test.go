@@ -0,0 +1,156 @@
+package mocksense
+
+import (
+	"context"
+	"encoding/binary"
+	"math"
+	"os"
+	"reflect"
+	"testing"
+)
+
+// newWAV builds a mono 16-bit PCM WAV file from samples in [-1, 1].
+func newWAV(sampleRate int, samples []float64) []byte {
+	data := make([]byte, 44+2*len(samples))
+	copy(data[0:4], "RIFF")
+	binary.LittleEndian.PutUint32(data[4:8], uint32(36+2*len(samples)))
+	copy(data[8:12], "WAVE")
+	copy(data[12:16], "fmt ")
+	binary.LittleEndian.PutUint32(data[16:20], 16)
+	binary.LittleEndian.PutUint16(data[20:22], 1)
+	binary.LittleEndian.PutUint16(data[22:24], 1)
+	binary.LittleEndian.PutUint32(data[24:28], uint32(sampleRate))
+	binary.LittleEndian.PutUint32(data[28:32], uint32(sampleRate*2))
+	binary.LittleEndian.PutUint16(data[32:34], 2)
+	binary.LittleEndian.PutUint16(data[34:36], 16)
+	copy(data[36:40], "data")
+	binary.LittleEndian.PutUint32(data[40:44], uint32(2*len(samples)))
+	for i, s := range samples {
+		binary.LittleEndian.PutUint16(data[44+2*i:], uint16(int16(s*math.MaxInt16)))
+	}
+	return data
+}
+
+func TestAnalyzeWAV(t *testing.T) {
+	const sampleRate = 8000
+
+	// Two seconds of silence followed by two seconds of a 50 Hz tone.
+	samples := make([]float64, 4*sampleRate)
+	for i := 2 * sampleRate; i < len(samples); i++ {
+		samples[i] = 0.5 * math.Sin(2*math.Pi*50*float64(i)/sampleRate)
+	}
+
+	results := Analyze(newWAV(sampleRate, samples), "audio/wav", 4)
+
+	wantBounds := [][2]int{{0, 1000}, {500, 1500}, {1000, 2000}, {1500, 2500}, {2000, 3000}, {2500, 3500}, {3000, 4000}}
+	if len(results) != len(wantBounds) {
+		t.Fatalf("expected %d windows, got %d", len(wantBounds), len(results))
+	}
+
+	for i, r := range results {
+		if r.StartTime != wantBounds[i][0] || r.EndTime != wantBounds[i][1] {
+			t.Errorf("window %d: got %d-%d, want %d-%d", i, r.StartTime, r.EndTime, wantBounds[i][0], wantBounds[i][1])
+		}
+		if len(r.Tags) != 1 {
+			t.Fatalf("window %d: expected 1 tag, got %d", i, len(r.Tags))
+		}
+
+		tag := r.Tags[0]
+		switch {
+		case r.EndTime <= 2000:
+			if tag.Name != "Others" {
+				t.Errorf("window %d: expected silence to be tagged Others, got %s", i, tag.Name)
+			}
+		case r.StartTime >= 2000:
+			if tag.Name != "Music" || tag.Probability < 0.5 {
+				t.Errorf("window %d: expected steady tone to be tagged Music, got %+v", i, tag)
+			}
+		default:
+			if tag.Name != "Knock" {
+				t.Errorf("window %d: expected onset to be tagged Knock, got %s", i, tag.Name)
+			}
+		}
+	}
+}
+
+func TestAnalyzeSoundFromStart(t *testing.T) {
+	const sampleRate = 8000
+
+	// One second of a 50 Hz tone from the first sample.
+	samples := make([]float64, sampleRate)
+	for i := range samples {
+		samples[i] = 0.5 * math.Sin(2*math.Pi*50*float64(i)/sampleRate)
+	}
+
+	results := Analyze(newWAV(sampleRate, samples), "audio/wav", 1)
+	if len(results) != 1 || results[0].Tags[0].Name != "Music" {
+		t.Errorf("expected the tone to be tagged Music without an onset, got %+v", results)
+	}
+
+	data, err := os.ReadFile("../../util/audio/testdata/wav-test.wav")
+	if err != nil {
+		t.Fatalf("failed to read test file: %v", err)
+	}
+	if first := Analyze(data, "audio/wav", 10)[0].Tags[0]; first.Name == "Knock" {
+		t.Errorf("expected no onset in the first window, got %+v", first)
+	}
+}
+
+func TestAnalyzeIsDeterministic(t *testing.T) {
+	for _, tt := range []struct {
+		file        string
+		contentType string
+	}{
+		{"wav-test.wav", "audio/wav"},
+		{"mp3-test.mp3", "audio/mp3"},
+		{"ogg-test.ogg", "audio/ogg"},
+	} {
+		t.Run(tt.file, func(t *testing.T) {
+			data, err := os.ReadFile("../../util/audio/testdata/" + tt.file)
+			if err != nil {
+				t.Fatalf("failed to read test file: %v", err)
+			}
+
+			first := Analyze(data, tt.contentType, 10)
+			if len(first) != 19 {
+				t.Fatalf("expected 19 windows for 10s of audio, got %d", len(first))
+			}
+			if second := Analyze(data, tt.contentType, 10); !reflect.DeepEqual(first, second) {
+				t.Error("expected identical results for identical input")
+			}
+		})
+	}
+}
+
+func TestNewClient(t *testing.T) {
+	data := newWAV(8000, make([]float64, 8000))
+	c := NewClient("test")
+
+	session, err := c.CreateSession(context.Background(), "silence.wav", "wav", 1, len(data))
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	if _, err := c.UploadChunk(context.Background(), session.SessionID, session.ChunkSequence, data); err != nil {
+		t.Fatalf("failed to upload chunk: %v", err)
+	}
+
+	first, err := c.GetInferenceResult(context.Background(), session.SessionID, "")
+	if err != nil {
+		t.Fatalf("failed to get result: %v", err)
+	}
+	if first.State != "pending" {
+		t.Errorf("expected first poll to be pending, got %s", first.State)
+	}
+
+	second, err := c.GetInferenceResult(context.Background(), session.SessionID, "")
+	if err != nil {
+		t.Fatalf("failed to get result: %v", err)
+	}
+	if second.State != "done" || len(second.Data) != 1 || second.Data[0].Tags[0].Name != "Others" {
+		t.Errorf("unexpected result: %+v", second)
+	}
+
+	if err := c.DeleteSession(context.Background(), session.SessionID); err != nil {
+		t.Fatalf("failed to delete session: %v", err)
+	}
+}
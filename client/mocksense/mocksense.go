@@ -0,0 +1,49 @@
+// Package mocksense provides a CochlSense client backed by an embedded fake
+// of the Cochl Sense API, so the server can run without a project key or
+// network access. Inference results are synthesized from the uploaded audio
+// by Analyze.
+package mocksense
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/cochlearai/cochl-mcp-server/client"
+	"github.com/cochlearai/cochl-mcp-server/client/fakesense"
+)
+
+// BaseURL is the base URL that selects the mock backend.
+const BaseURL = "mock://"
+
+// NewClient returns a client whose requests are served in-process by a fresh
+// fake backend. Each session reports one pending poll before its results, so
+// the full session, chunk and result lifecycle is exercised.
+func NewClient(version string) *client.CochlSenseClient {
+	backend := fakesense.NewBackend(
+		fakesense.WithPendingPolls(1),
+		fakesense.WithResultFunc(func(s fakesense.Session) []client.InferenceResult {
+			if s.Type == "stream" {
+				return Analyze(s.Data(), s.ContentType, s.StreamDuration())
+			}
+			return Analyze(s.Data(), s.ContentType, s.FileLength)
+		}),
+	)
+
+	c := client.NewCochlSense("mock", "http://mock.invalid", version)
+	c.Client.SetTransport(handlerTransport{handler: backend})
+	return c
+}
+
+// handlerTransport is an http.RoundTripper that serves requests with an
+// http.Handler instead of sending them over the network.
+type handlerTransport struct {
+	handler http.Handler
+}
+
+func (t handlerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rec := httptest.NewRecorder()
+	t.handler.ServeHTTP(rec, req)
+	resp := rec.Result()
+	resp.Request = req
+	return resp, nil
+}
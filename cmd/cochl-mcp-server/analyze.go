@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"syscall"
+	"text/tabwriter"
+
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/cochlearai/cochl-mcp-server/client"
+	"github.com/cochlearai/cochl-mcp-server/common"
+	"github.com/cochlearai/cochl-mcp-server/tools"
+)
+
+// Exit codes of the analyze command.
+const (
+	exitOK = 0
+	// exitFailed means at least one file could not be analyzed.
+	exitFailed = 1
+	// exitUsage means invalid flags or configuration; nothing was analyzed.
+	exitUsage = 2
+)
+
+// fileAnalysis is the outcome of analyzing one file from the command line.
+type fileAnalysis struct {
+	File    string                   `json:"file"`
+	Results []client.InferenceResult `json:"results,omitempty"`
+	Error   string                   `json:"error,omitempty"`
+}
+
+// outputFormats write the analyses of the analyze command. minProbability
+// selects the tags listed in a summary.
+var outputFormats = map[string]func(w io.Writer, analyses []fileAnalysis, minProbability float64) error{
+	"json":    writeJSON,
+	"table":   writeTable,
+	"csv":     writeCSV,
+	"summary": writeSummary,
+}
+
+// analyze runs the analyze command: each file is analyzed with the
+// analyze_audio tool, with the credentials in the environment, and the
+// results are written to stdout. It returns the exit code.
+func analyze(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("analyze", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	output := fs.String("output", "json", "output format: json, table, csv or summary")
+	fs.StringVar(output, "o", "json", "output format: json, table, csv or summary")
+	minProbability := fs.Float64("min-probability", 0.5, "minimum probability of the tags listed by -output summary")
+	mock := fs.Bool("mock", false, "use an embedded offline Cochl Sense backend instead of the API")
+	logLevel := fs.String("log-level", "warn", "log level (debug, info, warn, error)")
+	configFile := fs.String("config", "", "YAML configuration file providing the Cochl Sense base URL and project key")
+	profile := fs.String("profile", "", "profile of the configuration file")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s analyze [flags] <file>...\n\nAnalyze audio files with Cochl Sense. Flags:\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+
+	write, ok := outputFormats[*output]
+	if !ok {
+		fmt.Fprintf(stderr, "invalid output format %q, expected json, table, csv or summary\n", *output)
+		return exitUsage
+	}
+	if fs.NArg() == 0 {
+		fmt.Fprintln(stderr, "no audio files given")
+		fs.Usage()
+		return exitUsage
+	}
+
+	slog.SetDefault(slog.New(slog.NewTextHandler(stderr, &slog.HandlerOptions{
+		Level: parseLogLevel(*logLevel),
+	})))
+	common.SetMockMode(*mock)
+	if err := loadCredentials(*configFile, *profile); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+	if common.ProjectKeyFromEnv() == "" && !common.MockMode() {
+		fmt.Fprintf(stderr, "%v: set the COCHL_SENSE_PROJECT_KEY environment variable, project-key in the configuration file, or use -mock\n", common.ErrMissingProjectKey)
+		return exitUsage
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx = common.ExtractCochlSenseApiClientFromEnv(ctx)
+
+	_, handler := tools.Sense()
+	code := exitOK
+	var analyses []fileAnalysis
+	for _, file := range fs.Args() {
+		a := analyzeFile(ctx, handler, file)
+		if a.Error != "" {
+			fmt.Fprintf(stderr, "%s: %s\n", file, a.Error)
+			code = exitFailed
+		}
+		analyses = append(analyses, a)
+	}
+
+	if err := write(stdout, analyses, *minProbability); err != nil {
+		fmt.Fprintf(stderr, "failed to write results: %v\n", err)
+		return exitFailed
+	}
+	return code
+}
+
+// analyzeFile analyzes the file at path, which may be relative, with the
+// analyze_audio tool handler.
+func analyzeFile(ctx context.Context, handler func(context.Context, mcp.CallToolRequest) (*mcp.CallToolResult, error), path string) fileAnalysis {
+	a := fileAnalysis{File: path}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		a.Error = err.Error()
+		return a
+	}
+
+	var request mcp.CallToolRequest
+	request.Params.Name = "analyze_audio"
+	request.Params.Arguments = map[string]any{"file_absolute_path": abs}
+	result, err := handler(ctx, request)
+	if err != nil {
+		a.Error = err.Error()
+		return a
+	}
+
+	text, _ := result.Content[0].(mcp.TextContent)
+	if result.IsError {
+		a.Error = text.Text
+		return a
+	}
+	if err := json.Unmarshal([]byte(text.Text), &a.Results); err != nil {
+		a.Error = fmt.Sprintf("failed to decode results: %v", err)
+	}
+	return a
+}
+
+func writeJSON(w io.Writer, analyses []fileAnalysis, _ float64) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(analyses)
+}
+
+func writeTable(w io.Writer, analyses []fileAnalysis, _ float64) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "FILE\tSTART\tEND\tTAG\tPROBABILITY")
+	for _, a := range analyses {
+		for _, r := range a.Results {
+			for _, tag := range r.Tags {
+				fmt.Fprintf(tw, "%s\t%.1fs\t%.1fs\t%s\t%.3f\n",
+					a.File, float64(r.StartTime)/1000, float64(r.EndTime)/1000, tag.Name, tag.Probability)
+			}
+		}
+	}
+	return tw.Flush()
+}
+
+func writeCSV(w io.Writer, analyses []fileAnalysis, _ float64) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"file", "start_time", "end_time", "tag", "probability"})
+	for _, a := range analyses {
+		for _, r := range a.Results {
+			for _, tag := range r.Tags {
+				cw.Write([]string{
+					a.File,
+					strconv.Itoa(r.StartTime),
+					strconv.Itoa(r.EndTime),
+					tag.Name,
+					strconv.FormatFloat(tag.Probability, 'f', -1, 64),
+				})
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// tagSummary is how long a tag was detected in a file.
+type tagSummary struct {
+	name           string
+	durationMs     int
+	maxProbability float64
+}
+
+// writeSummary lists, for each file, the tags detected with at least
+// minProbability, longest first. Overlapping segments are counted once.
+func writeSummary(w io.Writer, analyses []fileAnalysis, minProbability float64) error {
+	for i, a := range analyses {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		if a.Error != "" {
+			fmt.Fprintf(w, "%s: failed: %s\n", a.File, a.Error)
+			continue
+		}
+		fmt.Fprintf(w, "%s: %d segments\n", a.File, len(a.Results))
+
+		tags := summarizeTags(a.Results, minProbability)
+		if len(tags) == 0 {
+			fmt.Fprintf(w, "  no tags with probability of at least %g\n", minProbability)
+			continue
+		}
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		for _, t := range tags {
+			fmt.Fprintf(tw, "  %s\t%.1fs\tmax %.3f\n", t.name, float64(t.durationMs)/1000, t.maxProbability)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func summarizeTags(results []client.InferenceResult, minProbability float64) []tagSummary {
+	spans := make(map[string][][2]int)
+	maxProbability := make(map[string]float64)
+	for _, r := range results {
+		for _, tag := range r.Tags {
+			if tag.Probability < minProbability {
+				continue
+			}
+			spans[tag.Name] = append(spans[tag.Name], [2]int{r.StartTime, r.EndTime})
+			maxProbability[tag.Name] = max(maxProbability[tag.Name], tag.Probability)
+		}
+	}
+
+	var tags []tagSummary
+	for name, s := range spans {
+		slices.SortFunc(s, func(a, b [2]int) int { return a[0] - b[0] })
+		duration, end := 0, 0
+		for _, span := range s {
+			start := max(span[0], end)
+			if span[1] > start {
+				duration += span[1] - start
+				end = span[1]
+			}
+		}
+		tags = append(tags, tagSummary{name: name, durationMs: duration, maxProbability: maxProbability[name]})
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].durationMs != tags[j].durationMs {
+			return tags[i].durationMs > tags[j].durationMs
+		}
+		return tags[i].name < tags[j].name
+	})
+	return tags
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cochlearai/cochl-mcp-server/client"
+	"github.com/cochlearai/cochl-mcp-server/client/fakesense"
+)
+
+var analyzeResults = []client.InferenceResult{
+	{StartTime: 0, EndTime: 1000, Tags: []client.Tags{{Name: "Dog_bark", Probability: 0.9}}},
+	{StartTime: 500, EndTime: 1500, Tags: []client.Tags{{Name: "Dog_bark", Probability: 0.7}, {Name: "Siren", Probability: 0.3}}},
+}
+
+func TestAnalyzeCommand(t *testing.T) {
+	fake := fakesense.NewServer(fakesense.WithAPIKey("cli-key"), fakesense.WithResults(analyzeResults...))
+	defer fake.Close()
+	t.Setenv("COCHL_SENSE_BASE_URL", fake.URL)
+	t.Setenv("COCHL_SENSE_PROJECT_KEY", "cli-key")
+
+	wav := filepath.Join("..", "..", "util", "audio", "testdata", "wav-test.wav")
+	missing := filepath.Join("..", "..", "util", "audio", "testdata", "missing.wav")
+
+	tests := []struct {
+		name       string
+		args       []string
+		wantCode   int
+		wantOutput string
+		wantStderr string
+	}{
+		{
+			name:       "CSV",
+			args:       []string{"-output", "csv", wav},
+			wantCode:   exitOK,
+			wantOutput: "file,start_time,end_time,tag,probability\n" + wav + ",0,1000,Dog_bark,0.9\n" + wav + ",500,1500,Dog_bark,0.7\n" + wav + ",500,1500,Siren,0.3\n",
+		},
+		{
+			name:       "Table",
+			args:       []string{"-o", "table", wav},
+			wantCode:   exitOK,
+			wantOutput: "0.5s   1.5s  Siren     0.300\n",
+		},
+		{
+			name:       "Summary merges overlapping segments",
+			args:       []string{"-output", "summary", wav},
+			wantCode:   exitOK,
+			wantOutput: wav + ": 2 segments\n  Dog_bark  1.5s  max 0.900\n",
+		},
+		{
+			name:       "Failed file",
+			args:       []string{"-output", "summary", missing, wav},
+			wantCode:   exitFailed,
+			wantOutput: missing + ": failed: file not found",
+			wantStderr: missing + ": file not found",
+		},
+		{
+			name:       "No files",
+			args:       []string{"-output", "csv"},
+			wantCode:   exitUsage,
+			wantStderr: "no audio files given",
+		},
+		{
+			name:       "Unknown output format",
+			args:       []string{"-output", "xml", wav},
+			wantCode:   exitUsage,
+			wantStderr: "invalid output format",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			if code := analyze(tt.args, &stdout, &stderr); code != tt.wantCode {
+				t.Errorf("got exit code %d, want %d (stderr: %s)", code, tt.wantCode, stderr.String())
+			}
+			if !strings.Contains(stdout.String(), tt.wantOutput) {
+				t.Errorf("got output %q, want it to contain %q", stdout.String(), tt.wantOutput)
+			}
+			if !strings.Contains(stderr.String(), tt.wantStderr) {
+				t.Errorf("got stderr %q, want it to contain %q", stderr.String(), tt.wantStderr)
+			}
+		})
+	}
+}
+
+func TestAnalyzeCommandJSON(t *testing.T) {
+	fake := fakesense.NewServer(fakesense.WithResults(analyzeResults...))
+	defer fake.Close()
+	t.Setenv("COCHL_SENSE_BASE_URL", fake.URL)
+	t.Setenv("COCHL_SENSE_PROJECT_KEY", "cli-key")
+
+	wav := filepath.Join("..", "..", "util", "audio", "testdata", "wav-test.wav")
+	var stdout, stderr bytes.Buffer
+	if code := analyze([]string{wav}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("got exit code %d: %s", code, stderr.String())
+	}
+
+	var got []fileAnalysis
+	if err := json.Unmarshal(stdout.Bytes(), &got); err != nil {
+		t.Fatalf("failed to decode output: %v", err)
+	}
+	want := []fileAnalysis{{File: wav, Results: analyzeResults}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestAnalyzeCommandRequiresProjectKey(t *testing.T) {
+	t.Setenv("COCHL_SENSE_PROJECT_KEY", "")
+
+	var stdout, stderr bytes.Buffer
+	if code := analyze([]string{"audio.wav"}, &stdout, &stderr); code != exitUsage {
+		t.Errorf("got exit code %d, want %d", code, exitUsage)
+	}
+	if !strings.Contains(stderr.String(), "COCHL_SENSE_PROJECT_KEY") {
+		t.Errorf("expected a hint about the project key, got %q", stderr.String())
+	}
+}
+
+func TestCommands(t *testing.T) {
+	run := func(args ...string) (string, int) {
+		cmd := exec.Command(os.Args[0], args...)
+		cmd.Env = append(os.Environ(), testMainEnvVar+"=1")
+		out, _ := cmd.CombinedOutput()
+		return string(out), cmd.ProcessState.ExitCode()
+	}
+
+	if out, code := run("version"); code != 0 || !strings.HasPrefix(out, "cochl-mcp-server ") {
+		t.Errorf("version: got %q with exit code %d", out, code)
+	}
+	if out, code := run("frobnicate"); code != exitUsage || !strings.Contains(out, `unknown command "frobnicate"`) {
+		t.Errorf("unknown command: got %q with exit code %d", out, code)
+	}
+	if out, code := run("analyze"); code != exitUsage || !strings.Contains(out, "Usage:") {
+		t.Errorf("analyze without files: got %q with exit code %d", out, code)
+	}
+}
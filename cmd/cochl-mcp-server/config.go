@@ -0,0 +1,237 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/cochlearai/cochl-mcp-server/common"
+	"github.com/cochlearai/cochl-mcp-server/config"
+	"github.com/cochlearai/cochl-mcp-server/poll"
+	"github.com/cochlearai/cochl-mcp-server/throttle"
+	"github.com/cochlearai/cochl-mcp-server/tracing"
+	usagemeter "github.com/cochlearai/cochl-mcp-server/usage"
+	"github.com/cochlearai/cochl-mcp-server/util/audio"
+)
+
+// loadServeConfig reads the configuration file selected by f, sets the flags
+// of fs it names that were not given on the command line and validates the
+// result. The file also provides the Cochl Sense credentials the environment
+// does not set.
+func loadServeConfig(fs *flag.FlagSet, f *serveFlags) (*config.Config, error) {
+	c, err := config.Load(f.configFile, f.profile)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.Apply(fs); err != nil {
+		return nil, err
+	}
+	common.SetConfigDefaults(c.Value(config.BaseURL), c.Value(config.ProjectKey))
+	if err := f.validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// loadCredentials reads only the Cochl Sense credentials from the
+// configuration file, for the commands whose flags the file does not set.
+func loadCredentials(path, profile string) error {
+	c, err := config.Load(path, profile)
+	if err != nil {
+		return err
+	}
+	common.SetConfigDefaults(c.Value(config.BaseURL), c.Value(config.ProjectKey))
+	return nil
+}
+
+// validate reports every flag with a value serve would reject.
+func (f *serveFlags) validate() error {
+	var errs []error
+	switch f.transport {
+	case "stdio", "sse", "http":
+	default:
+		errs = append(errs, fmt.Errorf("invalid transport %q, expected stdio, sse or http", f.transport))
+	}
+	switch f.credentials {
+	case "client", "server":
+	default:
+		errs = append(errs, fmt.Errorf("invalid credentials mode %q, expected client or server", f.credentials))
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(f.logLevel)); err != nil {
+		errs = append(errs, fmt.Errorf("invalid log level %q, expected debug, info, warn or error", f.logLevel))
+	}
+	switch f.traceExporter {
+	case tracing.ExporterNone, tracing.ExporterOTLP, tracing.ExporterFile:
+	default:
+		errs = append(errs, fmt.Errorf("invalid trace exporter %q, expected none, otlp or file", f.traceExporter))
+	}
+	if f.audioMaxSizeMB < 0 || f.audioMinDuration < 0 || f.audioMaxDuration < 0 {
+		errs = append(errs, fmt.Errorf("invalid audio limits: sizes and durations must not be negative"))
+	} else if f.audioMaxDuration > 0 && f.audioMinDuration > f.audioMaxDuration {
+		errs = append(errs, fmt.Errorf("invalid audio limits: -audio-min-duration %v exceeds -audio-max-duration %v",
+			f.audioMinDuration, f.audioMaxDuration))
+	}
+	if _, err := audio.ParseInts(f.audioSampleRates); err != nil {
+		errs = append(errs, fmt.Errorf("invalid -audio-sample-rates: %w", err))
+	}
+	if _, err := audio.ParseInts(f.audioChannels); err != nil {
+		errs = append(errs, fmt.Errorf("invalid -audio-channels: %w", err))
+	}
+	if f.rateLimit < 0 {
+		errs = append(errs, fmt.Errorf("invalid -rate-limit: must not be negative"))
+	}
+	if f.rateBurst < 1 {
+		errs = append(errs, fmt.Errorf("invalid -rate-burst: must be at least 1"))
+	}
+	if f.maxConcurrentSessions < 0 {
+		errs = append(errs, fmt.Errorf("invalid -max-concurrent-sessions: must not be negative"))
+	}
+	limits := []struct {
+		name  string
+		value int64
+	}{
+		{"usage-daily-audio-limit", int64(f.usageDailyAudio)},
+		{"usage-monthly-audio-limit", int64(f.usageMonthlyAudio)},
+		{"usage-daily-upload-limit-mb", f.usageDailyUploadMB},
+		{"usage-monthly-upload-limit-mb", f.usageMonthlyUploadMB},
+	}
+	for _, l := range limits {
+		if l.value < 0 {
+			errs = append(errs, fmt.Errorf("invalid -%s: must not be negative", l.name))
+		}
+	}
+	if err := f.polling().Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("invalid polling configuration: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
+func (f *serveFlags) polling() poll.Strategy {
+	return poll.Strategy{
+		InitialDelay:        f.pollInitialDelay,
+		DelayPerAudioSecond: f.pollDelayPerAudioSecond,
+		Multiplier:          f.pollMultiplier,
+		MaxDelay:            f.pollMaxDelay,
+		MaxWait:             f.pollMaxWait,
+		Clock:               poll.SystemClock,
+	}
+}
+
+// audioLimits returns the limits audio files must meet to be analyzed. The
+// lists were checked by validate.
+func (f *serveFlags) audioLimits() audio.Limits {
+	sampleRates, _ := audio.ParseInts(f.audioSampleRates)
+	channels, _ := audio.ParseInts(f.audioChannels)
+	return audio.Limits{
+		MaxSize:     f.audioMaxSizeMB << 20,
+		MinDuration: f.audioMinDuration.Seconds(),
+		MaxDuration: f.audioMaxDuration.Seconds(),
+		SampleRates: sampleRates,
+		Channels:    channels,
+	}
+}
+
+// throttle returns the limits of the requests of each project key.
+func (f *serveFlags) throttle() throttle.Config {
+	return throttle.Config{
+		Rate:        f.rateLimit,
+		Burst:       f.rateBurst,
+		MaxSessions: f.maxConcurrentSessions,
+	}
+}
+
+// usageLimits returns the limits enforced for each project key.
+func (f *serveFlags) usageLimits() usagemeter.Limits {
+	const mb = 1 << 20
+	return usagemeter.Limits{
+		DailySeconds:   f.usageDailyAudio.Seconds(),
+		MonthlySeconds: f.usageMonthlyAudio.Seconds(),
+		DailyBytes:     f.usageDailyUploadMB * mb,
+		MonthlyBytes:   f.usageMonthlyUploadMB * mb,
+	}
+}
+
+// configCommand runs the config command, which validates or shows the serve
+// configuration resulting from the file, the environment and the given serve
+// flags. It returns the exit code.
+func configCommand(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 || (args[0] != "validate" && args[0] != "show") {
+		fmt.Fprintf(stderr, "Usage: %s config validate|show [serve flags]\n\n"+
+			"Validate or show the configuration of serve, with secrets redacted.\n", os.Args[0])
+		return exitUsage
+	}
+	action := args[0]
+
+	fs, f := newServeFlags(flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	if err := fs.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+	explicit := make(map[string]bool)
+	fs.Visit(func(fl *flag.Flag) { explicit[fl.Name] = true })
+
+	c, err := loadServeConfig(fs, f)
+	if err != nil {
+		fmt.Fprintf(stderr, "invalid configuration:\n%v\n", err)
+		return exitFailed
+	}
+
+	source := "no configuration file"
+	if c.Path != "" {
+		source = c.Path
+		if c.Profile != "" {
+			source += ", profile " + c.Profile
+		}
+	}
+	if action == "validate" {
+		fmt.Fprintf(stdout, "configuration OK (%s)\n", source)
+		return exitOK
+	}
+
+	fmt.Fprintf(stdout, "# %s\n", source)
+	showSetting(stdout, c, config.BaseURL, common.BaseURL(), "COCHL_SENSE_BASE_URL", "default")
+	showSetting(stdout, c, config.ProjectKey, common.ProjectKeyFromEnv(), "COCHL_SENSE_PROJECT_KEY", "not set")
+	fs.VisitAll(func(fl *flag.Flag) {
+		// -t is a shorthand of -transport.
+		if fl.Name == "t" || fl.Name == "config" || fl.Name == "profile" {
+			return
+		}
+		origin := "default"
+		if explicit[fl.Name] {
+			origin = "flag"
+		} else if s, ok := c.Lookup(fl.Name); ok {
+			origin = s.Source
+		}
+		fmt.Fprintf(stdout, "%s: %s  # %s\n", fl.Name, showValue(fl.Name, fl.Value.String()), origin)
+	})
+	return exitOK
+}
+
+// showSetting prints a Cochl Sense setting, which the environment variable
+// envVar overrides.
+func showSetting(w io.Writer, c *config.Config, name, value, envVar, fallback string) {
+	origin := fallback
+	if os.Getenv(envVar) != "" {
+		origin = envVar
+	} else if s, ok := c.Lookup(name); ok {
+		origin = s.Source
+	}
+	fmt.Fprintf(w, "%s: %s  # %s\n", name, showValue(name, value), origin)
+}
+
+func showValue(name, value string) string {
+	if config.Secret(name) {
+		value = config.Redact(value)
+	}
+	if value == "" {
+		return `""`
+	}
+	return value
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cochlearai/cochl-mcp-server/common"
+)
+
+func TestConfigCommand(t *testing.T) {
+	t.Cleanup(func() { common.SetConfigDefaults("", "") })
+	t.Setenv("COCHL_SENSE_BASE_URL", "")
+	t.Setenv("COCHL_SENSE_PROJECT_KEY", "")
+	t.Setenv("COCHL_MCP_PROFILE", "")
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(path, []byte(`
+transport: sse
+log-level: debug
+profiles:
+  staging:
+    base-url: https://staging.example.com
+    project-key: staging-project-key
+    sse-port: 9000
+`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("COCHL_MCP_CONFIG", path)
+
+	var stdout, stderr bytes.Buffer
+	if code := configCommand([]string{"validate", "-profile", "staging"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("validate: got exit code %d, stderr:\n%s", code, stderr.String())
+	}
+	if want := "configuration OK (" + path + ", profile staging)\n"; stdout.String() != want {
+		t.Errorf("validate: got %q, want %q", stdout.String(), want)
+	}
+
+	// Flags take precedence over the environment, which takes precedence
+	// over the file.
+	t.Setenv("COCHL_SENSE_PROJECT_KEY", "environment-project-key")
+	stdout.Reset()
+	if code := configCommand([]string{"show", "-profile", "staging", "-log-level", "warn"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("show: got exit code %d, stderr:\n%s", code, stderr.String())
+	}
+	for _, want := range []string{
+		"# " + path + ", profile staging\n",
+		"base-url: https://staging.example.com  # profile staging\n",
+		"project-key: ****-key  # COCHL_SENSE_PROJECT_KEY\n",
+		"transport: sse  # config file\n",
+		"sse-port: 9000  # profile staging\n",
+		"log-level: warn  # flag\n",
+		"cache-size: 100  # default\n",
+		`bind-addr: ""  # default` + "\n",
+	} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("show output does not contain %q:\n%s", want, stdout.String())
+		}
+	}
+	if strings.Contains(stdout.String(), "staging-project-key") || strings.Contains(stdout.String(), "environment-project-key") {
+		t.Errorf("show output leaks the project key:\n%s", stdout.String())
+	}
+
+	if err := os.WriteFile(path, []byte("transport: ssh\nsse_port: 9000\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	stderr.Reset()
+	if code := configCommand([]string{"validate"}, &stdout, &stderr); code != exitFailed {
+		t.Errorf("validate: got exit code %d for an invalid file, want %d", code, exitFailed)
+	}
+	if !strings.Contains(stderr.String(), `config.yaml:2: unknown setting "sse_port", did you mean "sse-port"?`) {
+		t.Errorf("validate: unexpected errors:\n%s", stderr.String())
+	}
+
+	if err := os.WriteFile(path, []byte("transport: ssh\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	stderr.Reset()
+	if code := configCommand([]string{"validate"}, &stdout, &stderr); code != exitFailed || !strings.Contains(stderr.String(), `invalid transport "ssh", expected stdio, sse or http`) {
+		t.Errorf("validate: got exit code %d, stderr:\n%s", code, stderr.String())
+	}
+	if err := os.WriteFile(path, []byte("audio-sample-rates: 16000, 44.1k\naudio-min-duration: 1m\naudio-max-duration: 30s\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	stderr.Reset()
+	if code := configCommand([]string{"validate"}, &stdout, &stderr); code != exitFailed {
+		t.Errorf("validate: got exit code %d for invalid audio limits, want %d", code, exitFailed)
+	}
+	for _, want := range []string{
+		`invalid -audio-sample-rates: invalid number "44.1k"`,
+		"-audio-min-duration 1m0s exceeds -audio-max-duration 30s",
+	} {
+		if !strings.Contains(stderr.String(), want) {
+			t.Errorf("validate: errors do not contain %q:\n%s", want, stderr.String())
+		}
+	}
+}
@@ -2,69 +2,628 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log/slog"
+	"net"
+	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/mark3labs/mcp-go/server"
 
+	"github.com/cochlearai/cochl-mcp-server/cache"
 	"github.com/cochlearai/cochl-mcp-server/common"
+	"github.com/cochlearai/cochl-mcp-server/history"
+	"github.com/cochlearai/cochl-mcp-server/metrics"
+	"github.com/cochlearai/cochl-mcp-server/poll"
+	"github.com/cochlearai/cochl-mcp-server/prompts"
+	"github.com/cochlearai/cochl-mcp-server/resources"
+	"github.com/cochlearai/cochl-mcp-server/taxonomy"
+	"github.com/cochlearai/cochl-mcp-server/throttle"
 	"github.com/cochlearai/cochl-mcp-server/tools"
+	"github.com/cochlearai/cochl-mcp-server/tracing"
+	"github.com/cochlearai/cochl-mcp-server/transport"
+	usagemeter "github.com/cochlearai/cochl-mcp-server/usage"
+	"github.com/cochlearai/cochl-mcp-server/util/audio"
 )
 
-func newServer() *server.MCPServer {
+// authTokenEnvVar holds a client token accepted in addition to those in
+// -auth-tokens-file.
+const authTokenEnvVar = "COCHL_MCP_AUTH_TOKEN"
+
+// serverConfig holds the optional components shared by every session.
+type serverConfig struct {
+	cache    *cache.Cache
+	history  *history.Store
+	inflight *tools.Inflight
+	polling  *poll.Strategy
+	limits   *audio.Limits
+}
+
+func newServer(cfg serverConfig) *server.MCPServer {
+	sessions := common.NewSessions()
+	hooks := &server.Hooks{}
+	hooks.AddOnRegisterSession(sessions.Register)
+	hooks.AddBeforeAny(sessions.Identify)
+
+	var senseOpts []tools.SenseOption
+	if cfg.inflight != nil {
+		senseOpts = append(senseOpts, tools.WithInflight(cfg.inflight))
+	}
+	if cfg.polling != nil {
+		senseOpts = append(senseOpts, tools.WithPolling(*cfg.polling))
+	}
+	if cfg.limits != nil {
+		senseOpts = append(senseOpts, tools.WithAudioLimits(*cfg.limits))
+	}
+	if cfg.cache != nil {
+		senseOpts = append(senseOpts, tools.WithCache(cfg.cache))
+	}
+	if cfg.history != nil {
+		senseOpts = append(senseOpts, tools.WithHistory(cfg.history))
+		hooks.AddAfterListResources(resources.ListAnalyses(cfg.history))
+		cfg.history.OnAdd(resources.NotifyAnalysisAdded(sessions))
+	}
+
 	s := server.NewMCPServer(
 		"mcp-cochl",
 		common.Version,
-		server.WithResourceCapabilities(true, true),
+		// mcp-go does not route resources/subscribe, so subscriptions are not
+		// offered; clients learn of new analyses from list_changed.
+		server.WithResourceCapabilities(false, true),
+		server.WithPromptCapabilities(false),
 		server.WithLogging(),
+		server.WithHooks(hooks),
 	)
 
-	s.AddTool(tools.Sense())
+	s.AddTool(tools.Instrument(tools.Sense(senseOpts...)))
+	s.AddTool(tools.Instrument(tools.FindSound(senseOpts...)))
+	s.AddTool(tools.Instrument(tools.CompareAudio(senseOpts...)))
+	s.AddTool(tools.Instrument(tools.AnalyzeStream(senseOpts...)))
+	s.AddTool(tools.Instrument(tools.ListSoundTags()))
+	s.AddTool(tools.Instrument(tools.SearchSoundTags()))
+	s.AddTool(tools.Instrument(tools.CheckConnection()))
+	s.AddTool(tools.Instrument(tools.GetUsage()))
+
+	s.AddResource(resources.Tags(taxonomy.Default()))
+	s.AddResource(resources.Usage())
+
+	prompts.Register(s)
+
+	if cfg.history != nil {
+		s.AddResource(resources.Analyses(cfg.history))
+		s.AddResourceTemplate(resources.Analysis(cfg.history))
+	}
 
 	return s
 }
 
-func run(transport, port string) error {
-	s := newServer()
+// listenConfig configures the listener of the sse and http transports.
+type listenConfig struct {
+	addr   string
+	tokens transport.Tokens
+	tls    transport.TLSFiles
+	// contextFunc attaches a Cochl Sense client to each request.
+	contextFunc server.SSEContextFunc
+	// readyChecks are run by /readyz in addition to the shutdown check.
+	readyChecks []transport.ReadinessCheck
+}
+
+// httpShutdownTimeout bounds how long closing the connections of the sse and
+// http transports may take once running analyses are drained.
+const httpShutdownTimeout = 5 * time.Second
 
-	switch transport {
+// run serves s until ctx is done, then calls drain to let running analyses
+// finish before closing the transport.
+func run(ctx context.Context, s *server.MCPServer, transportName string, lc listenConfig, drain func()) error {
+	switch transportName {
 	case "sse":
 		srv := server.NewSSEServer(s,
-			server.WithSSEContextFunc(common.SSEContextFunc),
+			server.WithSSEContextFunc(lc.contextFunc),
+		)
+		return serveHTTP(ctx, "sse", srv, lc, drain)
+
+	case "http":
+		srv := transport.NewStreamableHTTPServer(s,
+			transport.WithHTTPContextFunc(lc.contextFunc),
+			transport.WithSessionOwner(common.CallerID),
 		)
-		slog.Info("Starting Cochl MCP server using sse transport", "port", port)
-		return srv.Start(":" + port)
+		return serveHTTP(ctx, "streamable http", srv, lc, func() {
+			drain()
+			srv.Shutdown(context.Background())
+		})
 
 	case "stdio":
 		srv := server.NewStdioServer(s)
 		srv.SetContextFunc(common.ExtractCochlSenseApiClientFromEnv)
 		slog.Info("Starting Cochl MCP server using stdio transport")
-		return srv.Listen(context.Background(), os.Stdin, os.Stdout)
+
+		// Listen gets its own context so that canceling ctx does not abort
+		// the tool call being processed.
+		listenCtx, stop := context.WithCancel(context.Background())
+		defer stop()
+		errc := make(chan error, 1)
+		go func() {
+			errc <- srv.Listen(listenCtx, os.Stdin, os.Stdout)
+		}()
+
+		select {
+		case err := <-errc:
+			return err
+		case <-ctx.Done():
+		}
+		slog.Info("Shutting down Cochl MCP server")
+		drain()
+		stop()
+		if err := <-errc; !errors.Is(err, context.Canceled) {
+			return err
+		}
+		return nil
 
 	default:
-		return fmt.Errorf("invalid transport: %s", transport)
+		return fmt.Errorf("invalid transport: %s", transportName)
 	}
 
 }
 
+// serveHTTP serves handler until ctx is done. It then calls stop while still
+// serving, so running tool calls can deliver their results, and closes every
+// connection afterwards.
+func serveHTTP(ctx context.Context, name string, handler http.Handler, lc listenConfig, stop func()) error {
+	// Probes and scrapers do not authenticate, so the health and metrics
+	// endpoints bypass client authentication.
+	mux := http.NewServeMux()
+	mux.Handle("/", tracing.ExtractHTTP(transport.Authenticate(handler, lc.tokens)))
+	mux.Handle("/healthz", transport.HealthHandler())
+	mux.Handle("/readyz", transport.ReadyHandler(append(lc.readyChecks, transport.ShutdownCheck(ctx))...))
+	mux.Handle("/metrics", metrics.Default.Handler())
+
+	srv, err := transport.NewHTTPServer(lc.addr, mux, lc.tls)
+	if err != nil {
+		return err
+	}
+
+	// Event streams never go idle, so they are ended by canceling the
+	// context of every request.
+	streams, closeStreams := context.WithCancel(context.Background())
+	defer closeStreams()
+	srv.BaseContext = func(net.Listener) context.Context { return streams }
+
+	if len(lc.tokens) == 0 && lc.tls.ClientCAFile == "" {
+		slog.Warn("Client authentication is disabled, anyone who can reach the server can use it",
+			"addr", lc.addr)
+	}
+	slog.Info("Starting Cochl MCP server using "+name+" transport",
+		"addr", lc.addr, "tls", srv.TLSConfig != nil, "mtls", lc.tls.ClientCAFile != "")
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- transport.ListenAndServe(srv)
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+	slog.Info("Shutting down Cochl MCP server", "addr", lc.addr)
+	stop()
+	closeStreams()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), httpShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		srv.Close()
+		return fmt.Errorf("failed to close connections: %w", err)
+	}
+	return <-errc
+}
+
+// loadTokens combines the tokens in file with the single token in the
+// COCHL_MCP_AUTH_TOKEN environment variable.
+func loadTokens(file string) (transport.Tokens, error) {
+	tokens := make(transport.Tokens)
+	if file != "" {
+		var err error
+		if tokens, err = transport.LoadTokens(file); err != nil {
+			return nil, err
+		}
+	}
+	if token := os.Getenv(authTokenEnvVar); token != "" {
+		tokens[token] = "env"
+	}
+	return tokens, nil
+}
+
 func main() {
-	var transport string
-	flag.StringVar(&transport, "transport", "stdio", "transport (stdio or sse)")
-	flag.StringVar(&transport, "t", "stdio", "transport (stdio or sse)")
-	logLevel := flag.String("log-level", "info", "log level (debug, info, warn, error)")
-	port := flag.String("sse-port", "8080", "port to listen on (required for sse transport)")
-	flag.Parse()
+	command, args := "serve", os.Args[1:]
+	// Without a command the arguments are serve flags, as they were before
+	// there were commands.
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+
+	switch command {
+	case "serve":
+		serve(args)
+	case "analyze":
+		os.Exit(analyze(args, os.Stdout, os.Stderr))
+	case "watch":
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		code := watchDir(ctx, args, os.Stderr)
+		stop()
+		os.Exit(code)
+	case "stream":
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		code := streamCommand(ctx, args, os.Stdout, os.Stderr)
+		stop()
+		os.Exit(code)
+	case "config":
+		os.Exit(configCommand(args, os.Stdout, os.Stderr))
+	case "version":
+		fmt.Printf("cochl-mcp-server %s\n", common.Version)
+	case "help":
+		usage(os.Stdout)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", command)
+		usage(os.Stderr)
+		os.Exit(exitUsage)
+	}
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintf(w, `Usage: %s <command> [flags]
+
+Commands:
+  serve      serve MCP clients (the default when no command is given)
+  analyze    analyze audio files and print the results
+  watch      analyze the recordings written to a directory
+  stream     analyze live audio and print the detections
+  config     validate or show the configuration
+  version    print the version
+
+Run "%[1]s <command> -h" for the flags of a command.
+`, os.Args[0])
+}
+
+// serveFlags holds the flags of the serve command, which the configuration
+// file can also set.
+type serveFlags struct {
+	configFile              string
+	profile                 string
+	transport               string
+	logLevel                string
+	port                    string
+	bindAddr                string
+	authTokensFile          string
+	tlsCert                 string
+	tlsKey                  string
+	tlsClientCA             string
+	readyCheckAPI           bool
+	startupCheck            bool
+	credentials             string
+	allowClientBaseURL      bool
+	projectKeyFile          string
+	projectKeysFile         string
+	mock                    bool
+	cacheSize               int
+	cacheTTL                time.Duration
+	diskCache               bool
+	cacheDir                string
+	cacheMaxDiskMB          int64
+	historySize             int
+	historyPersist          bool
+	historyDir              string
+	audioMaxSizeMB          int64
+	audioMinDuration        time.Duration
+	audioMaxDuration        time.Duration
+	audioSampleRates        string
+	audioChannels           string
+	rateLimit               float64
+	rateBurst               int
+	maxConcurrentSessions   int
+	usageFile               string
+	usageDailyAudio         time.Duration
+	usageMonthlyAudio       time.Duration
+	usageDailyUploadMB      int64
+	usageMonthlyUploadMB    int64
+	pollInitialDelay        time.Duration
+	pollDelayPerAudioSecond time.Duration
+	pollMultiplier          float64
+	pollMaxDelay            time.Duration
+	pollMaxWait             time.Duration
+	traceExporter           string
+	traceFile               string
+	shutdownTimeout         time.Duration
+}
+
+// newServeFlags defines the flags of the serve command.
+func newServeFlags(errorHandling flag.ErrorHandling) (*flag.FlagSet, *serveFlags) {
+	f := &serveFlags{}
+	fs := flag.NewFlagSet("serve", errorHandling)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s [serve] [flags]\n\nServe MCP clients. Flags:\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&f.configFile, "config", "", "YAML configuration file (default: $COCHL_MCP_CONFIG, or config.yaml in the cochl-mcp-server user config directory if it exists)")
+	fs.StringVar(&f.profile, "profile", "", "profile of the configuration file (default: $COCHL_MCP_PROFILE, or the profile named in the file)")
+	fs.StringVar(&f.transport, "transport", "stdio", "transport (stdio, sse or http)")
+	fs.StringVar(&f.transport, "t", "stdio", "transport (stdio, sse or http)")
+	fs.StringVar(&f.logLevel, "log-level", "info", "log level (debug, info, warn, error)")
+	fs.StringVar(&f.port, "sse-port", "8080", "port to listen on for the sse and http transports")
+	fs.StringVar(&f.bindAddr, "bind-addr", "", "address to listen on for the sse and http transports (default: all interfaces)")
+	fs.StringVar(&f.authTokensFile, "auth-tokens-file", "", "file of client tokens required by the sse and http transports, one \"[name] token\" per line")
+	fs.StringVar(&f.tlsCert, "tls-cert", "", "TLS certificate file for the sse and http transports")
+	fs.StringVar(&f.tlsKey, "tls-key", "", "TLS private key file for the sse and http transports")
+	fs.StringVar(&f.tlsClientCA, "tls-client-ca", "", "CA file for verifying client certificates (enables mutual TLS)")
+	fs.BoolVar(&f.readyCheckAPI, "ready-check-api", false, "make /readyz of the sse and http transports also require the Cochl Sense API to be reachable")
+	fs.BoolVar(&f.startupCheck, "startup-check", false, "check at startup that the Cochl Sense API is reachable and accepts the project key, logging a warning if not")
+	fs.StringVar(&f.credentials, "credentials", "client", "where the sse and http transports get Cochl Sense project keys: client (X-Api-Key header) or server")
+	fs.BoolVar(&f.allowClientBaseURL, "allow-client-base-url", false, "let clients of the sse and http transports choose the Cochl Sense API with the X-Base-Url header")
+	fs.StringVar(&f.projectKeyFile, "project-key-file", "", "file holding the default project key for -credentials server")
+	fs.StringVar(&f.projectKeysFile, "project-keys-file", "", "file mapping client names to project keys for -credentials server, one \"name key\" per line")
+	fs.BoolVar(&f.mock, "mock", false, "use an embedded offline Cochl Sense backend instead of the API")
+	fs.IntVar(&f.cacheSize, "cache-size", 100, "number of analysis results cached in memory (0 disables caching)")
+	fs.DurationVar(&f.cacheTTL, "cache-ttl", 24*time.Hour, "how long cached analysis results stay valid (0 means forever)")
+	fs.BoolVar(&f.diskCache, "disk-cache", false, "also persist cached analysis results on disk")
+	fs.StringVar(&f.cacheDir, "cache-dir", "", "directory for the on-disk cache (default: user cache directory)")
+	fs.Int64Var(&f.cacheMaxDiskMB, "cache-max-disk-mb", 256, "maximum size of the on-disk cache in megabytes (0 means unlimited)")
+	fs.IntVar(&f.historySize, "history-size", 100, "number of analyses kept in the history (0 disables history)")
+	fs.BoolVar(&f.historyPersist, "history-persist", false, "also persist the analysis history on disk")
+	fs.StringVar(&f.historyDir, "history-dir", "", "directory for the persisted analysis history (default: user cache directory)")
+	fs.Int64Var(&f.audioMaxSizeMB, "audio-max-size-mb", audio.DefaultMaxSize>>20, "largest audio file analyzed, in megabytes (0 means unlimited)")
+	fs.DurationVar(&f.audioMinDuration, "audio-min-duration", 0, "shortest recording analyzed (0 only rejects empty files)")
+	fs.DurationVar(&f.audioMaxDuration, "audio-max-duration", 0, "longest recording analyzed (0 means unlimited)")
+	fs.StringVar(&f.audioSampleRates, "audio-sample-rates", "", "comma-separated sample rates in Hz accepted for analysis (default: any)")
+	fs.StringVar(&f.audioChannels, "audio-channels", "", "comma-separated channel counts accepted for analysis (default: any)")
+	fs.Float64Var(&f.rateLimit, "rate-limit", 0, "Cochl Sense requests per second allowed for each project key, queuing the others (0 means unlimited)")
+	fs.IntVar(&f.rateBurst, "rate-burst", 10, "requests each project key may send at once before -rate-limit applies")
+	fs.IntVar(&f.maxConcurrentSessions, "max-concurrent-sessions", 0, "Cochl Sense sessions each project key may have open at once, queuing the other analyses (0 means unlimited)")
+	fs.StringVar(&f.usageFile, "usage-file", "", "file where the audio submitted per project key is counted (default: user cache directory)")
+	fs.DurationVar(&f.usageDailyAudio, "usage-daily-audio-limit", 0, "audio each project key may submit per UTC day (0 means unlimited)")
+	fs.DurationVar(&f.usageMonthlyAudio, "usage-monthly-audio-limit", 0, "audio each project key may submit per UTC month (0 means unlimited)")
+	fs.Int64Var(&f.usageDailyUploadMB, "usage-daily-upload-limit-mb", 0, "megabytes of audio each project key may upload per UTC day (0 means unlimited)")
+	fs.Int64Var(&f.usageMonthlyUploadMB, "usage-monthly-upload-limit-mb", 0, "megabytes of audio each project key may upload per UTC month (0 means unlimited)")
+	defaultPolling := poll.DefaultStrategy()
+	fs.DurationVar(&f.pollInitialDelay, "poll-initial-delay", defaultPolling.InitialDelay, "delay before the first inference result request")
+	fs.DurationVar(&f.pollDelayPerAudioSecond, "poll-delay-per-audio-second", defaultPolling.DelayPerAudioSecond, "added to the first delay for each second of audio")
+	fs.Float64Var(&f.pollMultiplier, "poll-multiplier", defaultPolling.Multiplier, "growth of the delay after each pending result")
+	fs.DurationVar(&f.pollMaxDelay, "poll-max-delay", defaultPolling.MaxDelay, "maximum delay between inference result requests")
+	fs.DurationVar(&f.pollMaxWait, "poll-max-wait", defaultPolling.MaxWait, "how long to wait for the results of an analysis; 0 waits indefinitely")
+	fs.StringVar(&f.traceExporter, "trace-exporter", "none", "OpenTelemetry trace exporter: none, otlp (configured with OTEL_EXPORTER_OTLP_* variables) or file")
+	fs.StringVar(&f.traceFile, "trace-file", "cochl-mcp-traces.jsonl", "file that receives spans with -trace-exporter file")
+	fs.DurationVar(&f.shutdownTimeout, "shutdown-timeout", 30*time.Second, "how long to wait for running analyses on SIGINT or SIGTERM before canceling them")
+	return fs, f
+}
+
+// serve runs the MCP server, the default command.
+func serve(args []string) {
+	fs, f := newServeFlags(flag.ExitOnError)
+	fs.Parse(args)
+	if _, err := loadServeConfig(fs, f); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitUsage)
+	}
 
 	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
-		Level: parseLogLevel(*logLevel),
+		Level: parseLogLevel(f.logLevel),
 	})))
 
-	if err := run(transport, *port); err != nil {
+	common.SetClientBaseURL(f.allowClientBaseURL)
+	if f.mock {
+		common.SetMockMode(true)
+		slog.Warn("Mock mode enabled, analysis results are synthesized locally")
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		Exporter:       f.traceExporter,
+		File:           f.traceFile,
+		ServiceVersion: common.Version,
+	})
+	if err != nil {
+		slog.Error("Failed to set up tracing", "error", err)
+		os.Exit(1)
+	}
+
+	polling := f.polling()
+
+	meter, err := openUsageMeter(f.usageFile, f.usageLimits())
+	if err != nil {
+		slog.Error("Failed to open usage counters", "error", err)
+		os.Exit(1)
+	}
+	common.SetUsageMeter(meter)
+	if limits := f.throttle(); limits.Enabled() {
+		common.SetThrottle(throttle.New(limits))
+		slog.Info("Limiting Cochl Sense requests per project key",
+			"rate", limits.Rate, "burst", limits.Burst, "max_sessions", limits.MaxSessions)
+	}
+
+	limits := f.audioLimits()
+	cfg := serverConfig{inflight: tools.NewInflight(), polling: &polling, limits: &limits}
+	if f.cacheSize > 0 {
+		c, err := newResultCache(f.cacheSize, f.cacheTTL, f.diskCache, f.cacheDir, f.cacheMaxDiskMB)
+		if err != nil {
+			slog.Error("Failed to create result cache", "error", err)
+			os.Exit(1)
+		}
+		cfg.cache = c
+	}
+	if f.historySize > 0 {
+		store, err := openHistory(f.historySize, f.historyPersist, f.historyDir)
+		if err != nil {
+			slog.Error("Failed to open analysis history", "error", err)
+			os.Exit(1)
+		}
+		cfg.history = store
+	}
+
+	tokens, err := loadTokens(f.authTokensFile)
+	if err != nil {
+		slog.Error("Failed to load client tokens", "error", err)
+		os.Exit(1)
+	}
+	contextFunc, err := newContextFunc(f.credentials, f.projectKeyFile, f.projectKeysFile)
+	if err != nil {
+		slog.Error("Failed to configure Cochl Sense credentials", "error", err)
+		os.Exit(1)
+	}
+	lc := listenConfig{
+		addr:        net.JoinHostPort(f.bindAddr, f.port),
+		tokens:      tokens,
+		contextFunc: contextFunc,
+		tls: transport.TLSFiles{
+			CertFile:     f.tlsCert,
+			KeyFile:      f.tlsKey,
+			ClientCAFile: f.tlsClientCA,
+		},
+	}
+	if f.readyCheckAPI && !common.MockMode() {
+		lc.readyChecks = append(lc.readyChecks, transport.ReachabilityCheck(common.BaseURL()))
+	}
+
+	if f.startupCheck {
+		checkConnection(context.Background(), f.transport != "stdio")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	drain := func() {
+		// A second signal skips the wait.
+		stop()
+		slog.Info("Waiting for running analyses", "timeout", f.shutdownTimeout)
+		drainCtx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer cancel()
+		drainCtx, cancel = context.WithTimeout(drainCtx, f.shutdownTimeout)
+		defer cancel()
+		cfg.inflight.Drain(drainCtx)
+	}
+
+	err = run(ctx, newServer(cfg), f.transport, lc, drain)
+
+	if err := meter.Close(); err != nil {
+		slog.Warn("Failed to save usage", "error", err)
+	}
+	flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	if err := shutdownTracing(flushCtx); err != nil {
+		slog.Warn("Failed to flush traces", "error", err)
+	}
+	cancel()
+
+	if err != nil {
 		slog.Error("Server error", "error", err)
+		os.Stderr.Sync()
 		os.Exit(1)
 	}
+	slog.Info("Cochl MCP server stopped")
+	os.Stderr.Sync()
+}
+
+// checkConnection logs whether the Cochl Sense API accepts the project key
+// from the environment. Clients of the network transports may send their own
+// keys, so for them a missing key is expected.
+func checkConnection(ctx context.Context, clientKeys bool) {
+	report := tools.Diagnose(common.ExtractCochlSenseApiClientFromEnv(ctx))
+	switch {
+	case report.Status == tools.ConnectionOK:
+		slog.Info("Cochl Sense connection check passed", "base_url", report.BaseURL, "latency_ms", report.LatencyMs)
+	case report.Status == tools.ConnectionNoProjectKey && clientKeys:
+		slog.Info("Cochl Sense API is reachable, project keys are expected from clients", "base_url", report.BaseURL)
+	default:
+		slog.Warn("Cochl Sense connection check failed, analyses will fail until this is fixed",
+			"status", report.Status, "base_url", report.BaseURL, "problem", report.Problem, "fix", report.Fix)
+	}
+}
+
+// newContextFunc returns the context function of the network transports for
+// the given credentials mode. In server mode the project keys come from
+// keyFile, keysFile and the COCHL_SENSE_PROJECT_KEY environment variable.
+func newContextFunc(mode, keyFile, keysFile string) (server.SSEContextFunc, error) {
+	switch mode {
+	case "client":
+		if keyFile != "" || keysFile != "" {
+			return nil, fmt.Errorf("project key files require -credentials server")
+		}
+		return common.SSEContextFunc, nil
+
+	case "server":
+		defaultKey := common.ProjectKeyFromEnv()
+		if keyFile != "" {
+			var err error
+			if defaultKey, err = common.ReadSecretFile(keyFile); err != nil {
+				return nil, err
+			}
+		}
+
+		var clients map[string]string
+		if keysFile != "" {
+			var err error
+			if clients, err = common.LoadProjectKeys(keysFile); err != nil {
+				return nil, err
+			}
+		}
+
+		keys := common.NewProjectKeys(defaultKey, clients)
+		if keys.Len() == 0 && !common.MockMode() {
+			return nil, fmt.Errorf("no project keys configured: set COCHL_SENSE_PROJECT_KEY, project-key in the configuration file, -project-key-file or -project-keys-file")
+		}
+		slog.Info("Using server-side Cochl Sense project keys", "keys", keys.Len())
+		return common.ServerCredentialsContextFunc(keys), nil
+
+	default:
+		return nil, fmt.Errorf("invalid credentials mode %q, expected client or server", mode)
+	}
+}
+
+func newResultCache(size int, ttl time.Duration, disk bool, dir string, maxDiskMB int64) (*cache.Cache, error) {
+	opts := []cache.Option{
+		cache.WithMaxEntries(size),
+		cache.WithTTL(ttl),
+	}
+
+	if disk {
+		if dir == "" {
+			var err error
+			if dir, err = cache.DefaultDir(); err != nil {
+				return nil, fmt.Errorf("failed to find user cache directory: %w", err)
+			}
+		}
+		opts = append(opts, cache.WithDiskDir(dir, maxDiskMB*1024*1024))
+		slog.Info("Caching analysis results on disk", "dir", dir)
+	}
+
+	return cache.New(opts...)
+}
+
+func openHistory(size int, persist bool, dir string) (*history.Store, error) {
+	if !persist {
+		return history.Open("", size)
+	}
+	if dir == "" {
+		var err error
+		if dir, err = history.DefaultDir(); err != nil {
+			return nil, fmt.Errorf("failed to find user cache directory: %w", err)
+		}
+	}
+	slog.Info("Persisting analysis history", "dir", dir)
+	return history.Open(dir, size)
+}
+
+func openUsageMeter(path string, limits usagemeter.Limits) (*usagemeter.Meter, error) {
+	if path == "" {
+		var err error
+		if path, err = usagemeter.DefaultPath(); err != nil {
+			return nil, fmt.Errorf("failed to find user cache directory: %w", err)
+		}
+	}
+	slog.Debug("Counting usage", "file", path)
+	return usagemeter.Open(path, usagemeter.WithLimits(limits))
 }
 
 func parseLogLevel(logLevel string) slog.Level {
@@ -0,0 +1,681 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	mcpclient "github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+
+	"github.com/cochlearai/cochl-mcp-server/client"
+	"github.com/cochlearai/cochl-mcp-server/client/fakesense"
+	"github.com/cochlearai/cochl-mcp-server/common"
+	"github.com/cochlearai/cochl-mcp-server/transport"
+)
+
+// testMainEnvVar makes the test binary behave like the real server binary so
+// the stdio transport can be exercised in a subprocess.
+const testMainEnvVar = "COCHL_MCP_SERVER_TEST_MAIN"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(testMainEnvVar) == "1" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+var testResults = []client.InferenceResult{
+	{StartTime: 0, EndTime: 1000, Tags: []client.Tags{{Name: "Speech", Probability: 0.8}}},
+}
+
+func analyzeTestFile(t *testing.T, c mcpclient.MCPClient) []client.InferenceResult {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var initReq mcp.InitializeRequest
+	initReq.Params.ProtocolVersion = mcp.LATEST_PROTOCOL_VERSION
+	initReq.Params.ClientInfo = mcp.Implementation{Name: "test-client", Version: "1.0.0"}
+	if _, err := c.Initialize(ctx, initReq); err != nil {
+		t.Fatalf("failed to initialize: %v", err)
+	}
+
+	path, err := filepath.Abs(filepath.Join("..", "..", "util", "audio", "testdata", "wav-test.wav"))
+	if err != nil {
+		t.Fatalf("failed to resolve testdata path: %v", err)
+	}
+
+	var callReq mcp.CallToolRequest
+	callReq.Params.Name = "analyze_audio"
+	callReq.Params.Arguments = map[string]any{"file_absolute_path": path}
+	result, err := c.CallTool(ctx, callReq)
+	if err != nil {
+		t.Fatalf("failed to call tool: %v", err)
+	}
+	if result.IsError || len(result.Content) == 0 {
+		t.Fatalf("unexpected tool result: %+v", result)
+	}
+
+	text, ok := result.Content[0].(mcp.TextContent)
+	if !ok {
+		t.Fatalf("expected text content, got %T", result.Content[0])
+	}
+	var got []client.InferenceResult
+	if err := json.Unmarshal([]byte(text.Text), &got); err != nil {
+		t.Fatalf("failed to decode result: %v", err)
+	}
+	return got
+}
+
+func TestStdioTransport(t *testing.T) {
+	fake := fakesense.NewServer(
+		fakesense.WithAPIKey("stdio-key"),
+		fakesense.WithResults(testResults...),
+	)
+	defer fake.Close()
+
+	c, err := mcpclient.NewStdioMCPClient(os.Args[0], []string{
+		testMainEnvVar + "=1",
+		"COCHL_SENSE_BASE_URL=" + fake.URL,
+		"COCHL_SENSE_PROJECT_KEY=stdio-key",
+	}, "-transport", "stdio")
+	if err != nil {
+		t.Fatalf("failed to start server: %v", err)
+	}
+	defer c.Close()
+
+	if got := analyzeTestFile(t, c); !reflect.DeepEqual(got, testResults) {
+		t.Errorf("got %+v, want %+v", got, testResults)
+	}
+	if sessions := fake.Sessions(); len(sessions) != 1 || !sessions[0].Deleted {
+		t.Errorf("expected one deleted session, got %+v", sessions)
+	}
+}
+
+func TestMockMode(t *testing.T) {
+	c, err := mcpclient.NewStdioMCPClient(os.Args[0], []string{
+		testMainEnvVar + "=1",
+		"COCHL_SENSE_PROJECT_KEY=",
+	}, "-transport", "stdio", "-mock")
+	if err != nil {
+		t.Fatalf("failed to start server: %v", err)
+	}
+	defer c.Close()
+
+	got := analyzeTestFile(t, c)
+	if len(got) == 0 {
+		t.Fatal("expected mock results")
+	}
+	for _, r := range got {
+		if r.EndTime <= r.StartTime || len(r.Tags) == 0 {
+			t.Errorf("implausible segment: %+v", r)
+		}
+	}
+}
+
+func TestSSETransport(t *testing.T) {
+	fake := fakesense.NewServer(
+		fakesense.WithAPIKey("sse-key"),
+		fakesense.WithResults(testResults...),
+	)
+	defer fake.Close()
+
+	t.Setenv("COCHL_SENSE_BASE_URL", fake.URL)
+	ts := server.NewTestServer(newServer(serverConfig{}), server.WithSSEContextFunc(common.SSEContextFunc))
+	defer func() {
+		// The SSE stream stays open until its connection is dropped.
+		ts.CloseClientConnections()
+		ts.Close()
+	}()
+
+	c, err := mcpclient.NewSSEMCPClient(ts.URL+"/sse", mcpclient.WithHeaders(map[string]string{
+		"X-Api-Key": "sse-key",
+	}))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	defer c.Close()
+
+	if err := c.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start client: %v", err)
+	}
+
+	if got := analyzeTestFile(t, c); !reflect.DeepEqual(got, testResults) {
+		t.Errorf("got %+v, want %+v", got, testResults)
+	}
+	if sessions := fake.Sessions(); len(sessions) != 1 || !sessions[0].Deleted {
+		t.Errorf("expected one deleted session, got %+v", sessions)
+	}
+}
+
+// httpClient is a minimal Streamable HTTP client for tests.
+type httpClient struct {
+	url       string
+	header    http.Header
+	sessionID string
+	nextID    int
+}
+
+func newHTTPClient(url string, header map[string]string) *httpClient {
+	c := &httpClient{url: url, header: make(http.Header)}
+	for k, v := range header {
+		c.header.Set(k, v)
+	}
+	return c
+}
+
+func (c *httpClient) call(t *testing.T, method string, params any) json.RawMessage {
+	t.Helper()
+
+	c.nextID++
+	body, _ := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": c.nextID, "method": method, "params": params})
+	req, err := http.NewRequest(http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	req.Header = c.header.Clone()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	if c.sessionID != "" {
+		req.Header.Set(transport.SessionIDHeader, c.sessionID)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("%s failed with status %d", method, resp.StatusCode)
+	}
+	if id := resp.Header.Get(transport.SessionIDHeader); id != "" {
+		c.sessionID = id
+	}
+
+	var msg struct {
+		Result json.RawMessage `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&msg); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	return msg.Result
+}
+
+func (c *httpClient) initialize(t *testing.T) {
+	t.Helper()
+
+	c.call(t, "initialize", map[string]any{
+		"protocolVersion": mcp.LATEST_PROTOCOL_VERSION,
+		"clientInfo":      map[string]any{"name": "test-client", "version": "1.0.0"},
+	})
+	if c.sessionID == "" {
+		t.Fatal("expected a session ID from initialize")
+	}
+}
+
+// analyze calls analyze_audio on the test file and returns the text of the
+// result and whether it is a tool error.
+func (c *httpClient) analyze(t *testing.T) (string, bool) {
+	t.Helper()
+
+	path, err := filepath.Abs(filepath.Join("..", "..", "util", "audio", "testdata", "wav-test.wav"))
+	if err != nil {
+		t.Fatalf("failed to resolve testdata path: %v", err)
+	}
+	raw := c.call(t, "tools/call", map[string]any{"name": "analyze_audio", "arguments": map[string]any{"file_absolute_path": path}})
+	var result struct {
+		Content []mcp.TextContent `json:"content"`
+		IsError bool              `json:"isError"`
+	}
+	if err := json.Unmarshal(raw, &result); err != nil || len(result.Content) == 0 {
+		t.Fatalf("unexpected tool result: %s", raw)
+	}
+	return result.Content[0].Text, result.IsError
+}
+
+func TestStreamableHTTPTransport(t *testing.T) {
+	fake := fakesense.NewServer(
+		fakesense.WithAPIKey("http-key"),
+		fakesense.WithResults(testResults...),
+	)
+	defer fake.Close()
+
+	t.Setenv("COCHL_SENSE_BASE_URL", fake.URL)
+	h := transport.NewStreamableHTTPServer(newServer(serverConfig{}), transport.WithHTTPContextFunc(common.SSEContextFunc))
+	ts := httptest.NewServer(h)
+	defer func() {
+		h.Shutdown(context.Background())
+		ts.Close()
+	}()
+
+	c := newHTTPClient(ts.URL+"/mcp", map[string]string{
+		"X-Api-Key": "http-key",
+	})
+	c.initialize(t)
+
+	text, isError := c.analyze(t)
+	if isError {
+		t.Fatalf("unexpected tool error: %s", text)
+	}
+	var got []client.InferenceResult
+	if err := json.Unmarshal([]byte(text), &got); err != nil {
+		t.Fatalf("failed to decode result: %v", err)
+	}
+	if !reflect.DeepEqual(got, testResults) {
+		t.Errorf("got %+v, want %+v", got, testResults)
+	}
+	if sessions := fake.Sessions(); len(sessions) != 1 || !sessions[0].Deleted {
+		t.Errorf("expected one deleted session, got %+v", sessions)
+	}
+
+	// Without a project key the request is refused before any upload.
+	c = newHTTPClient(ts.URL+"/mcp", nil)
+	c.initialize(t)
+	if text, isError := c.analyze(t); !isError || !strings.Contains(text, "send your project key in the X-Api-Key header") {
+		t.Errorf("expected missing key error, got %q", text)
+	}
+	if n := len(fake.Sessions()); n != 1 {
+		t.Errorf("expected no new Cochl Sense session, got %d sessions", n)
+	}
+}
+
+// startNetworkServer runs the server binary in a subprocess listening on a
+// free local port, and returns its address once it accepts connections.
+func startNetworkServer(t *testing.T, env []string, args ...string) (*exec.Cmd, string) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to find a free port: %v", err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	_, port, _ := net.SplitHostPort(addr)
+
+	args = append(args, "-bind-addr", "127.0.0.1", "-sse-port", port, "-history-size", "0")
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Env = append(append(os.Environ(), testMainEnvVar+"=1"), env...)
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("failed to start server: %v", err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		conn, err := net.Dial("tcp", addr)
+		if err == nil {
+			conn.Close()
+			return cmd, addr
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("server did not start listening: %v", err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestHTTPAuthentication(t *testing.T) {
+	tokensFile := filepath.Join(t.TempDir(), "tokens")
+	if err := os.WriteFile(tokensFile, []byte("alice file-token\n"), 0600); err != nil {
+		t.Fatalf("failed to write tokens: %v", err)
+	}
+	_, addr := startNetworkServer(t, []string{authTokenEnvVar + "=env-token"},
+		"-transport", "http", "-mock", "-auth-tokens-file", tokensFile)
+
+	initialize := func(token string) int {
+		body := `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"` +
+			mcp.LATEST_PROTOCOL_VERSION + `","clientInfo":{"name":"test","version":"1.0.0"}}}`
+		req, _ := http.NewRequest(http.MethodPost, "http://"+addr+"/mcp", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	for token, want := range map[string]int{
+		"":           http.StatusUnauthorized,
+		"wrong":      http.StatusUnauthorized,
+		"file-token": http.StatusOK,
+		"env-token":  http.StatusOK,
+	} {
+		if got := initialize(token); got != want {
+			t.Errorf("token %q: got status %d, want %d", token, got, want)
+		}
+	}
+}
+
+func TestServerCredentials(t *testing.T) {
+	fake := fakesense.NewServer(
+		fakesense.WithAPIKey("alice-project-key"),
+		fakesense.WithResults(testResults...),
+	)
+	defer fake.Close()
+
+	dir := t.TempDir()
+	tokensFile := filepath.Join(dir, "tokens")
+	keysFile := filepath.Join(dir, "keys")
+	if err := os.WriteFile(tokensFile, []byte("alice alice-token\nbob bob-token\n"), 0600); err != nil {
+		t.Fatalf("failed to write tokens: %v", err)
+	}
+	if err := os.WriteFile(keysFile, []byte("alice alice-project-key\n"), 0600); err != nil {
+		t.Fatalf("failed to write project keys: %v", err)
+	}
+
+	_, addr := startNetworkServer(t, []string{"COCHL_SENSE_BASE_URL=" + fake.URL, "COCHL_SENSE_PROJECT_KEY="},
+		"-transport", "http", "-credentials", "server",
+		"-auth-tokens-file", tokensFile, "-project-keys-file", keysFile)
+
+	// Headers cannot override the server's key or API URL.
+	alice := newHTTPClient("http://"+addr+"/mcp", map[string]string{
+		"Authorization": "Bearer alice-token",
+		"X-Api-Key":     "wrong-key",
+		"X-Base-Url":    "http://127.0.0.1:1",
+	})
+	alice.initialize(t)
+	if text, isError := alice.analyze(t); isError {
+		t.Fatalf("unexpected tool error: %s", text)
+	}
+
+	bob := newHTTPClient("http://"+addr+"/mcp", map[string]string{"Authorization": "Bearer bob-token"})
+	bob.initialize(t)
+	text, isError := bob.analyze(t)
+	if !isError || !strings.Contains(text, `no Cochl Sense project key configured for client "bob"`) {
+		t.Errorf("expected missing key error for bob, got %q", text)
+	}
+
+	if sessions := fake.Sessions(); len(sessions) != 1 {
+		t.Errorf("expected one Cochl Sense session, got %d", len(sessions))
+	}
+}
+
+// signalOnSession sends SIGTERM to cmd once fake has an open session.
+func signalOnSession(cmd *exec.Cmd, fake *fakesense.Server) {
+	go func() {
+		for len(fake.Sessions()) == 0 {
+			time.Sleep(10 * time.Millisecond)
+		}
+		cmd.Process.Signal(syscall.SIGTERM)
+	}()
+}
+
+// waitExit waits for cmd to exit and fails unless it exited cleanly.
+func waitExit(t *testing.T, cmd *exec.Cmd) {
+	t.Helper()
+
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+	select {
+	case err := <-exited:
+		if err != nil {
+			t.Errorf("server did not exit cleanly: %v", err)
+		}
+	case <-time.After(15 * time.Second):
+		t.Fatal("server did not exit after SIGTERM")
+	}
+}
+
+func TestGracefulShutdown(t *testing.T) {
+	tests := []struct {
+		name         string
+		timeout      string
+		pendingPolls int
+		wantError    string
+	}{
+		{name: "Analysis finishes", timeout: "30s", pendingPolls: 1},
+		{name: "Deadline passes", timeout: "500ms", pendingPolls: 1 << 20, wantError: "analysis canceled"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := fakesense.NewServer(
+				fakesense.WithAPIKey("http-key"),
+				fakesense.WithResults(testResults...),
+				fakesense.WithPendingPolls(tt.pendingPolls),
+			)
+			defer fake.Close()
+
+			cmd, addr := startNetworkServer(t, []string{"COCHL_SENSE_BASE_URL=" + fake.URL}, "-transport", "http", "-shutdown-timeout", tt.timeout)
+			c := newHTTPClient("http://"+addr+"/mcp", map[string]string{
+				"X-Api-Key": "http-key",
+			})
+			c.initialize(t)
+
+			signalOnSession(cmd, fake)
+			text, isError := c.analyze(t)
+			switch {
+			case tt.wantError == "" && isError:
+				t.Errorf("unexpected tool error: %s", text)
+			case tt.wantError != "" && (!isError || !strings.Contains(text, tt.wantError)):
+				t.Errorf("expected error containing %q, got %q", tt.wantError, text)
+			}
+
+			waitExit(t, cmd)
+			if sessions := fake.Sessions(); len(sessions) != 1 || !sessions[0].Deleted {
+				t.Errorf("expected one deleted session, got %+v", sessions)
+			}
+		})
+	}
+}
+
+func TestGracefulShutdownClosesSSEStreams(t *testing.T) {
+	cmd, addr := startNetworkServer(t, nil, "-transport", "sse", "-mock")
+
+	resp, err := http.Get("http://" + addr + "/sse")
+	if err != nil {
+		t.Fatalf("failed to open SSE stream: %v", err)
+	}
+	defer resp.Body.Close()
+
+	cmd.Process.Signal(syscall.SIGTERM)
+	waitExit(t, cmd)
+	if _, err := io.ReadAll(resp.Body); err != nil {
+		t.Errorf("SSE stream was not closed cleanly: %v", err)
+	}
+}
+
+func TestHealthAndMetricsEndpoints(t *testing.T) {
+	fake := fakesense.NewServer(fakesense.WithResults(testResults...))
+	defer fake.Close()
+
+	tokensFile := filepath.Join(t.TempDir(), "tokens")
+	if err := os.WriteFile(tokensFile, []byte("alice alice-token\n"), 0600); err != nil {
+		t.Fatalf("failed to write tokens: %v", err)
+	}
+	_, addr := startNetworkServer(t, []string{"COCHL_SENSE_BASE_URL=" + fake.URL},
+		"-transport", "http", "-auth-tokens-file", tokensFile, "-ready-check-api")
+
+	get := func(path string) (int, string) {
+		resp, err := http.Get("http://" + addr + path)
+		if err != nil {
+			t.Fatalf("GET %s failed: %v", path, err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	// Probes need no client token.
+	if status, _ := get("/healthz"); status != http.StatusOK {
+		t.Errorf("/healthz: got status %d", status)
+	}
+	if status, body := get("/readyz"); status != http.StatusOK {
+		t.Errorf("/readyz: got status %d: %s", status, body)
+	}
+
+	c := newHTTPClient("http://"+addr+"/mcp", map[string]string{
+		"Authorization": "Bearer alice-token",
+		"X-Api-Key":     "key",
+	})
+	c.initialize(t)
+	if text, isError := c.analyze(t); isError {
+		t.Fatalf("unexpected tool error: %s", text)
+	}
+
+	_, body := get("/metrics")
+	for _, want := range []string{
+		`cochl_mcp_tool_calls_total{tool="analyze_audio",result="success"} 1`,
+		`cochl_sense_poll_iterations_count 1`,
+		`cochl_mcp_client_sessions 1`,
+		`cochl_sense_active_sessions 0`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics do not contain %q:\n%s", want, body)
+		}
+	}
+
+	// Readiness fails once the API is unreachable.
+	fake.Close()
+	if status, body := get("/readyz"); status != http.StatusServiceUnavailable || !strings.Contains(body, "unreachable") {
+		t.Errorf("/readyz: expected unreachable API, got %d: %s", status, body)
+	}
+}
+
+func TestTraceFileExporter(t *testing.T) {
+	fake := fakesense.NewServer(fakesense.WithResults(testResults...))
+	defer fake.Close()
+
+	traceFile := filepath.Join(t.TempDir(), "traces.jsonl")
+	cmd, addr := startNetworkServer(t, []string{"COCHL_SENSE_BASE_URL=" + fake.URL}, "-transport", "http", "-trace-exporter", "file", "-trace-file", traceFile)
+	c := newHTTPClient("http://"+addr+"/mcp", map[string]string{
+		"X-Api-Key": "key",
+	})
+	c.initialize(t)
+	if text, isError := c.analyze(t); isError {
+		t.Fatalf("unexpected tool error: %s", text)
+	}
+
+	// Spans are flushed on shutdown.
+	cmd.Process.Signal(syscall.SIGTERM)
+	waitExit(t, cmd)
+
+	f, err := os.Open(traceFile)
+	if err != nil {
+		t.Fatalf("failed to open trace file: %v", err)
+	}
+	defer f.Close()
+
+	traces := make(map[string]bool)
+	names := make(map[string]bool)
+	dec := json.NewDecoder(f)
+	for dec.More() {
+		var span struct {
+			Name        string
+			SpanContext struct{ TraceID string }
+		}
+		if err := dec.Decode(&span); err != nil {
+			t.Fatalf("failed to decode span: %v", err)
+		}
+		names[span.Name] = true
+		traces[span.SpanContext.TraceID] = true
+	}
+	for _, want := range []string{"tools/call analyze_audio", "GetAudioInfo", "HTTP POST", "HTTP PUT", "poll", "HTTP GET", "HTTP DELETE"} {
+		if !names[want] {
+			t.Errorf("missing span %q, got %v", want, names)
+		}
+	}
+	if len(traces) != 1 {
+		t.Errorf("expected every span in one trace, got %d traces", len(traces))
+	}
+}
+
+func TestStreamCommand(t *testing.T) {
+	fake := fakesense.NewServer(fakesense.WithAPIKey("stream-key"), fakesense.WithResults(testResults...))
+	defer fake.Close()
+
+	audio, err := os.Open(filepath.Join("..", "..", "util", "audio", "testdata", "wav-test.wav"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer audio.Close()
+
+	cmd := exec.Command(os.Args[0], "stream", "-max-duration", "2s", "-poll-interval", "10ms", "-")
+	cmd.Env = append(os.Environ(),
+		testMainEnvVar+"=1",
+		"COCHL_SENSE_BASE_URL="+fake.URL,
+		"COCHL_SENSE_PROJECT_KEY=stream-key",
+	)
+	cmd.Stdin = audio
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("stream command failed: %v\n%s", err, stderr.String())
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected a detection and the stats, got %q", out)
+	}
+	var detection client.InferenceResult
+	if err := json.Unmarshal([]byte(lines[0]), &detection); err != nil || !reflect.DeepEqual(detection, testResults[0]) {
+		t.Errorf("got detection %s, want %+v", lines[0], testResults[0])
+	}
+	var stats struct {
+		Stats struct {
+			Chunks       int     `json:"chunks"`
+			AudioSeconds float64 `json:"audio_seconds"`
+		} `json:"stats"`
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &stats); err != nil || stats.Stats.Chunks != 2 || stats.Stats.AudioSeconds != 2 {
+		t.Errorf("got stats %s, want 2 s in 2 chunks", lines[1])
+	}
+
+	sessions := fake.Sessions()
+	if len(sessions) != 1 || sessions[0].Type != "stream" || !sessions[0].ReadOnly || !sessions[0].Deleted {
+		t.Errorf("expected one closed and deleted stream session, got %+v", sessions)
+	}
+}
+
+func TestStartupCheck(t *testing.T) {
+	fake := fakesense.NewServer(fakesense.WithAPIKey("check-key"))
+	defer fake.Close()
+
+	for key, want := range map[string]string{
+		"check-key": "Cochl Sense connection check passed",
+		"wrong-key": "Cochl Sense connection check failed, analyses will fail until this is fixed\" status=invalid_project_key",
+	} {
+		cmd := exec.Command(os.Args[0], "-startup-check", "-history-size", "0")
+		cmd.Env = append(os.Environ(),
+			testMainEnvVar+"=1",
+			"COCHL_SENSE_BASE_URL="+fake.URL,
+			"COCHL_SENSE_PROJECT_KEY="+key,
+		)
+		// The server stops once stdin is closed.
+		cmd.Stdin = strings.NewReader("")
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("server failed: %v\n%s", err, out)
+		}
+		if !strings.Contains(string(out), want) {
+			t.Errorf("with key %s, expected %q in the log:\n%s", key, want, out)
+		}
+	}
+	if sessions := fake.Sessions(); len(sessions) != 0 {
+		t.Errorf("expected no session to be created, got %d", len(sessions))
+	}
+}
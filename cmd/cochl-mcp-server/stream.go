@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/cochlearai/cochl-mcp-server/common"
+	"github.com/cochlearai/cochl-mcp-server/stream"
+)
+
+// streamCommand runs the stream command: live audio from a file, a named
+// pipe or stdin is analyzed with a Cochl Sense stream session, with the
+// credentials in the environment, and detections are written to stdout. The
+// stream stops reading once ctx is done. It returns the exit code.
+func streamCommand(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("stream", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	container := fs.String("format", "wav", "container of the audio: wav or pcm (raw 16-bit little-endian samples)")
+	sampleRate := fs.Int("sample-rate", 22050, "sample rate of -format pcm audio in Hz")
+	channels := fs.Int("channels", 1, "number of channels of -format pcm audio")
+	chunk := fs.Duration("chunk", stream.DefaultChunkDuration, "length of the audio uploaded at a time")
+	maxDuration := fs.Duration("max-duration", 0, "stop after this much audio (0 reads until the source ends)")
+	pollInterval := fs.Duration("poll-interval", stream.DefaultPollInterval, "delay between result requests")
+	mock := fs.Bool("mock", false, "use an embedded offline Cochl Sense backend instead of the API")
+	logLevel := fs.String("log-level", "info", "log level (debug, info, warn, error)")
+	configFile := fs.String("config", "", "YAML configuration file providing the Cochl Sense base URL and project key")
+	profile := fs.String("profile", "", "profile of the configuration file")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s stream [flags] <source>\n\nAnalyze live audio from a file, a named pipe or stdin (\"-\"), printing detections as JSON lines. Flags:\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(stderr, "expected exactly one audio source")
+		fs.Usage()
+		return exitUsage
+	}
+
+	slog.SetDefault(slog.New(slog.NewTextHandler(stderr, &slog.HandlerOptions{
+		Level: parseLogLevel(*logLevel),
+	})))
+	common.SetMockMode(*mock)
+	if err := loadCredentials(*configFile, *profile); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+	if common.ProjectKeyFromEnv() == "" && !common.MockMode() {
+		fmt.Fprintf(stderr, "%v: set the COCHL_SENSE_PROJECT_KEY environment variable, project-key in the configuration file, or use -mock\n", common.ErrMissingProjectKey)
+		return exitUsage
+	}
+
+	err := runStream(ctx, streamConfig{
+		source:    fs.Arg(0),
+		container: *container,
+		format:    stream.Format{SampleRate: *sampleRate, Channels: *channels},
+		opts: stream.Options{
+			ChunkDuration: *chunk,
+			MaxDuration:   *maxDuration,
+			PollInterval:  *pollInterval,
+		},
+	}, stdout)
+	if err != nil {
+		slog.Error("Stream analysis failed", "error", err)
+		return exitFailed
+	}
+	return exitOK
+}
+
+// streamConfig configures the stream command.
+type streamConfig struct {
+	// source is a file or named pipe, or "-" for stdin.
+	source    string
+	container string
+	format    stream.Format
+	opts      stream.Options
+}
+
+// runStream streams audio from cfg.source to Cochl Sense with the
+// credentials in the environment. Each detection is written to out as a JSON
+// line as it arrives, followed by a line holding the stats. Once ctx is done,
+// no more audio is read and the remaining results are awaited; a second
+// signal aborts.
+func runStream(ctx context.Context, cfg streamConfig, out io.Writer) error {
+	c := common.CochlSenseClientFromContext(common.ExtractCochlSenseApiClientFromEnv(context.Background()))
+
+	r := os.Stdin
+	if cfg.source != "-" {
+		slog.Info("Opening audio source", "path", cfg.source)
+		f, err := os.Open(cfg.source)
+		if err != nil {
+			return fmt.Errorf("failed to open audio source: %w", err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	src, err := stream.NewSource(r, cfg.container, cfg.format)
+	if err != nil {
+		return err
+	}
+
+	runCtx, abort := context.WithCancel(context.Background())
+	defer abort()
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-runCtx.Done():
+			return
+		}
+		slog.Info("Stopping stream, waiting for the remaining results")
+		second, stop := signal.NotifyContext(runCtx, os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		<-second.Done()
+		abort()
+	}()
+
+	session, err := c.CreateStreamSession(runCtx, src.Format.ContentType())
+	if err != nil {
+		return fmt.Errorf("failed to create stream session: %w", err)
+	}
+	defer func() {
+		deleteCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := c.DeleteSession(deleteCtx, session.SessionID); err != nil {
+			slog.Warn("Failed to delete session", "session_id", session.SessionID, "error", err)
+		}
+	}()
+	slog.Info("Streaming audio", "session_id", session.SessionID, "content_type", src.Format.ContentType())
+
+	enc := json.NewEncoder(out)
+	opts := cfg.opts
+	opts.Stop = ctx.Done()
+	opts.OnDetections = func(detections []stream.Detection) {
+		for _, d := range detections {
+			enc.Encode(d)
+		}
+	}
+	result, err := stream.Run(runCtx, c, session, src, opts)
+	enc.Encode(map[string]any{"stats": result.Stats})
+	if errors.Is(err, context.Canceled) {
+		return errors.New("stream aborted")
+	}
+	return err
+}
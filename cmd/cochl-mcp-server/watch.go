@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/cochlearai/cochl-mcp-server/client"
+	"github.com/cochlearai/cochl-mcp-server/common"
+	"github.com/cochlearai/cochl-mcp-server/tools"
+	"github.com/cochlearai/cochl-mcp-server/watch"
+)
+
+// defaultWatchLog is the name of the result log in the watched directory
+// unless -log-file is given.
+const defaultWatchLog = "cochl-results.jsonl"
+
+// watchDir runs the watch command: recordings dropped into a directory are
+// analyzed with the analyze_audio tool until ctx is done. It returns the exit
+// code.
+func watchDir(ctx context.Context, args []string, stderr io.Writer) int {
+	fs := flag.NewFlagSet("watch", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	output := fs.String("output", "sidecar", "where results go: sidecar (a "+watch.SidecarSuffix+" file next to each recording) or jsonl (-log-file)")
+	logFile := fs.String("log-file", "", "JSON lines file that -output jsonl appends results to (default: "+defaultWatchLog+" in the watched directory)")
+	stateFile := fs.String("state-file", "", "file recording which recordings are done (default: "+watch.DefaultStateFile+" in the watched directory)")
+	settleTime := fs.Duration("settle-time", watch.DefaultSettleTime, "how long a file must stay unchanged before it is analyzed")
+	pollInterval := fs.Duration("poll-interval", watch.DefaultPollInterval, "how often the directory is scanned")
+	polling := fs.Bool("polling", false, "scan the directory instead of using inotify, e.g. on network file systems")
+	maxAttempts := fs.Int("max-attempts", watch.DefaultMaxAttempts, "how many times a recording is analyzed before it is given up on")
+	mock := fs.Bool("mock", false, "use an embedded offline Cochl Sense backend instead of the API")
+	logLevel := fs.String("log-level", "info", "log level (debug, info, warn, error)")
+	configFile := fs.String("config", "", "YAML configuration file providing the Cochl Sense base URL and project key")
+	profile := fs.String("profile", "", "profile of the configuration file")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s watch [flags] <dir>\n\nAnalyze the recordings written to a directory. Flags:\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(stderr, "expected exactly one directory to watch")
+		fs.Usage()
+		return exitUsage
+	}
+	dir := fs.Arg(0)
+
+	slog.SetDefault(slog.New(slog.NewTextHandler(stderr, &slog.HandlerOptions{
+		Level: parseLogLevel(*logLevel),
+	})))
+	common.SetMockMode(*mock)
+	if err := loadCredentials(*configFile, *profile); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+	if common.ProjectKeyFromEnv() == "" && !common.MockMode() {
+		fmt.Fprintf(stderr, "%v: set the COCHL_SENSE_PROJECT_KEY environment variable, project-key in the configuration file, or use -mock\n", common.ErrMissingProjectKey)
+		return exitUsage
+	}
+
+	var out watch.Output
+	switch *output {
+	case "sidecar":
+		out = watch.SidecarOutput()
+	case "jsonl":
+		path := *logFile
+		if path == "" {
+			path = filepath.Join(dir, defaultWatchLog)
+		}
+		log, err := watch.OpenJSONLOutput(path)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitUsage
+		}
+		defer log.Close()
+		out = log
+	default:
+		fmt.Fprintf(stderr, "invalid output %q, expected sidecar or jsonl\n", *output)
+		return exitUsage
+	}
+
+	ctx = common.ExtractCochlSenseApiClientFromEnv(ctx)
+	_, handler := tools.Sense()
+	w, err := watch.New(watch.Config{
+		Dir: dir,
+		Analyze: func(ctx context.Context, path string) ([]client.InferenceResult, error) {
+			a := analyzeFile(ctx, handler, path)
+			if a.Error != "" {
+				return nil, errors.New(a.Error)
+			}
+			return a.Results, nil
+		},
+		Output:       out,
+		StateFile:    *stateFile,
+		SettleTime:   *settleTime,
+		PollInterval: *pollInterval,
+		Polling:      *polling,
+		MaxAttempts:  *maxAttempts,
+	})
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+
+	if err := w.Run(ctx); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitFailed
+	}
+	return exitOK
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cochlearai/cochl-mcp-server/client/fakesense"
+	"github.com/cochlearai/cochl-mcp-server/watch"
+)
+
+func TestWatchCommand(t *testing.T) {
+	fake := fakesense.NewServer(fakesense.WithAPIKey("cli-key"), fakesense.WithResults(analyzeResults...))
+	defer fake.Close()
+	t.Setenv("COCHL_SENSE_BASE_URL", fake.URL)
+	t.Setenv("COCHL_SENSE_PROJECT_KEY", "cli-key")
+
+	dir := t.TempDir()
+	logFile := filepath.Join(dir, "results.jsonl")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var stderr bytes.Buffer
+	done := make(chan int, 1)
+	go func() {
+		done <- watchDir(ctx, []string{"-output", "jsonl", "-log-file", logFile, "-settle-time", "50ms", "-poll-interval", "20ms", dir}, &stderr)
+	}()
+
+	wav, err := os.ReadFile(filepath.Join("..", "..", "util", "audio", "testdata", "wav-test.wav"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "recording.wav"), wav, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var data []byte
+	for deadline := time.Now().Add(10 * time.Second); len(data) == 0; time.Sleep(20 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("no result was logged, stderr:\n%s", stderr.String())
+		}
+		data, _ = os.ReadFile(logFile)
+	}
+	cancel()
+	if code := <-done; code != exitOK {
+		t.Errorf("got exit code %d, want %d, stderr:\n%s", code, exitOK, stderr.String())
+	}
+
+	var record watch.Record
+	if err := json.Unmarshal(data, &record); err != nil {
+		t.Fatalf("invalid log %q: %v", data, err)
+	}
+	if record.File != filepath.Join(dir, "recording.wav") || !reflect.DeepEqual(record.Results, analyzeResults) {
+		t.Errorf("got record %+v", record)
+	}
+}
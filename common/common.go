@@ -2,13 +2,19 @@ package common
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
+	"strings"
 
 	"github.com/mark3labs/mcp-go/server"
 
 	"github.com/cochlearai/cochl-mcp-server/client"
+	"github.com/cochlearai/cochl-mcp-server/client/mocksense"
+	"github.com/cochlearai/cochl-mcp-server/throttle"
+	"github.com/cochlearai/cochl-mcp-server/transport"
+	"github.com/cochlearai/cochl-mcp-server/usage"
 )
 
 // Version is set at build time using ldflags
@@ -26,30 +32,111 @@ const (
 
 type cochlSenseClientKey struct{}
 
-var ExtractCochlSenseApiClientFromEnv server.StdioContextFunc = func(ctx context.Context) context.Context {
-	apiKey := os.Getenv(_cochlSenseProjectKeyEnvVar)
-	baseUrl := os.Getenv(_cochlSenseBaseURLEnvVar)
-	if baseUrl == "" {
-		baseUrl = _defaultBaseURL
+var (
+	mockMode bool
+	// clientBaseURL lets the X-Base-Url header choose the API.
+	clientBaseURL bool
+
+	// configBaseURL and configProjectKey come from the configuration file
+	// and apply when the environment sets none.
+	configBaseURL    string
+	configProjectKey string
+
+	usageMeter *usage.Meter
+	limits     *throttle.Throttle
+)
+
+// SetUsageMeter makes every client created by the context functions account
+// the audio it submits in m, by project key and client name. Mock clients
+// are not accounted.
+func SetUsageMeter(m *usage.Meter) {
+	usageMeter = m
+}
+
+// SetThrottle makes every client created by the context functions wait for
+// the limits of t, shared by the clients of each project key. Mock clients
+// are not limited.
+func SetThrottle(t *throttle.Throttle) {
+	limits = t
+}
+
+// SetClientBaseURL lets clients of the network transports choose the Cochl
+// Sense API with the X-Base-Url header. It is off by default, since the
+// server would otherwise send requests to any URL a client names.
+func SetClientBaseURL(allowed bool) {
+	clientBaseURL = allowed
+}
+
+// SetConfigDefaults sets the base URL and project key used when the
+// environment sets none, as read from the configuration file. The base URL
+// also applies to requests of the network transports.
+func SetConfigDefaults(baseURL, projectKey string) {
+	configBaseURL, configProjectKey = baseURL, projectKey
+}
+
+// SetMockMode makes every client created by the context functions use the
+// embedded offline backend from mocksense, whatever base URL is configured.
+func SetMockMode(enabled bool) {
+	mockMode = enabled
+}
+
+// MockMode reports whether SetMockMode enabled the offline backend.
+func MockMode() bool {
+	return mockMode
+}
+
+// newCochlSenseClient creates a client for the API at baseUrl. clientName is
+// the authenticated client the requests are made for, if any.
+func newCochlSenseClient(apiKey, baseUrl, clientName string) client.CochlSense {
+	if mockMode || strings.HasPrefix(baseUrl, mocksense.BaseURL) {
+		slog.Debug("CochlSense mock client created", "version", Version)
+		return mocksense.NewClient(Version)
 	}
-	client := client.NewCochlSense(apiKey, baseUrl, Version)
 
 	slog.Debug("CochlSense client created", "baseUrl", baseUrl, "version", Version, "api-key-set", apiKey != "")
-	return context.WithValue(ctx, cochlSenseClientKey{}, client)
+	var c client.CochlSense = client.NewCochlSense(apiKey, baseUrl, Version)
+	if limits != nil {
+		c = limits.Client(c, baseUrl, apiKey)
+	}
+	// Usage is checked first, so refused sessions do not wait for a slot.
+	if usageMeter != nil && apiKey != "" {
+		return usageMeter.Client(c, apiKey, clientName)
+	}
+	return c
+}
+
+// BaseURL returns the Cochl Sense API URL set in the environment or the
+// configuration file, or the public API if none is set.
+func BaseURL() string {
+	if baseUrl := os.Getenv(_cochlSenseBaseURLEnvVar); baseUrl != "" {
+		return baseUrl
+	}
+	if configBaseURL != "" {
+		return configBaseURL
+	}
+	return _defaultBaseURL
+}
+
+var ExtractCochlSenseApiClientFromEnv server.StdioContextFunc = func(ctx context.Context) context.Context {
+	return WithCochlSenseClient(ctx, newCochlSenseClient(ProjectKeyFromEnv(), BaseURL(), ""))
 }
 
 var ExtractCochlSenseApiClientFromHeader server.SSEContextFunc = func(ctx context.Context, r *http.Request) context.Context {
 	apiKey := r.Header.Get(_cochlSenseProjectKeyHeader)
-	baseUrl := r.Header.Get(_cochlSenseBaseURLHeader)
-
+	var baseUrl string
+	if clientBaseURL {
+		baseUrl = r.Header.Get(_cochlSenseBaseURLHeader)
+	}
 	if baseUrl == "" {
-		baseUrl = _defaultBaseURL
+		baseUrl = BaseURL()
+	}
+	if apiKey == "" && !mockMode && !strings.HasPrefix(baseUrl, mocksense.BaseURL) {
+		return withCredentialError(ctx, fmt.Errorf(
+			"%w: send your project key in the %s header", ErrMissingProjectKey, _cochlSenseProjectKeyHeader))
 	}
 
-	client := client.NewCochlSense(apiKey, baseUrl, Version)
-
-	slog.Debug("CochlSense client created", "baseUrl", baseUrl, "version", Version, "api-key-set", apiKey != "")
-	return context.WithValue(ctx, cochlSenseClientKey{}, client)
+	name, _ := transport.ClientNameFromContext(ctx)
+	return WithCochlSenseClient(ctx, newCochlSenseClient(apiKey, baseUrl, name))
 }
 
 var (
@@ -57,8 +144,14 @@ var (
 	StdioContextFunc server.StdioContextFunc = ExtractCochlSenseApiClientFromEnv
 )
 
-func CochlSenseClientFromContext(ctx context.Context) *client.CochlSenseClient {
-	c, ok := ctx.Value(cochlSenseClientKey{}).(*client.CochlSenseClient)
+// WithCochlSenseClient returns a copy of ctx carrying c, which tools retrieve
+// with CochlSenseClientFromContext.
+func WithCochlSenseClient(ctx context.Context, c client.CochlSense) context.Context {
+	return context.WithValue(ctx, cochlSenseClientKey{}, c)
+}
+
+func CochlSenseClientFromContext(ctx context.Context) client.CochlSense {
+	c, ok := ctx.Value(cochlSenseClientKey{}).(client.CochlSense)
 	if !ok {
 		return nil
 	}
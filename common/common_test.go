@@ -0,0 +1,63 @@
+package common
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cochlearai/cochl-mcp-server/client/fakesense"
+)
+
+func TestHeaderClientUsesConfiguredBaseURL(t *testing.T) {
+	fake := fakesense.NewServer(fakesense.WithAPIKey("header-key"))
+	t.Cleanup(fake.Close)
+
+	t.Setenv(_cochlSenseBaseURLEnvVar, "")
+	SetConfigDefaults(fake.URL, "")
+	t.Cleanup(func() { SetConfigDefaults("", "") })
+
+	r := httptest.NewRequest("POST", "/sse", nil)
+	r.Header.Set(_cochlSenseProjectKeyHeader, "header-key")
+	c, err := CochlSenseClient(ExtractCochlSenseApiClientFromHeader(context.Background(), r))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := c.CreateSession(context.Background(), "a.wav", "audio/wav", 1, 100); err != nil {
+		t.Fatalf("expected the call to reach the configured base URL, got %v", err)
+	}
+	if sessions := fake.Sessions(); len(sessions) != 1 {
+		t.Errorf("expected 1 session on the configured backend, got %d", len(sessions))
+	}
+}
+
+func TestHeaderBaseURLRequiresOptIn(t *testing.T) {
+	configured := fakesense.NewServer(fakesense.WithAPIKey("header-key"))
+	t.Cleanup(configured.Close)
+	requested := fakesense.NewServer(fakesense.WithAPIKey("header-key"))
+	t.Cleanup(requested.Close)
+	t.Setenv(_cochlSenseBaseURLEnvVar, configured.URL)
+
+	for _, allowed := range []bool{false, true} {
+		SetClientBaseURL(allowed)
+		t.Cleanup(func() { SetClientBaseURL(false) })
+
+		r := httptest.NewRequest("POST", "/mcp", nil)
+		r.Header.Set(_cochlSenseProjectKeyHeader, "header-key")
+		r.Header.Set(_cochlSenseBaseURLHeader, requested.URL)
+		c, err := CochlSenseClient(ExtractCochlSenseApiClientFromHeader(context.Background(), r))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := c.CreateSession(context.Background(), "a.wav", "audio/wav", 1, 100); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if n := len(configured.Sessions()); n != 1 {
+		t.Errorf("expected the header to be ignored by default, got %d sessions on the configured API", n)
+	}
+	if n := len(requested.Sessions()); n != 1 {
+		t.Errorf("expected the header to be honored once allowed, got %d sessions on the requested API", n)
+	}
+}
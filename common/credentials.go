@@ -0,0 +1,170 @@
+package common
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/server"
+
+	"github.com/cochlearai/cochl-mcp-server/client"
+	"github.com/cochlearai/cochl-mcp-server/transport"
+)
+
+// ErrMissingProjectKey means no Cochl Sense project key could be found for a
+// request. Tools report it before reading any audio.
+var ErrMissingProjectKey = errors.New("no Cochl Sense project key")
+
+// defaultClientName is the client name that sets the default key in a
+// project keys file.
+const defaultClientName = "*"
+
+type credentialErrorKey struct{}
+
+// withCredentialError records why no client could be created for a request.
+func withCredentialError(ctx context.Context, err error) context.Context {
+	return context.WithValue(ctx, credentialErrorKey{}, err)
+}
+
+// CochlSenseClient returns the client for the request, or an error wrapping
+// ErrMissingProjectKey if the request has no usable project key.
+func CochlSenseClient(ctx context.Context) (client.CochlSense, error) {
+	if c := CochlSenseClientFromContext(ctx); c != nil {
+		return c, nil
+	}
+	if err, ok := ctx.Value(credentialErrorKey{}).(error); ok {
+		return nil, err
+	}
+	return nil, errors.New("cochl sense client not found")
+}
+
+// CallerID identifies who makes a request by the client name the transport
+// authenticated and the tenant of the project key of its client, so network
+// transports can keep callers out of each other's sessions.
+func CallerID(ctx context.Context) string {
+	name, _ := transport.ClientNameFromContext(ctx)
+	return name + "/" + Tenant(CochlSenseClientFromContext(ctx))
+}
+
+// Tenant identifies the project key of c, so data derived from one key is
+// not served to callers with another. It is empty for clients that do not
+// report a key. Mock clients are all built with the key "mock", so they
+// share one tenant.
+func Tenant(c client.CochlSense) string {
+	if k, ok := c.(interface{ KeyID() string }); ok {
+		return k.KeyID()
+	}
+	return ""
+}
+
+// ProjectKeys holds the Cochl Sense project keys of a server that calls the
+// API on behalf of its clients, instead of taking keys from request headers.
+type ProjectKeys struct {
+	defaultKey string
+	clients    map[string]string
+}
+
+// NewProjectKeys returns keys that give each named client in clients its own
+// project key and every other client defaultKey, if set.
+func NewProjectKeys(defaultKey string, clients map[string]string) *ProjectKeys {
+	if clients == nil {
+		clients = make(map[string]string)
+	}
+	if key, ok := clients[defaultClientName]; ok && defaultKey == "" {
+		defaultKey = key
+	}
+	delete(clients, defaultClientName)
+	return &ProjectKeys{defaultKey: defaultKey, clients: clients}
+}
+
+// LoadProjectKeys reads a file mapping client names, as authenticated by the
+// transport, to project keys: one "<client name> <project key>" per line. The
+// name * sets the key used for every other client. Blank lines and lines
+// starting with # are ignored.
+func LoadProjectKeys(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open project keys file: %w", err)
+	}
+	defer f.Close()
+
+	keys := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: expected \"<client name> <project key>\"", path, line)
+		}
+		keys[fields[0]] = fields[1]
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read project keys file: %w", err)
+	}
+	return keys, nil
+}
+
+// ProjectKeyFromEnv returns the project key set in the environment, or else
+// in the configuration file, if any.
+func ProjectKeyFromEnv() string {
+	if key := os.Getenv(_cochlSenseProjectKeyEnvVar); key != "" {
+		return key
+	}
+	return configProjectKey
+}
+
+// ReadSecretFile returns the trimmed contents of a file holding a single
+// secret, such as a Docker or Kubernetes secret.
+func ReadSecretFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret file: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// Len returns the number of configured keys, including the default key.
+func (k *ProjectKeys) Len() int {
+	n := len(k.clients)
+	if k.defaultKey != "" {
+		n++
+	}
+	return n
+}
+
+// Resolve returns the project key for the named client.
+func (k *ProjectKeys) Resolve(clientName string) (string, bool) {
+	if key, ok := k.clients[clientName]; ok && clientName != "" {
+		return key, true
+	}
+	return k.defaultKey, k.defaultKey != ""
+}
+
+// ServerCredentialsContextFunc creates clients from keys held by the server,
+// chosen by the client name the transport authenticated. The API URL comes
+// from the environment; request headers cannot change the key or the URL, so
+// clients cannot send the server's keys elsewhere.
+func ServerCredentialsContextFunc(keys *ProjectKeys) server.SSEContextFunc {
+	baseUrl := BaseURL()
+	return func(ctx context.Context, r *http.Request) context.Context {
+		name, _ := transport.ClientNameFromContext(ctx)
+		apiKey, ok := keys.Resolve(name)
+		if !ok && !mockMode {
+			if name == "" {
+				return withCredentialError(ctx, fmt.Errorf(
+					"%w: this server has no default project key and the request is not authenticated as a known client",
+					ErrMissingProjectKey))
+			}
+			return withCredentialError(ctx, fmt.Errorf(
+				"%w configured for client %q: ask the server operator to add one", ErrMissingProjectKey, name))
+		}
+		return WithCochlSenseClient(ctx, newCochlSenseClient(apiKey, baseUrl, name))
+	}
+}
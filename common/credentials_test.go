@@ -0,0 +1,33 @@
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProjectKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys")
+	if err := os.WriteFile(path, []byte("# keys\nalice key-a\n* key-default\n"), 0600); err != nil {
+		t.Fatalf("failed to write keys: %v", err)
+	}
+	clients, err := LoadProjectKeys(path)
+	if err != nil {
+		t.Fatalf("failed to load keys: %v", err)
+	}
+
+	keys := NewProjectKeys("", clients)
+	for name, want := range map[string]string{"alice": "key-a", "bob": "key-default", "": "key-default"} {
+		if got, ok := keys.Resolve(name); !ok || got != want {
+			t.Errorf("Resolve(%q) = %q, %v, want %q", name, got, ok, want)
+		}
+	}
+
+	keys = NewProjectKeys("", map[string]string{"alice": "key-a"})
+	if _, ok := keys.Resolve("bob"); ok {
+		t.Error("expected no key for bob without a default")
+	}
+	if keys.Len() != 1 {
+		t.Errorf("expected 1 key, got %d", keys.Len())
+	}
+}
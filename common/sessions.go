@@ -0,0 +1,120 @@
+package common
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+
+	"github.com/cochlearai/cochl-mcp-server/metrics"
+)
+
+// Sessions tracks connected client sessions so server-initiated
+// notifications can be sent to all of them. Register it with
+// server.Hooks.AddOnRegisterSession, and Identify with
+// server.Hooks.AddBeforeAny to notify sessions by tenant.
+type Sessions struct {
+	mu       sync.Mutex
+	sessions map[string]server.ClientSession
+	// tenants holds the Tenant of the client each session last made a
+	// request with.
+	tenants map[string]string
+}
+
+func NewSessions() *Sessions {
+	return &Sessions{
+		sessions: make(map[string]server.ClientSession),
+		tenants:  make(map[string]string),
+	}
+}
+
+// Register tracks session until ctx, the context the transport registered it
+// with, is done.
+func (s *Sessions) Register(ctx context.Context, session server.ClientSession) {
+	id := session.SessionID()
+
+	s.mu.Lock()
+	if _, ok := s.sessions[id]; !ok {
+		metrics.ClientSessions.Inc()
+	}
+	s.sessions[id] = session
+	s.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.sessions[id] == session {
+			delete(s.sessions, id)
+			delete(s.tenants, id)
+			metrics.ClientSessions.Dec()
+		}
+	}()
+}
+
+// Identify records the tenant of the client a session makes a request with.
+// Transports only attach clients to request contexts, so a session is known
+// to a tenant from its first request, usually initialize.
+func (s *Sessions) Identify(ctx context.Context, id any, method mcp.MCPMethod, message any) {
+	session := server.ClientSessionFromContext(ctx)
+	c := CochlSenseClientFromContext(ctx)
+	if session == nil || c == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.sessions[session.SessionID()]; ok {
+		s.tenants[session.SessionID()] = Tenant(c)
+	}
+}
+
+// Count returns the number of tracked sessions.
+func (s *Sessions) Count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.sessions)
+}
+
+// Broadcast sends a notification to every initialized session. Sessions whose
+// notification channel is full are skipped.
+func (s *Sessions) Broadcast(method string, params map[string]any) {
+	s.broadcast(method, params, func(string) bool { return true })
+}
+
+// BroadcastTo sends a notification to every initialized session identified
+// with tenant, like Broadcast.
+func (s *Sessions) BroadcastTo(tenant, method string, params map[string]any) {
+	s.broadcast(method, params, func(id string) bool {
+		t, ok := s.tenants[id]
+		return ok && t == tenant
+	})
+}
+
+func (s *Sessions) broadcast(method string, params map[string]any, include func(id string) bool) {
+	notification := mcp.JSONRPCNotification{
+		JSONRPC: mcp.JSONRPC_VERSION,
+		Notification: mcp.Notification{
+			Method: method,
+			Params: mcp.NotificationParams{
+				AdditionalFields: params,
+			},
+		},
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, session := range s.sessions {
+		if !session.Initialized() || !include(id) {
+			continue
+		}
+		select {
+		case session.NotificationChannel() <- notification:
+		default:
+			slog.Warn("Dropping notification for blocked session", "session", id, "method", method)
+		}
+	}
+}
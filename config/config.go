@@ -0,0 +1,318 @@
+// Package config reads the YAML configuration file of the server. The file
+// sets command line flags by name, plus the Cochl Sense base URL and project
+// key, and can hold named profiles that override those settings:
+//
+//	log-level: info
+//	profile: staging
+//	profiles:
+//	  staging:
+//	    base-url: https://staging.example.com
+//	    project-key: ...
+//	  production:
+//	    transport: http
+//
+// Flags given on the command line take precedence over the environment, which
+// takes precedence over the file.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// PathEnvVar names the configuration file when -config is not given.
+	PathEnvVar = "COCHL_MCP_CONFIG"
+	// ProfileEnvVar selects a profile when -profile is not given.
+	ProfileEnvVar = "COCHL_MCP_PROFILE"
+
+	// BaseURL and ProjectKey are the settings that configure Cochl Sense
+	// rather than a flag.
+	BaseURL    = "base-url"
+	ProjectKey = "project-key"
+)
+
+// Setting is one value from the configuration file.
+type Setting struct {
+	Name  string
+	Value string
+	// Source is "config file", or "profile <name>" for a profile setting.
+	Source string
+	Line   int
+}
+
+// Config is the configuration file, resolved for the selected profile.
+type Config struct {
+	// Path is the file read, or empty if there is none.
+	Path    string
+	Profile string
+
+	settings map[string]Setting
+}
+
+// DefaultPath returns the configuration file read when neither -config nor
+// COCHL_MCP_CONFIG names one.
+func DefaultPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "cochl-mcp-server", "config.yaml"), nil
+}
+
+// Load reads the configuration file at path, or the one named by
+// COCHL_MCP_CONFIG, or the one at DefaultPath if it exists, and resolves the
+// given profile. Without a profile, COCHL_MCP_PROFILE or the profile named in
+// the file is used.
+func Load(path, profile string) (*Config, error) {
+	if path == "" {
+		path = os.Getenv(PathEnvVar)
+	}
+	if profile == "" {
+		profile = os.Getenv(ProfileEnvVar)
+	}
+
+	optional := false
+	if path == "" {
+		var err error
+		if path, err = DefaultPath(); err != nil {
+			return &Config{settings: make(map[string]Setting)}, nil
+		}
+		optional = true
+	}
+
+	data, err := os.ReadFile(path)
+	if optional && errors.Is(err, fs.ErrNotExist) {
+		if profile != "" {
+			return nil, fmt.Errorf("profile %q selected but there is no configuration file", profile)
+		}
+		return &Config{settings: make(map[string]Setting)}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read configuration file: %w", err)
+	}
+	return parse(path, data, profile)
+}
+
+func parse(path string, data []byte, profile string) (*Config, error) {
+	c := &Config{Path: path, settings: make(map[string]Setting)}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(doc.Content) == 0 {
+		if profile != "" {
+			return nil, fmt.Errorf("%s: profile %q is not defined", path, profile)
+		}
+		return c, nil
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%s:%d: expected settings as \"name: value\" pairs", path, root.Line)
+	}
+
+	var errs []error
+	profiles := make(map[string]*yaml.Node)
+	defaultProfile := ""
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+		switch key.Value {
+		case "profile":
+			v, err := scalar(path, key, value)
+			if err != nil {
+				errs = append(errs, err)
+			}
+			defaultProfile = v
+		case "profiles":
+			if value.Kind != yaml.MappingNode {
+				errs = append(errs, fmt.Errorf("%s:%d: profiles must map profile names to settings", path, value.Line))
+				continue
+			}
+			for j := 0; j+1 < len(value.Content); j += 2 {
+				profiles[value.Content[j].Value] = value.Content[j+1]
+			}
+		default:
+			errs = append(errs, c.add(path, key, value, "config file"))
+		}
+	}
+
+	if profile == "" {
+		profile = defaultProfile
+	}
+	if profile != "" {
+		node, ok := profiles[profile]
+		switch {
+		case !ok:
+			errs = append(errs, fmt.Errorf("%s: profile %q is not defined%s", path, profile, available(profiles)))
+		case node.Kind != yaml.MappingNode:
+			errs = append(errs, fmt.Errorf("%s:%d: profile %q must map names to values", path, node.Line, profile))
+		default:
+			for j := 0; j+1 < len(node.Content); j += 2 {
+				errs = append(errs, c.add(path, node.Content[j], node.Content[j+1], "profile "+profile))
+			}
+		}
+		c.Profile = profile
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// add records a setting, overriding an earlier one of the same name.
+func (c *Config) add(path string, key, value *yaml.Node, source string) error {
+	v, err := scalar(path, key, value)
+	if err != nil {
+		return err
+	}
+	if key.Value == "config" {
+		return fmt.Errorf("%s:%d: the configuration file cannot name another one", path, key.Line)
+	}
+	c.settings[key.Value] = Setting{Name: key.Value, Value: v, Source: source, Line: key.Line}
+	return nil
+}
+
+func scalar(path string, key, value *yaml.Node) (string, error) {
+	if value.Kind != yaml.ScalarNode {
+		return "", fmt.Errorf("%s:%d: %s must be a single value", path, key.Line, key.Value)
+	}
+	if value.Tag == "!!null" {
+		return "", fmt.Errorf("%s:%d: %s has no value", path, key.Line, key.Value)
+	}
+	return value.Value, nil
+}
+
+func available(profiles map[string]*yaml.Node) string {
+	if len(profiles) == 0 {
+		return ", the file has no profiles"
+	}
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return ", expected one of " + strings.Join(names, ", ")
+}
+
+// Lookup returns the setting called name, if the file sets it.
+func (c *Config) Lookup(name string) (Setting, bool) {
+	s, ok := c.settings[name]
+	return s, ok
+}
+
+// Value returns the value of the setting called name, or an empty string.
+func (c *Config) Value(name string) string {
+	return c.settings[name].Value
+}
+
+// Settings returns every setting, sorted by name.
+func (c *Config) Settings() []Setting {
+	settings := make([]Setting, 0, len(c.settings))
+	for _, s := range c.settings {
+		settings = append(settings, s)
+	}
+	sort.Slice(settings, func(i, j int) bool { return settings[i].Name < settings[j].Name })
+	return settings
+}
+
+// Apply sets the flags of fs named by the file, except those already set on
+// the command line. It reports every setting that is not a flag of fs or has
+// an invalid value.
+func (c *Config) Apply(fs *flag.FlagSet) error {
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+
+	var errs []error
+	for _, s := range c.Settings() {
+		if s.Name == BaseURL || s.Name == ProjectKey {
+			continue
+		}
+		f := fs.Lookup(s.Name)
+		if f == nil || s.Name == "profile" {
+			errs = append(errs, c.errorf(s, "unknown setting %q%s", s.Name, suggest(s.Name, fs)))
+			continue
+		}
+		if explicit[s.Name] {
+			continue
+		}
+		if err := fs.Set(s.Name, s.Value); err != nil {
+			errs = append(errs, c.errorf(s, "invalid value %q for %s (%s): %v", s.Value, s.Name, f.Usage, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (c *Config) errorf(s Setting, format string, args ...any) error {
+	return fmt.Errorf("%s:%d: %s", c.Path, s.Line, fmt.Sprintf(format, args...))
+}
+
+// suggest returns a hint naming the flag of fs, or Cochl Sense setting, that
+// name was probably meant to be.
+func suggest(name string, fs *flag.FlagSet) string {
+	candidates := []string{BaseURL, ProjectKey}
+	fs.VisitAll(func(f *flag.Flag) { candidates = append(candidates, f.Name) })
+
+	normalized := strings.ReplaceAll(strings.ToLower(name), "_", "-")
+	best, bestDistance := "", 3
+	for _, candidate := range candidates {
+		if candidate == normalized {
+			return fmt.Sprintf(", did you mean %q?", candidate)
+		}
+		if d := distance(normalized, candidate); d < bestDistance {
+			best, bestDistance = candidate, d
+		}
+	}
+	if best == "" {
+		return ""
+	}
+	return fmt.Sprintf(", did you mean %q?", best)
+}
+
+// distance returns the Levenshtein distance between a and b.
+func distance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// Secret reports whether the setting called name holds a secret that must not
+// be displayed.
+func Secret(name string) bool {
+	return name == ProjectKey
+}
+
+// Redact hides all but the last four characters of a secret, and all of a
+// short one.
+func Redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	if len(secret) < 12 {
+		return "****"
+	}
+	return "****" + secret[len(secret)-4:]
+}
@@ -0,0 +1,198 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testConfig = `
+log-level: debug
+sse-port: 9000
+base-url: https://api.example.com
+profile: staging
+profiles:
+  staging:
+    base-url: https://staging.example.com
+    project-key: staging-key
+  production:
+    transport: http
+    shutdown-timeout: 1m
+`
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+type testFlags struct {
+	transport string
+	logLevel  string
+	port      int
+	timeout   time.Duration
+}
+
+func newTestFlags() (*flag.FlagSet, *testFlags) {
+	f := &testFlags{}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.StringVar(&f.transport, "transport", "stdio", "transport")
+	fs.StringVar(&f.logLevel, "log-level", "info", "log level")
+	fs.IntVar(&f.port, "sse-port", 8080, "port")
+	fs.DurationVar(&f.timeout, "shutdown-timeout", 30*time.Second, "shutdown timeout")
+	return fs, f
+}
+
+func TestLoadProfiles(t *testing.T) {
+	t.Setenv(ProfileEnvVar, "")
+	path := writeConfig(t, testConfig)
+
+	c, err := Load(path, "")
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	if c.Profile != "staging" || c.Value(BaseURL) != "https://staging.example.com" || c.Value(ProjectKey) != "staging-key" {
+		t.Errorf("expected the default staging profile, got profile %q with %+v", c.Profile, c.Settings())
+	}
+	if s, _ := c.Lookup(BaseURL); s.Source != "profile staging" || s.Line != 8 {
+		t.Errorf("got base-url setting %+v", s)
+	}
+
+	// The environment selects a profile, the argument overrides it.
+	t.Setenv(ProfileEnvVar, "staging")
+	c, err = Load(path, "production")
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	if c.Value(BaseURL) != "https://api.example.com" || c.Value(ProjectKey) != "" || c.Value("transport") != "http" {
+		t.Errorf("expected the production profile, got %+v", c.Settings())
+	}
+
+	if _, err := Load(path, "qa"); err == nil || !strings.Contains(err.Error(), `profile "qa" is not defined, expected one of production, staging`) {
+		t.Errorf("expected an undefined profile error, got %v", err)
+	}
+}
+
+func TestLoadPath(t *testing.T) {
+	path := writeConfig(t, "transport: sse\n")
+	t.Setenv(PathEnvVar, path)
+	c, err := Load("", "")
+	if err != nil || c.Path != path || c.Value("transport") != "sse" {
+		t.Errorf("expected the file named by %s, got %+v, %v", PathEnvVar, c, err)
+	}
+
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.yaml"), ""); err == nil {
+		t.Error("expected an error for a missing file given explicitly")
+	}
+
+	// Without a file anywhere, there is nothing to apply.
+	t.Setenv(PathEnvVar, "")
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	c, err = Load("", "")
+	if err != nil || c.Path != "" || len(c.Settings()) != 0 {
+		t.Errorf("expected an empty config, got %+v, %v", c, err)
+	}
+}
+
+func TestApply(t *testing.T) {
+	path := writeConfig(t, testConfig)
+	c, err := Load(path, "production")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fs, f := newTestFlags()
+	if err := fs.Parse([]string{"-log-level", "warn"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Apply(fs); err != nil {
+		t.Fatalf("failed to apply config: %v", err)
+	}
+	// The command line takes precedence over the file.
+	want := testFlags{transport: "http", logLevel: "warn", port: 9000, timeout: time.Minute}
+	if *f != want {
+		t.Errorf("got flags %+v, want %+v", *f, want)
+	}
+}
+
+func TestInvalidConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{
+			name:    "Unknown settings",
+			content: "sse_port: 9000\nlog-levle: debug\ncolor: blue\n",
+			want: []string{
+				`config.yaml:1: unknown setting "sse_port", did you mean "sse-port"?`,
+				`config.yaml:2: unknown setting "log-levle", did you mean "log-level"?`,
+				`config.yaml:3: unknown setting "color"`,
+			},
+		},
+		{
+			name:    "Invalid values",
+			content: "sse-port: http\nshutdown-timeout: 30\n",
+			want: []string{
+				`config.yaml:1: invalid value "http" for sse-port (port)`,
+				`config.yaml:2: invalid value "30" for shutdown-timeout (shutdown timeout)`,
+			},
+		},
+		{
+			name:    "Not a single value",
+			content: "transport:\n  - sse\nlog-level:\n",
+			want:    []string{"config.yaml:1: transport must be a single value", "config.yaml:3: log-level has no value"},
+		},
+		{
+			name:    "Not settings",
+			content: "- transport\n",
+			want:    []string{`config.yaml:1: expected settings as "name: value" pairs`},
+		},
+		{
+			name:    "Syntax error",
+			content: "transport: [sse\n",
+			want:    []string{"config.yaml: yaml:"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeConfig(t, tt.content)
+			c, err := Load(path, "")
+			if err == nil {
+				fs, _ := newTestFlags()
+				err = c.Apply(fs)
+			}
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not contain %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRedact(t *testing.T) {
+	for secret, want := range map[string]string{
+		"":                 "",
+		"short":            "****",
+		"0123456789abcdef": "****cdef",
+	} {
+		if got := Redact(secret); got != want {
+			t.Errorf("Redact(%q) = %q, want %q", secret, got, want)
+		}
+	}
+	if !Secret(ProjectKey) || Secret(BaseURL) {
+		t.Error("only the project key is secret")
+	}
+}
@@ -0,0 +1,260 @@
+// Package history keeps a record of completed analyses so they can be
+// revisited without analyzing the audio again. Records are kept in memory and
+// optionally persisted as one JSON file each in a directory.
+package history
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cochlearai/cochl-mcp-server/client"
+)
+
+// Record is one completed analysis.
+type Record struct {
+	ID string `json:"id"`
+	// Owner identifies the project key the analysis was made with. Records
+	// are only listed and read for the same owner.
+	Owner      string                   `json:"owner,omitempty"`
+	FilePath   string                   `json:"file_path"`
+	FileName   string                   `json:"file_name"`
+	Format     string                   `json:"format"`
+	Duration   float64                  `json:"duration"`
+	Size       int                      `json:"size"`
+	AnalyzedAt time.Time                `json:"analyzed_at"`
+	Results    []client.InferenceResult `json:"results"`
+}
+
+// Summary describes a Record without its full results.
+type Summary struct {
+	ID         string       `json:"id"`
+	FileName   string       `json:"file_name"`
+	Duration   float64      `json:"duration"`
+	AnalyzedAt time.Time    `json:"analyzed_at"`
+	TopTags    []TagSummary `json:"top_tags"`
+}
+
+// TagSummary aggregates the detections of one tag across an analysis.
+type TagSummary struct {
+	Name           string  `json:"name"`
+	Segments       int     `json:"segments"`
+	MaxProbability float64 `json:"max_probability"`
+}
+
+// TopTags returns up to n tags detected in results, most frequent first.
+// The catch-all "Others" tag is left out.
+func TopTags(results []client.InferenceResult, n int) []TagSummary {
+	byName := make(map[string]*TagSummary)
+	for _, r := range results {
+		for _, tag := range r.Tags {
+			if tag.Name == "Others" {
+				continue
+			}
+			ts, ok := byName[tag.Name]
+			if !ok {
+				ts = &TagSummary{Name: tag.Name}
+				byName[tag.Name] = ts
+			}
+			ts.Segments++
+			ts.MaxProbability = max(ts.MaxProbability, tag.Probability)
+		}
+	}
+
+	tags := make([]TagSummary, 0, len(byName))
+	for _, ts := range byName {
+		tags = append(tags, *ts)
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Segments != tags[j].Segments {
+			return tags[i].Segments > tags[j].Segments
+		}
+		if tags[i].MaxProbability != tags[j].MaxProbability {
+			return tags[i].MaxProbability > tags[j].MaxProbability
+		}
+		return tags[i].Name < tags[j].Name
+	})
+
+	if len(tags) > n {
+		tags = tags[:n]
+	}
+	return tags
+}
+
+// DefaultDir returns the directory used to persist history when none is
+// configured.
+func DefaultDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "cochl-mcp-server", "history"), nil
+}
+
+type Store struct {
+	mu         sync.Mutex
+	dir        string
+	maxRecords int
+	// records is ordered from oldest to newest.
+	records []Record
+	onAdd   []func(Record)
+	now     func() time.Time
+}
+
+// Open returns a Store keeping at most maxRecords records, dropping the
+// oldest first. If dir is not empty, records are persisted there and the
+// ones already present are loaded.
+func Open(dir string, maxRecords int) (*Store, error) {
+	s := &Store{
+		dir:        dir,
+		maxRecords: maxRecords,
+		now:        time.Now,
+	}
+	if dir == "" {
+		return s, nil
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create history directory: %w", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history directory: %w", err)
+	}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			slog.Warn("Failed to read history record", "file", e.Name(), "error", err)
+			continue
+		}
+		var r Record
+		if err := json.Unmarshal(data, &r); err != nil || r.ID == "" {
+			slog.Warn("Skipping invalid history record", "file", e.Name(), "error", err)
+			continue
+		}
+		s.records = append(s.records, r)
+	}
+
+	sort.Slice(s.records, func(i, j int) bool {
+		return s.records[i].AnalyzedAt.Before(s.records[j].AnalyzedAt)
+	})
+	s.prune()
+	return s, nil
+}
+
+// OnAdd registers fn to be called with every record added to the store.
+func (s *Store) OnAdd(fn func(Record)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onAdd = append(s.onAdd, fn)
+}
+
+// Add stores r, assigning its ID and analysis time, and returns the stored
+// record.
+func (s *Store) Add(r Record) (Record, error) {
+	id, err := newID(s.now())
+	if err != nil {
+		return Record{}, err
+	}
+	r.ID = id
+	r.AnalyzedAt = s.now()
+
+	s.mu.Lock()
+	if s.dir != "" {
+		data, err := json.Marshal(r)
+		if err != nil {
+			s.mu.Unlock()
+			return Record{}, fmt.Errorf("failed to marshal history record: %w", err)
+		}
+		if err := os.WriteFile(s.path(r.ID), data, 0o600); err != nil {
+			s.mu.Unlock()
+			return Record{}, fmt.Errorf("failed to write history record: %w", err)
+		}
+	}
+	s.records = append(s.records, r)
+	s.prune()
+	callbacks := append([]func(Record){}, s.onAdd...)
+	s.mu.Unlock()
+
+	for _, fn := range callbacks {
+		fn(r)
+	}
+	return r, nil
+}
+
+// Get returns the record of owner with the given ID.
+func (s *Store) Get(owner, id string) (Record, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, r := range s.records {
+		if r.ID == id && r.Owner == owner {
+			return r, true
+		}
+	}
+	return Record{}, false
+}
+
+// List summarizes every record of owner, newest first.
+func (s *Store) List(owner string) []Summary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	summaries := make([]Summary, 0, len(s.records))
+	for i := len(s.records) - 1; i >= 0; i-- {
+		r := s.records[i]
+		if r.Owner != owner {
+			continue
+		}
+		summaries = append(summaries, Summary{
+			ID:         r.ID,
+			FileName:   r.FileName,
+			Duration:   r.Duration,
+			AnalyzedAt: r.AnalyzedAt,
+			TopTags:    TopTags(r.Results, 3),
+		})
+	}
+	return summaries
+}
+
+func (s *Store) prune() {
+	if s.maxRecords <= 0 || len(s.records) <= s.maxRecords {
+		return
+	}
+
+	drop := s.records[:len(s.records)-s.maxRecords]
+	for _, r := range drop {
+		if s.dir == "" {
+			continue
+		}
+		if err := os.Remove(s.path(r.ID)); err != nil && !os.IsNotExist(err) {
+			slog.Warn("Failed to remove history record", "id", r.ID, "error", err)
+		}
+	}
+	s.records = append([]Record(nil), s.records[len(drop):]...)
+}
+
+func (s *Store) path(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
+
+// newID returns a record ID that sorts by creation time.
+func newID(t time.Time) (string, error) {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate history id: %w", err)
+	}
+	return t.UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,102 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cochlearai/cochl-mcp-server/client"
+)
+
+func segment(start int, tags ...client.Tags) client.InferenceResult {
+	return client.InferenceResult{StartTime: start, EndTime: start + 1000, Tags: tags}
+}
+
+func TestTopTags(t *testing.T) {
+	results := []client.InferenceResult{
+		segment(0, client.Tags{Name: "Speech", Probability: 0.6}, client.Tags{Name: "Others", Probability: 0.1}),
+		segment(500, client.Tags{Name: "Speech", Probability: 0.9}, client.Tags{Name: "Dog_bark", Probability: 0.7}),
+		segment(1000, client.Tags{Name: "Siren", Probability: 0.8}),
+		segment(1500, client.Tags{Name: "Others", Probability: 0.2}),
+	}
+
+	want := []TagSummary{
+		{Name: "Speech", Segments: 2, MaxProbability: 0.9},
+		{Name: "Siren", Segments: 1, MaxProbability: 0.8},
+	}
+	if got := TopTags(results, 2); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestStore(t *testing.T) {
+	dir := t.TempDir()
+	store, err := Open(dir, 2)
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+
+	var added []string
+	store.OnAdd(func(r Record) { added = append(added, r.ID) })
+
+	var ids []string
+	for _, name := range []string{"a.wav", "b.wav", "c.wav"} {
+		r, err := store.Add(Record{
+			Owner:    "alice",
+			FileName: name,
+			Duration: 10,
+			Results:  []client.InferenceResult{segment(0, client.Tags{Name: "Speech", Probability: 0.9})},
+		})
+		if err != nil {
+			t.Fatalf("failed to add record: %v", err)
+		}
+		if !r.AnalyzedAt.Equal(now) {
+			t.Errorf("expected analysis time %v, got %v", now, r.AnalyzedAt)
+		}
+		ids = append(ids, r.ID)
+		now = now.Add(time.Minute)
+	}
+
+	if !reflect.DeepEqual(added, ids) {
+		t.Errorf("expected callbacks for %v, got %v", ids, added)
+	}
+
+	if _, ok := store.Get("alice", ids[0]); ok {
+		t.Error("expected oldest record to be pruned")
+	}
+	if _, err := os.Stat(filepath.Join(dir, ids[0]+".json")); !os.IsNotExist(err) {
+		t.Errorf("expected pruned record file to be removed, got %v", err)
+	}
+
+	list := store.List("alice")
+	if len(list) != 2 || list[0].FileName != "c.wav" || list[1].FileName != "b.wav" {
+		t.Fatalf("expected newest records first, got %+v", list)
+	}
+	if len(list[0].TopTags) != 1 || list[0].TopTags[0].Name != "Speech" {
+		t.Errorf("unexpected top tags: %+v", list[0].TopTags)
+	}
+
+	// Reopening the directory simulates a restart.
+	reopened, err := Open(dir, 2)
+	if err != nil {
+		t.Fatalf("failed to reopen store: %v", err)
+	}
+	if !reflect.DeepEqual(reopened.List("alice"), list) {
+		t.Errorf("expected persisted records %+v, got %+v", list, reopened.List("alice"))
+	}
+	r, ok := reopened.Get("alice", ids[2])
+	if !ok || r.FileName != "c.wav" || len(r.Results) != 1 {
+		t.Errorf("unexpected persisted record: %+v", r)
+	}
+
+	if list := reopened.List("bob"); len(list) != 0 {
+		t.Errorf("expected no records for another owner, got %+v", list)
+	}
+	if _, ok := reopened.Get("bob", ids[2]); ok {
+		t.Error("expected another owner not to read the record")
+	}
+}
@@ -0,0 +1,326 @@
+// Package metrics implements the counters, gauges and histograms the server
+// exports, and serves them in the Prometheus text exposition format.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Registry holds metrics and writes them in registration order.
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+	names   map[string]bool
+}
+
+type metric interface {
+	write(w *bufio.Writer)
+}
+
+func NewRegistry() *Registry {
+	return &Registry{names: make(map[string]bool)}
+}
+
+// Default is the registry of the metrics defined by this package.
+var Default = NewRegistry()
+
+func (r *Registry) register(name string, m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.names[name] {
+		panic("metrics: duplicate metric " + name)
+	}
+	r.names[name] = true
+	r.metrics = append(r.metrics, m)
+}
+
+// Write writes every metric of r to w.
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.Lock()
+	metrics := append([]metric(nil), r.metrics...)
+	r.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+	for _, m := range metrics {
+		m.write(bw)
+	}
+	return bw.Flush()
+}
+
+// Handler serves the metrics of r to Prometheus.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.Write(w)
+	})
+}
+
+// desc describes a metric and the series it holds, one per combination of
+// label values.
+type desc struct {
+	name   string
+	help   string
+	typ    string
+	labels []string
+
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+type series struct {
+	labelValues []string
+	value       float64
+	// buckets counts the observations of a histogram per upper bound.
+	buckets []uint64
+	count   uint64
+}
+
+func newDesc(name, help, typ string, labels []string) *desc {
+	d := &desc{name: name, help: help, typ: typ, labels: labels, series: make(map[string]*series)}
+	if len(labels) == 0 {
+		// A metric without labels is exported as zero before its first update.
+		d.series[""] = &series{}
+	}
+	return d
+}
+
+// with calls fn with the series for labelValues, creating it if needed.
+func (d *desc) with(labelValues []string, fn func(s *series)) {
+	if len(labelValues) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", d.name, len(d.labels), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	s, ok := d.series[key]
+	if !ok {
+		s = &series{labelValues: append([]string(nil), labelValues...)}
+		d.series[key] = s
+	}
+	fn(s)
+}
+
+// lookup returns a copy of the series for labelValues, or an empty series if
+// it does not exist.
+func (d *desc) lookup(labelValues []string) series {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if s, ok := d.series[strings.Join(labelValues, "\xff")]; ok {
+		return *s
+	}
+	return series{}
+}
+
+// sorted returns copies of the series ordered by label values, so the output
+// is stable.
+func (d *desc) sorted() []series {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	keys := make([]string, 0, len(d.series))
+	for k := range d.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	out := make([]series, len(keys))
+	for i, k := range keys {
+		s := *d.series[k]
+		s.buckets = append([]uint64(nil), s.buckets...)
+		out[i] = s
+	}
+	return out
+}
+
+func (d *desc) writeHeader(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", d.name, escapeHelp(d.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", d.name, d.typ)
+}
+
+// Counter is a value that only goes up, such as a number of requests.
+type Counter struct{ d *desc }
+
+// NewCounter registers a counter with the given label names.
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{d: newDesc(name, help, "counter", labels)}
+	r.register(name, c)
+	return c
+}
+
+// Inc adds one to the series for labelValues.
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds v, which must not be negative, to the series for labelValues.
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		panic("metrics: counter " + c.d.name + " cannot decrease")
+	}
+	c.d.with(labelValues, func(s *series) { s.value += v })
+}
+
+// Value returns the current value of the series for labelValues.
+func (c *Counter) Value(labelValues ...string) float64 {
+	return c.d.lookup(labelValues).value
+}
+
+func (c *Counter) write(w *bufio.Writer) {
+	c.d.writeHeader(w)
+	for _, s := range c.d.sorted() {
+		writeSample(w, c.d.name, c.d.labels, s.labelValues, "", "", s.value)
+	}
+}
+
+// Gauge is a value that goes up and down, such as a number of open sessions.
+type Gauge struct{ d *desc }
+
+// NewGauge registers a gauge with the given label names.
+func (r *Registry) NewGauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{d: newDesc(name, help, "gauge", labels)}
+	r.register(name, g)
+	return g
+}
+
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	g.d.with(labelValues, func(s *series) { s.value = v })
+}
+
+func (g *Gauge) Add(v float64, labelValues ...string) {
+	g.d.with(labelValues, func(s *series) { s.value += v })
+}
+
+func (g *Gauge) Inc(labelValues ...string) { g.Add(1, labelValues...) }
+func (g *Gauge) Dec(labelValues ...string) { g.Add(-1, labelValues...) }
+
+// Value returns the current value of the series for labelValues.
+func (g *Gauge) Value(labelValues ...string) float64 {
+	return g.d.lookup(labelValues).value
+}
+
+func (g *Gauge) write(w *bufio.Writer) {
+	g.d.writeHeader(w)
+	for _, s := range g.d.sorted() {
+		writeSample(w, g.d.name, g.d.labels, s.labelValues, "", "", s.value)
+	}
+}
+
+// gaugeFunc is a gauge whose value is read when the metrics are written.
+type gaugeFunc struct {
+	d  *desc
+	fn func() float64
+}
+
+// NewGaugeFunc registers a gauge without labels whose value is fn().
+func (r *Registry) NewGaugeFunc(name, help string, fn func() float64) {
+	r.register(name, &gaugeFunc{d: newDesc(name, help, "gauge", nil), fn: fn})
+}
+
+func (g *gaugeFunc) write(w *bufio.Writer) {
+	g.d.writeHeader(w)
+	writeSample(w, g.d.name, nil, nil, "", "", g.fn())
+}
+
+// Histogram counts observations, such as latencies, in buckets.
+type Histogram struct {
+	d      *desc
+	bounds []float64
+}
+
+// NewHistogram registers a histogram with the given bucket upper bounds, in
+// increasing order, and label names.
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if !sort.Float64sAreSorted(buckets) {
+		panic("metrics: buckets of " + name + " are not sorted")
+	}
+	h := &Histogram{d: newDesc(name, help, "histogram", labels), bounds: buckets}
+	r.register(name, h)
+	return h
+}
+
+// Observe records v in the series for labelValues.
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	h.d.with(labelValues, func(s *series) {
+		if s.buckets == nil {
+			s.buckets = make([]uint64, len(h.bounds))
+		}
+		if i := sort.SearchFloat64s(h.bounds, v); i < len(h.bounds) {
+			s.buckets[i]++
+		}
+		s.count++
+		s.value += v
+	})
+}
+
+// Count returns the number of observations in the series for labelValues.
+func (h *Histogram) Count(labelValues ...string) uint64 {
+	return h.d.lookup(labelValues).count
+}
+
+func (h *Histogram) write(w *bufio.Writer) {
+	h.d.writeHeader(w)
+	for _, s := range h.d.sorted() {
+		var cumulative uint64
+		for i, bound := range h.bounds {
+			if s.buckets != nil {
+				cumulative += s.buckets[i]
+			}
+			writeSample(w, h.d.name+"_bucket", h.d.labels, s.labelValues, "le", formatFloat(bound), float64(cumulative))
+		}
+		writeSample(w, h.d.name+"_bucket", h.d.labels, s.labelValues, "le", "+Inf", float64(s.count))
+		writeSample(w, h.d.name+"_sum", h.d.labels, s.labelValues, "", "", s.value)
+		writeSample(w, h.d.name+"_count", h.d.labels, s.labelValues, "", "", float64(s.count))
+	}
+}
+
+// writeSample writes one sample line, with an optional extra label such as
+// the le label of histogram buckets.
+func writeSample(w *bufio.Writer, name string, labels, values []string, extraLabel, extraValue string, v float64) {
+	w.WriteString(name)
+	if len(labels) > 0 || extraLabel != "" {
+		w.WriteByte('{')
+		for i, l := range labels {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, "%s=\"%s\"", l, escapeLabelValue(values[i]))
+		}
+		if extraLabel != "" {
+			if len(labels) > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, "%s=\"%s\"", extraLabel, extraValue)
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(v))
+	w.WriteByte('\n')
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpReplacer       = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelValueReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string       { return helpReplacer.Replace(s) }
+func escapeLabelValue(s string) string { return labelValueReplacer.Replace(s) }
@@ -0,0 +1,91 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegistryWrite(t *testing.T) {
+	r := NewRegistry()
+	calls := r.NewCounter("calls_total", "Calls by tool.", "tool")
+	open := r.NewGauge("open", "Open things.")
+	latency := r.NewHistogram("latency_seconds", "Latency.", []float64{0.5, 1})
+	r.NewGaugeFunc("clients", "Connected clients.", func() float64 { return 3 })
+
+	calls.Inc("sense")
+	calls.Add(2, `a"b`)
+	open.Inc()
+	open.Inc()
+	open.Dec()
+	latency.Observe(0.2)
+	latency.Observe(0.7)
+	latency.Observe(4)
+
+	var buf bytes.Buffer
+	if err := r.Write(&buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := `# HELP calls_total Calls by tool.
+# TYPE calls_total counter
+calls_total{tool="a\"b"} 2
+calls_total{tool="sense"} 1
+# HELP open Open things.
+# TYPE open gauge
+open 1
+# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{le="0.5"} 1
+latency_seconds_bucket{le="1"} 2
+latency_seconds_bucket{le="+Inf"} 3
+latency_seconds_sum 4.9
+latency_seconds_count 3
+# HELP clients Connected clients.
+# TYPE clients gauge
+clients 3
+`
+	if buf.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+
+	if v := calls.Value("sense"); v != 1 {
+		t.Errorf("got counter value %v, want 1", v)
+	}
+	if n := latency.Count(); n != 3 {
+		t.Errorf("got histogram count %d, want 3", n)
+	}
+}
+
+func TestRegistryPanics(t *testing.T) {
+	expectPanic := func(name string, fn func()) {
+		t.Helper()
+		defer func() {
+			if recover() == nil {
+				t.Errorf("%s: expected panic", name)
+			}
+		}()
+		fn()
+	}
+
+	r := NewRegistry()
+	c := r.NewCounter("c", "help", "label")
+	expectPanic("duplicate", func() { r.NewCounter("c", "help") })
+	expectPanic("wrong label count", func() { c.Inc() })
+	expectPanic("negative add", func() { c.Add(-1, "x") })
+	expectPanic("unsorted buckets", func() { r.NewHistogram("h", "help", []float64{2, 1}) })
+}
+
+func TestHandler(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("requests_total", "Requests.").Inc()
+
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("unexpected content type %q", ct)
+	}
+	if !strings.Contains(rec.Body.String(), "requests_total 1\n") {
+		t.Errorf("unexpected body:\n%s", rec.Body.String())
+	}
+}
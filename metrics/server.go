@@ -0,0 +1,29 @@
+package metrics
+
+// DurationBuckets are the bucket bounds, in seconds, of the latency
+// histograms. Analyses take seconds, so they reach well past a minute.
+var DurationBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 20, 30, 60, 120, 300}
+
+var (
+	ClientSessions = Default.NewGauge("cochl_mcp_client_sessions",
+		"Connected MCP client sessions.")
+	ToolCalls = Default.NewCounter("cochl_mcp_tool_calls_total",
+		"Tool calls by tool and result (success, tool_error or error).", "tool", "result")
+	ToolCallDuration = Default.NewHistogram("cochl_mcp_tool_call_duration_seconds",
+		"Duration of tool calls.", DurationBuckets, "tool")
+
+	UploadBytes = Default.NewCounter("cochl_sense_upload_bytes_total",
+		"Audio bytes uploaded to Cochl Sense.")
+	InferenceLatency = Default.NewHistogram("cochl_sense_inference_latency_seconds",
+		"Time from the end of an upload until Cochl Sense returned its results.", DurationBuckets)
+	PollIterations = Default.NewHistogram("cochl_sense_poll_iterations",
+		"Inference result requests needed per analysis.", []float64{1, 2, 3, 5, 10, 20, 50})
+	APIErrors = Default.NewCounter("cochl_sense_api_errors_total",
+		"Failed Cochl Sense API requests by operation and HTTP status, or \"network\" if no response arrived.",
+		"operation", "status")
+	ActiveSessions = Default.NewGauge("cochl_sense_active_sessions",
+		"Cochl Sense sessions opened by running analyses.")
+	QueuedRequests = Default.NewGauge("cochl_sense_queued_requests",
+		"Cochl Sense requests waiting for the rate limit or a session slot, by limit (rate or sessions).",
+		"limit")
+)
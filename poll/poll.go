@@ -0,0 +1,161 @@
+// Package poll schedules the inference result requests of an analysis: a
+// first delay scaled to the length of the audio, then exponentially growing
+// delays up to a cap, unless the server says when to ask again.
+package poll
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Clock tells time and waits. Tests replace SystemClock with an InstantClock
+// so schedules run without sleeping.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// SystemClock is the real clock.
+var SystemClock Clock = systemClock{}
+
+// Strategy decides how long to wait before each result request.
+type Strategy struct {
+	// InitialDelay is waited before the first request, plus
+	// DelayPerAudioSecond for each second of audio, since longer recordings
+	// take longer to analyze.
+	InitialDelay        time.Duration
+	DelayPerAudioSecond time.Duration
+	// Multiplier grows the delay after every pending response, up to
+	// MaxDelay.
+	Multiplier float64
+	MaxDelay   time.Duration
+	// MaxWait bounds the time from the start of a schedule until results are
+	// given up on. Zero waits indefinitely.
+	MaxWait time.Duration
+	Clock   Clock
+}
+
+// ErrMaxWait is returned by Schedule.Wait once the strategy's MaxWait has
+// passed.
+var ErrMaxWait = errors.New("gave up waiting for results")
+
+// DefaultStrategy returns the strategy used unless flags override it: a 10 s
+// clip is first polled after 1 s, a 1 min recording after 3.5 s.
+func DefaultStrategy() Strategy {
+	return Strategy{
+		InitialDelay:        500 * time.Millisecond,
+		DelayPerAudioSecond: 50 * time.Millisecond,
+		Multiplier:          1.5,
+		MaxDelay:            10 * time.Second,
+		MaxWait:             10 * time.Minute,
+		Clock:               SystemClock,
+	}
+}
+
+// Validate reports a strategy that would poll without waiting or never.
+func (s Strategy) Validate() error {
+	switch {
+	case s.InitialDelay < 0 || s.DelayPerAudioSecond < 0:
+		return errors.New("poll delays must not be negative")
+	case s.Multiplier < 1:
+		return errors.New("the poll delay multiplier must be at least 1")
+	case s.MaxDelay <= 0:
+		return errors.New("the maximum poll delay must be positive")
+	case s.MaxWait < 0:
+		return errors.New("the maximum poll wait must not be negative")
+	}
+	return nil
+}
+
+// Schedule is the sequence of delays of one analysis.
+type Schedule struct {
+	strategy Strategy
+	next     time.Duration
+	// deadline is when MaxWait runs out, or zero without a limit.
+	deadline time.Time
+}
+
+// NewSchedule starts the schedule of an analysis of audioDuration seconds of
+// audio.
+func (s Strategy) NewSchedule(audioDuration float64) *Schedule {
+	if s.Clock == nil {
+		s.Clock = SystemClock
+	}
+	first := s.InitialDelay + time.Duration(audioDuration*float64(s.DelayPerAudioSecond))
+	sc := &Schedule{strategy: s, next: min(first, s.MaxDelay)}
+	if s.MaxWait > 0 {
+		sc.deadline = s.Clock.Now().Add(s.MaxWait)
+	}
+	return sc
+}
+
+// Next returns the delay before the next request and advances the schedule.
+// A positive hint, such as a Retry-After sent by the server, replaces the
+// delay without advancing the schedule.
+func (sc *Schedule) Next(hint time.Duration) time.Duration {
+	if hint > 0 {
+		return hint
+	}
+	d := sc.next
+	sc.next = min(time.Duration(float64(sc.next)*sc.strategy.Multiplier), sc.strategy.MaxDelay)
+	return d
+}
+
+// Wait waits for the delay returned by Next(hint). It returns ctx.Err() if
+// ctx is done first, and ErrMaxWait without waiting once the schedule's
+// MaxWait has passed. The last delay is cut short at the deadline.
+func (sc *Schedule) Wait(ctx context.Context, hint time.Duration) error {
+	d := sc.Next(hint)
+	if !sc.deadline.IsZero() {
+		left := sc.deadline.Sub(sc.strategy.Clock.Now())
+		if left <= 0 {
+			return ErrMaxWait
+		}
+		d = min(d, left)
+	}
+	select {
+	case <-sc.strategy.Clock.After(d):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// InstantClock is a Clock whose timers fire at once. Now advances by every
+// delay waited for, which Waits records.
+type InstantClock struct {
+	mu    sync.Mutex
+	now   time.Time
+	waits []time.Duration
+}
+
+func (c *InstantClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *InstantClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	c.waits = append(c.waits, d)
+
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return ch
+}
+
+// Waits returns the delays waited for so far.
+func (c *InstantClock) Waits() []time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]time.Duration(nil), c.waits...)
+}
@@ -0,0 +1,109 @@
+package poll
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSchedule(t *testing.T) {
+	s := Strategy{
+		InitialDelay:        time.Second,
+		DelayPerAudioSecond: 100 * time.Millisecond,
+		Multiplier:          2,
+		MaxDelay:            8 * time.Second,
+	}
+
+	tests := []struct {
+		name          string
+		audioDuration float64
+		hints         []time.Duration
+		want          []time.Duration
+	}{
+		{
+			name:          "Short clip",
+			audioDuration: 0,
+			hints:         make([]time.Duration, 5),
+			want:          []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 8 * time.Second},
+		},
+		{
+			name:          "Initial delay scales with duration",
+			audioDuration: 30,
+			hints:         make([]time.Duration, 2),
+			want:          []time.Duration{4 * time.Second, 8 * time.Second},
+		},
+		{
+			name:          "Initial delay is capped",
+			audioDuration: 600,
+			hints:         make([]time.Duration, 1),
+			want:          []time.Duration{8 * time.Second},
+		},
+		{
+			name:          "Server hint replaces the delay",
+			audioDuration: 0,
+			hints:         []time.Duration{0, 3 * time.Second, 0},
+			want:          []time.Duration{time.Second, 3 * time.Second, 2 * time.Second},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := &InstantClock{}
+			s := s
+			s.Clock = clock
+			sc := s.NewSchedule(tt.audioDuration)
+			for _, hint := range tt.hints {
+				if err := sc.Wait(context.Background(), hint); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+			if got := clock.Waits(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got waits %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScheduleWaitCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	sc := Strategy{InitialDelay: time.Hour, Multiplier: 1, MaxDelay: time.Hour}.NewSchedule(0)
+	if err := sc.Wait(ctx, 0); err != context.Canceled {
+		t.Errorf("got %v, want context.Canceled", err)
+	}
+}
+
+func TestScheduleMaxWait(t *testing.T) {
+	clock := &InstantClock{}
+	sc := Strategy{InitialDelay: 4 * time.Second, Multiplier: 1, MaxDelay: 4 * time.Second, MaxWait: 10 * time.Second, Clock: clock}.NewSchedule(0)
+
+	var err error
+	for i := 0; i < 5 && err == nil; i++ {
+		err = sc.Wait(context.Background(), 0)
+	}
+	if !errors.Is(err, ErrMaxWait) {
+		t.Fatalf("got %v, want ErrMaxWait", err)
+	}
+	want := []time.Duration{4 * time.Second, 4 * time.Second, 2 * time.Second}
+	if got := clock.Waits(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got waits %v, want %v", got, want)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := DefaultStrategy().Validate(); err != nil {
+		t.Errorf("default strategy is invalid: %v", err)
+	}
+	for _, s := range []Strategy{
+		{InitialDelay: -time.Second, Multiplier: 1, MaxDelay: time.Second},
+		{Multiplier: 0.5, MaxDelay: time.Second},
+		{Multiplier: 2},
+		{Multiplier: 1, MaxDelay: time.Second, MaxWait: -time.Second},
+	} {
+		if err := s.Validate(); err == nil {
+			t.Errorf("expected %+v to be invalid", s)
+		}
+	}
+}
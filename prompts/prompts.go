@@ -0,0 +1,171 @@
+// Package prompts defines MCP prompt templates for common audio
+// investigations. Each prompt tells the model which tools to call and how to
+// read the inference results they return.
+package prompts
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+type Argument struct {
+	Name        string
+	Description string
+	Required    bool
+	// Default is used when an optional argument is not given.
+	Default string
+}
+
+// Definition describes a prompt and the template its message is rendered
+// from. The template is executed with the prompt arguments as a
+// map[string]string.
+type Definition struct {
+	Name        string
+	Description string
+	Arguments   []Argument
+	Template    string
+}
+
+// resultsGuide is available to every template as {{template "results"}}.
+const resultsGuide = `{{define "results"}}analyze_audio returns a JSON array of segments. Each segment has:
+- start_time and end_time: the position of the segment in the file, in milliseconds. Segments overlap because the analysis window moves in half-window steps.
+- tags: the sounds detected in the segment, each with a name and a probability between 0 and 1. "Others" means no known sound was recognized.
+Merge consecutive segments with the same tag into one event, and report times as mm:ss. Use the cochl://tags resource or the search_sound_tags tool to look up what a tag means.{{end}}`
+
+// Registry lists every prompt the server offers.
+var Registry = []Definition{
+	{
+		Name:        "summarize_recording",
+		Description: "Summarize what can be heard in an audio recording, with a timeline of the main sound events.",
+		Arguments: []Argument{
+			{Name: "file_absolute_path", Description: "Absolute path of the audio file (mp3, ogg or wav).", Required: true},
+		},
+		Template: `Call the analyze_audio tool with file_absolute_path set to "{{.file_absolute_path}}".
+
+{{template "results"}}
+
+Then write a short summary of the recording:
+1. One or two sentences describing the overall soundscape.
+2. A timeline of the main sound events with their start and end times.
+3. The sounds that dominate the recording, with how long each is heard in total.
+Ignore tags with a probability below 0.5 unless nothing else was detected.`,
+	},
+	{
+		Name:        "find_safety_incidents",
+		Description: "Look for sounds that may indicate an emergency or safety incident, such as alarms, screams, glass breaking or gunshots.",
+		Arguments: []Argument{
+			{Name: "file_absolute_path", Description: "Absolute path of the audio file (mp3, ogg or wav).", Required: true},
+			{Name: "min_probability", Description: "Only report tags at or above this probability. Defaults to 0.6.", Default: "0.6"},
+		},
+		Template: `Call the analyze_audio tool with file_absolute_path set to "{{.file_absolute_path}}".
+
+{{template "results"}}
+
+Review the results for possible safety incidents. Consider every tag in the Emergency category of the cochl://tags resource, and other sounds that may signal danger such as screaming, crying, glass breaking, gunshots, explosions or smoke alarms. Only consider tags with a probability of at least {{.min_probability}}.
+
+For each incident report:
+- the time range,
+- the tags involved and their highest probability,
+- how urgent it seems and why.
+If there are no incidents, say so plainly and do not speculate.`,
+	},
+	{
+		Name:        "compare_recordings",
+		Description: "Compare the sounds detected in two audio recordings.",
+		Arguments: []Argument{
+			{Name: "first_file_absolute_path", Description: "Absolute path of the first audio file.", Required: true},
+			{Name: "second_file_absolute_path", Description: "Absolute path of the second audio file.", Required: true},
+		},
+		Template: `Call the analyze_audio tool twice: once with file_absolute_path set to "{{.first_file_absolute_path}}" and once with "{{.second_file_absolute_path}}".
+
+{{template "results"}}
+
+Compare the two recordings:
+1. Tags detected in both recordings, and how long each is heard in each one.
+2. Tags detected in only one of the recordings.
+3. Notable differences in when sounds occur or in their probabilities.
+Finish with a one-paragraph conclusion on how similar the recordings are.`,
+	},
+	{
+		Name:        "privacy_audit",
+		Description: "Check an audio recording for privacy-sensitive sounds, such as speech, before it is shared or stored.",
+		Arguments: []Argument{
+			{Name: "file_absolute_path", Description: "Absolute path of the audio file (mp3, ogg or wav).", Required: true},
+		},
+		Template: `Call the analyze_audio tool with file_absolute_path set to "{{.file_absolute_path}}".
+
+{{template "results"}}
+
+Audit the recording for privacy-sensitive sounds. These include human voices (speech, conversation, whispering, singing, laughing, coughing), and sounds that can identify a person or place, such as doorbells, phone rings or children.
+
+Report:
+- every time range containing a privacy-sensitive sound, with the tags involved,
+- the share of the recording's duration they cover,
+- whether the recording looks safe to share as is, or which parts should be cut or muted.
+Audio analysis cannot tell what was said, so do not guess at the content of any speech.`,
+	},
+}
+
+// Register adds every prompt in the registry to s.
+func Register(s *server.MCPServer) {
+	for _, d := range Registry {
+		s.AddPrompt(d.Prompt(), d.Handler())
+	}
+}
+
+// Prompt returns the MCP description of the prompt.
+func (d Definition) Prompt() mcp.Prompt {
+	opts := []mcp.PromptOption{mcp.WithPromptDescription(d.Description)}
+	for _, a := range d.Arguments {
+		argOpts := []mcp.ArgumentOption{mcp.ArgumentDescription(a.Description)}
+		if a.Required {
+			argOpts = append(argOpts, mcp.RequiredArgument())
+		}
+		opts = append(opts, mcp.WithArgument(a.Name, argOpts...))
+	}
+	return mcp.NewPrompt(d.Name, opts...)
+}
+
+func (d Definition) Handler() server.PromptHandlerFunc {
+	return func(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+		return d.Render(request.Params.Arguments)
+	}
+}
+
+// Render fills in the prompt template. Missing required arguments are an
+// error; missing optional ones take their default.
+func (d Definition) Render(args map[string]string) (*mcp.GetPromptResult, error) {
+	values := make(map[string]string, len(d.Arguments))
+	for _, a := range d.Arguments {
+		v := strings.TrimSpace(args[a.Name])
+		if v == "" {
+			if a.Required {
+				return nil, fmt.Errorf("missing required argument %q for prompt %s", a.Name, d.Name)
+			}
+			v = a.Default
+		}
+		values[a.Name] = v
+	}
+
+	tmpl, err := template.New(d.Name).Option("missingkey=error").Parse(resultsGuide)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse results guide: %v", err)
+	}
+	if _, err := tmpl.Parse(d.Template); err != nil {
+		return nil, fmt.Errorf("failed to parse prompt %s: %v", d.Name, err)
+	}
+
+	var text strings.Builder
+	if err := tmpl.Execute(&text, values); err != nil {
+		return nil, fmt.Errorf("failed to render prompt %s: %v", d.Name, err)
+	}
+
+	return mcp.NewGetPromptResult(d.Description, []mcp.PromptMessage{
+		mcp.NewPromptMessage(mcp.RoleUser, mcp.NewTextContent(text.String())),
+	}), nil
+}
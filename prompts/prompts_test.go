@@ -0,0 +1,106 @@
+package prompts
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+func renderText(t *testing.T, d Definition, args map[string]string) string {
+	t.Helper()
+
+	result, err := d.Render(args)
+	if err != nil {
+		t.Fatalf("failed to render %s: %v", d.Name, err)
+	}
+	if len(result.Messages) != 1 || result.Messages[0].Role != mcp.RoleUser {
+		t.Fatalf("expected a single user message, got %+v", result.Messages)
+	}
+	text, ok := result.Messages[0].Content.(mcp.TextContent)
+	if !ok {
+		t.Fatalf("expected text content, got %T", result.Messages[0].Content)
+	}
+	return text.Text
+}
+
+func TestRenderAll(t *testing.T) {
+	for _, d := range Registry {
+		t.Run(d.Name, func(t *testing.T) {
+			args := make(map[string]string)
+			for _, a := range d.Arguments {
+				if a.Required {
+					args[a.Name] = "/recordings/" + a.Name + ".wav"
+				}
+			}
+
+			text := renderText(t, d, args)
+			for _, want := range []string{"analyze_audio", "start_time", "probability"} {
+				if !strings.Contains(text, want) {
+					t.Errorf("expected prompt to mention %q:\n%s", want, text)
+				}
+			}
+			for name, value := range args {
+				if !strings.Contains(text, value) {
+					t.Errorf("expected prompt to contain argument %s=%q", name, value)
+				}
+			}
+			if strings.Contains(text, "<no value>") {
+				t.Errorf("prompt has unfilled placeholders:\n%s", text)
+			}
+		})
+	}
+}
+
+func TestRenderArguments(t *testing.T) {
+	var d Definition
+	for _, def := range Registry {
+		if def.Name == "find_safety_incidents" {
+			d = def
+		}
+	}
+
+	if _, err := d.Render(map[string]string{"file_absolute_path": "  "}); err == nil ||
+		!strings.Contains(err.Error(), `missing required argument "file_absolute_path"`) {
+		t.Errorf("expected missing argument error, got %v", err)
+	}
+
+	text := renderText(t, d, map[string]string{"file_absolute_path": "/a.wav"})
+	if !strings.Contains(text, "at least 0.6") {
+		t.Errorf("expected default min_probability in prompt:\n%s", text)
+	}
+
+	text = renderText(t, d, map[string]string{"file_absolute_path": "/a.wav", "min_probability": "0.8"})
+	if !strings.Contains(text, "at least 0.8") {
+		t.Errorf("expected given min_probability in prompt:\n%s", text)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	s := server.NewMCPServer("test", "1.0.0", server.WithPromptCapabilities(false))
+	Register(s)
+
+	req, _ := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": 1, "method": "prompts/list"})
+	resp, err := json.Marshal(s.HandleMessage(context.Background(), req))
+	if err != nil {
+		t.Fatalf("failed to marshal response: %v", err)
+	}
+
+	var msg struct {
+		Result mcp.ListPromptsResult `json:"result"`
+	}
+	if err := json.Unmarshal(resp, &msg); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(msg.Result.Prompts) != len(Registry) {
+		t.Fatalf("expected %d prompts, got %+v", len(Registry), msg.Result.Prompts)
+	}
+	for _, p := range msg.Result.Prompts {
+		if p.Description == "" || len(p.Arguments) == 0 || !p.Arguments[0].Required {
+			t.Errorf("unexpected prompt: %+v", p)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package resources
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+
+	"github.com/cochlearai/cochl-mcp-server/common"
+	"github.com/cochlearai/cochl-mcp-server/history"
+)
+
+const (
+	AnalysesURI         = "cochl://analyses"
+	analysisURIPrefix   = AnalysesURI + "/"
+	analysisURITemplate = analysisURIPrefix + "{id}"
+)
+
+// AnalysisURI returns the URI of the stored analysis with the given ID.
+func AnalysisURI(id string) string {
+	return analysisURIPrefix + id
+}
+
+func Analyses(store *history.Store) (resource mcp.Resource, handler server.ResourceHandlerFunc) {
+	resource = mcp.NewResource(AnalysesURI, "Analysis history",
+		mcp.WithResourceDescription(
+			"Earlier audio analyses, newest first, with file name, duration, time of analysis and "+
+				"the most frequent tags. Read cochl://analyses/{id} for the full result of one analysis.",
+		),
+		mcp.WithMIMEType("application/json"),
+	)
+
+	handler = func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		c, err := common.CochlSenseClient(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := json.Marshal(store.List(common.Tenant(c)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal analysis history: %v", err)
+		}
+
+		return []mcp.ResourceContents{
+			mcp.TextResourceContents{
+				URI:      request.Params.URI,
+				MIMEType: "application/json",
+				Text:     string(data),
+			},
+		}, nil
+	}
+
+	return resource, handler
+}
+
+func Analysis(store *history.Store) (template mcp.ResourceTemplate, handler server.ResourceTemplateHandlerFunc) {
+	template = mcp.NewResourceTemplate(analysisURITemplate, "Stored analysis",
+		mcp.WithTemplateDescription(
+			"The full result of an earlier audio analysis, including file details and every "+
+				"inference segment.",
+		),
+		mcp.WithTemplateMIMEType("application/json"),
+	)
+
+	handler = func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		c, err := common.CochlSenseClient(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		id := strings.TrimPrefix(request.Params.URI, analysisURIPrefix)
+		record, ok := store.Get(common.Tenant(c), id)
+		if !ok {
+			return nil, fmt.Errorf("analysis %q not found, read %s for the available analyses", id, AnalysesURI)
+		}
+
+		data, err := json.Marshal(record)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal analysis: %v", err)
+		}
+
+		return []mcp.ResourceContents{
+			mcp.TextResourceContents{
+				URI:      request.Params.URI,
+				MIMEType: "application/json",
+				Text:     string(data),
+			},
+		}, nil
+	}
+
+	return template, handler
+}
+
+// ListAnalyses adds the stored analyses of the caller's project key to
+// resources/list results, so clients can browse them without reading the
+// history listing first.
+func ListAnalyses(store *history.Store) server.OnAfterListResourcesFunc {
+	return func(ctx context.Context, id any, message *mcp.ListResourcesRequest, result *mcp.ListResourcesResult) {
+		c := common.CochlSenseClientFromContext(ctx)
+		if c == nil {
+			return
+		}
+		for _, s := range store.List(common.Tenant(c)) {
+			result.Resources = append(result.Resources, mcp.NewResource(
+				AnalysisURI(s.ID),
+				fmt.Sprintf("Analysis of %s (%s)", s.FileName, s.AnalyzedAt.Format("2006-01-02 15:04:05")),
+				mcp.WithMIMEType("application/json"),
+			))
+		}
+	}
+}
+
+// NotifyAnalysisAdded returns a history callback telling the sessions of the
+// record's owner that the resource list changed.
+func NotifyAnalysisAdded(sessions *common.Sessions) func(history.Record) {
+	return func(r history.Record) {
+		sessions.BroadcastTo(r.Owner, "notifications/resources/list_changed", nil)
+	}
+}
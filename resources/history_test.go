@@ -0,0 +1,200 @@
+package resources
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+
+	"github.com/cochlearai/cochl-mcp-server/client"
+	"github.com/cochlearai/cochl-mcp-server/common"
+	"github.com/cochlearai/cochl-mcp-server/history"
+)
+
+type testSession struct {
+	id            string
+	ctx           context.Context
+	notifications chan mcp.JSONRPCNotification
+}
+
+func (s *testSession) SessionID() string { return s.id }
+func (s *testSession) NotificationChannel() chan<- mcp.JSONRPCNotification {
+	return s.notifications
+}
+func (s *testSession) Initialize()       {}
+func (s *testSession) Initialized() bool { return true }
+
+func newHistoryServer(t *testing.T) (*server.MCPServer, *history.Store) {
+	t.Helper()
+
+	store, err := history.Open("", 10)
+	if err != nil {
+		t.Fatalf("failed to open history: %v", err)
+	}
+
+	sessions := common.NewSessions()
+	hooks := &server.Hooks{}
+	hooks.AddOnRegisterSession(sessions.Register)
+	hooks.AddBeforeAny(sessions.Identify)
+	hooks.AddAfterListResources(ListAnalyses(store))
+	store.OnAdd(NotifyAnalysisAdded(sessions))
+
+	s := server.NewMCPServer("test", "1.0.0", server.WithResourceCapabilities(false, true), server.WithHooks(hooks))
+	s.AddResource(Analyses(store))
+	s.AddResourceTemplate(Analysis(store))
+
+	return s, store
+}
+
+// connect registers a session whose requests use a client with key.
+func connect(t *testing.T, s *server.MCPServer, key string) *testSession {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	session := &testSession{id: key, notifications: make(chan mcp.JSONRPCNotification, 10)}
+	session.ctx = s.WithContext(common.WithCochlSenseClient(ctx, client.NewCochlSense(key, "http://127.0.0.1", "test")), session)
+	if err := s.RegisterSession(ctx, session); err != nil {
+		t.Fatalf("failed to register session: %v", err)
+	}
+	handle(t, s, session, "ping", nil)
+	return session
+}
+
+func handle(t *testing.T, s *server.MCPServer, session *testSession, method string, params any) json.RawMessage {
+	t.Helper()
+
+	req, err := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": 1, "method": method, "params": params})
+	if err != nil {
+		t.Fatalf("failed to marshal request: %v", err)
+	}
+	resp, err := json.Marshal(s.HandleMessage(session.ctx, req))
+	if err != nil {
+		t.Fatalf("failed to marshal response: %v", err)
+	}
+
+	var msg struct {
+		Result json.RawMessage `json:"result"`
+		Error  *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(resp, &msg); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if msg.Error != nil {
+		return json.RawMessage(fmt.Sprintf(`{"error":%q}`, msg.Error.Message))
+	}
+	return msg.Result
+}
+
+func readText(t *testing.T, s *server.MCPServer, session *testSession, uri string) string {
+	t.Helper()
+
+	var result struct {
+		Contents []mcp.TextResourceContents `json:"contents"`
+		Error    string                     `json:"error"`
+	}
+	if err := json.Unmarshal(handle(t, s, session, "resources/read", map[string]any{"uri": uri}), &result); err != nil {
+		t.Fatalf("failed to decode read result: %v", err)
+	}
+	if result.Error != "" {
+		return result.Error
+	}
+	if len(result.Contents) != 1 {
+		t.Fatalf("expected 1 content, got %d", len(result.Contents))
+	}
+	return result.Contents[0].Text
+}
+
+func TestHistoryResources(t *testing.T) {
+	s, store := newHistoryServer(t)
+	session := connect(t, s, "alice-key")
+	other := connect(t, s, "bob-key")
+
+	record, err := store.Add(history.Record{
+		Owner:    common.Tenant(client.NewCochlSense("alice-key", "http://127.0.0.1", "test")),
+		FileName: "doorbell.wav",
+		Duration: 4.5,
+		Results: []client.InferenceResult{
+			{StartTime: 0, EndTime: 1000, Tags: []client.Tags{{Name: "Doorbell", Probability: 0.95}}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to add record: %v", err)
+	}
+
+	want := []string{"notifications/resources/list_changed"}
+	var got []string
+	for len(got) < len(want) {
+		select {
+		case n := <-session.notifications:
+			got = append(got, n.Method)
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for notifications, got %v", got)
+		}
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("got notifications %v, want %v", got, want)
+	}
+
+	var list struct {
+		Resources []mcp.Resource `json:"resources"`
+	}
+	if err := json.Unmarshal(handle(t, s, session, "resources/list", map[string]any{}), &list); err != nil {
+		t.Fatalf("failed to decode list result: %v", err)
+	}
+	uris := make(map[string]bool)
+	for _, r := range list.Resources {
+		uris[r.URI] = true
+	}
+	if !uris[AnalysesURI] || !uris[AnalysisURI(record.ID)] {
+		t.Errorf("expected history listing and analysis in resources, got %+v", list.Resources)
+	}
+
+	var summaries []history.Summary
+	if err := json.Unmarshal([]byte(readText(t, s, session, AnalysesURI)), &summaries); err != nil {
+		t.Fatalf("failed to decode history listing: %v", err)
+	}
+	if len(summaries) != 1 || summaries[0].ID != record.ID || summaries[0].TopTags[0].Name != "Doorbell" {
+		t.Errorf("unexpected history listing: %+v", summaries)
+	}
+
+	var stored history.Record
+	if err := json.Unmarshal([]byte(readText(t, s, session, AnalysisURI(record.ID))), &stored); err != nil {
+		t.Fatalf("failed to decode analysis: %v", err)
+	}
+	if stored.ID != record.ID || stored.FileName != "doorbell.wav" || len(stored.Results) != 1 {
+		t.Errorf("unexpected analysis: %+v", stored)
+	}
+
+	if msg := readText(t, s, session, AnalysisURI("missing")); !strings.Contains(msg, "not found") {
+		t.Errorf("expected not found error, got %q", msg)
+	}
+
+	select {
+	case n := <-other.notifications:
+		t.Errorf("expected no notification for another project key, got %s", n.Method)
+	default:
+	}
+	if err := json.Unmarshal(handle(t, s, other, "resources/list", map[string]any{}), &list); err != nil {
+		t.Fatalf("failed to decode list result: %v", err)
+	}
+	for _, r := range list.Resources {
+		if r.URI == AnalysisURI(record.ID) {
+			t.Errorf("expected the analysis not to be listed for another project key")
+		}
+	}
+	if text := readText(t, s, other, AnalysesURI); text != "[]" {
+		t.Errorf("expected an empty history listing for another project key, got %s", text)
+	}
+	if msg := readText(t, s, other, AnalysisURI(record.ID)); !strings.Contains(msg, "not found") {
+		t.Errorf("expected another project key not to read the analysis, got %q", msg)
+	}
+}
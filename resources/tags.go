@@ -0,0 +1,41 @@
+package resources
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+
+	"github.com/cochlearai/cochl-mcp-server/taxonomy"
+)
+
+const TagsURI = "cochl://tags"
+
+func Tags(t *taxonomy.Taxonomy) (resource mcp.Resource, handler server.ResourceHandlerFunc) {
+	resource = mcp.NewResource(TagsURI, "Sound tag taxonomy",
+		mcp.WithResourceDescription(
+			"Every sound tag Cochl Sense can return, grouped into categories with a description "+
+				"of each tag. Includes the taxonomy version.",
+		),
+		mcp.WithMIMEType("application/json"),
+	)
+
+	handler = func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		data, err := json.Marshal(t)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal taxonomy: %v", err)
+		}
+
+		return []mcp.ResourceContents{
+			mcp.TextResourceContents{
+				URI:      request.Params.URI,
+				MIMEType: "application/json",
+				Text:     string(data),
+			},
+		}, nil
+	}
+
+	return resource, handler
+}
@@ -0,0 +1,52 @@
+package resources
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+
+	"github.com/cochlearai/cochl-mcp-server/common"
+	"github.com/cochlearai/cochl-mcp-server/usage"
+)
+
+const UsageURI = "cochl://usage"
+
+func Usage() (resource mcp.Resource, handler server.ResourceHandlerFunc) {
+	resource = mcp.NewResource(UsageURI, "Cochl Sense usage",
+		mcp.WithResourceDescription(
+			"Audio submitted to Cochl Sense with the project key of this session today, this month "+
+				"and in total, by client, with the configured limits and what they still allow.",
+		),
+		mcp.WithMIMEType("application/json"),
+	)
+
+	handler = func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		c, err := common.CochlSenseClient(ctx)
+		if err != nil {
+			return nil, err
+		}
+		report, ok := usage.ReportFor(c)
+		if !ok {
+			return nil, errors.New("usage is not tracked for this session: the server runs in mock mode or has no project key")
+		}
+
+		data, err := json.Marshal(report)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal usage: %v", err)
+		}
+
+		return []mcp.ResourceContents{
+			mcp.TextResourceContents{
+				URI:      request.Params.URI,
+				MIMEType: "application/json",
+				Text:     string(data),
+			},
+		}, nil
+	}
+
+	return resource, handler
+}
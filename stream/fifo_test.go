@@ -0,0 +1,86 @@
+//go:build unix
+
+package stream
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/cochlearai/cochl-mcp-server/client/fakesense"
+)
+
+// TestRunFIFO plays a recording into a named pipe in real time, one second at
+// a time, and expects detections before the recording ends.
+func TestRunFIFO(t *testing.T) {
+	recording, err := os.ReadFile(testWAV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fifo := filepath.Join(t.TempDir(), "live.wav")
+	if err := syscall.Mkfifo(fifo, 0o600); err != nil {
+		t.Skipf("named pipes are not supported: %v", err)
+	}
+
+	header := bytes.Index(recording, []byte("data")) + 8
+
+	written := make(chan time.Time, 1)
+	go func() {
+		w, err := os.OpenFile(fifo, os.O_WRONLY, 0)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer w.Close()
+
+		// The audio is 48 kHz mono.
+		const second = 48000 * 2
+		w.Write(recording[:header])
+		for data := recording[header:]; len(data) > 0; {
+			n := min(second, len(data))
+			w.Write(data[:n])
+			data = data[n:]
+			time.Sleep(20 * time.Millisecond)
+		}
+		written <- time.Now()
+	}()
+
+	r, err := os.Open(fifo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	src, err := NewSource(r, "wav", Format{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	format := Format{SampleRate: 48000, Channels: 1}
+	fake, c, session := startStream(t, format, fakesense.WithResults(segments(10000)...))
+	var first time.Time
+	result, err := Run(context.Background(), c, session, src, Options{
+		PollInterval: 5 * time.Millisecond,
+		OnDetections: func([]Detection) {
+			if first.IsZero() {
+				first = time.Now()
+			}
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !first.Before(<-written) {
+		t.Error("expected detections while the recording was still playing")
+	}
+	if n := len(result.Detections); n != len(segments(10000)) {
+		t.Errorf("got %d detections, want %d", n, len(segments(10000)))
+	}
+	if got, want := len(fake.Sessions()[0].Data()), len(recording)-header; got != want {
+		t.Errorf("got %d bytes streamed, want %d", got, want)
+	}
+}
@@ -0,0 +1,368 @@
+// Package stream analyzes live audio with Cochl Sense stream sessions. Audio
+// read from a named pipe, stdin or any other reader is uploaded in fixed-size
+// chunks as it arrives while the results are polled, and every detection is
+// reported with how long after its audio was uploaded it arrived.
+package stream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/cochlearai/cochl-mcp-server/client"
+	"github.com/cochlearai/cochl-mcp-server/poll"
+)
+
+const (
+	// DefaultChunkDuration is the audio uploaded at a time unless
+	// Options.ChunkDuration is set.
+	DefaultChunkDuration = time.Second
+	// DefaultPollInterval is the delay between result requests unless
+	// Options.PollInterval is set.
+	DefaultPollInterval = 500 * time.Millisecond
+)
+
+// Format describes raw PCM audio of 16-bit little-endian samples.
+type Format struct {
+	SampleRate int
+	Channels   int
+}
+
+// ContentType returns the content type of a stream session of this format.
+func (f Format) ContentType() string {
+	return fmt.Sprintf("audio/x-raw; rate=%d; format=s16le; channels=%d", f.SampleRate, f.Channels)
+}
+
+// Validate reports a format that cannot be streamed.
+func (f Format) Validate() error {
+	switch {
+	case f.SampleRate <= 0:
+		return errors.New("the sample rate must be positive")
+	case f.Channels <= 0:
+		return errors.New("the number of channels must be positive")
+	}
+	return nil
+}
+
+func (f Format) frameSize() int      { return 2 * f.Channels }
+func (f Format) bytesPerSecond() int { return f.SampleRate * f.frameSize() }
+
+// Source is live audio in a known format.
+type Source struct {
+	Format Format
+	r      io.Reader
+}
+
+// NewSource reads audio from r in the given container: "wav", whose header
+// is read right away to learn the format, or "pcm", raw audio in format.
+func NewSource(r io.Reader, container string, format Format) (*Source, error) {
+	switch container {
+	case "wav":
+		var err error
+		if format, err = readWAVHeader(r); err != nil {
+			return nil, err
+		}
+	case "pcm":
+	default:
+		return nil, fmt.Errorf("unsupported stream format %q, expected wav or pcm", container)
+	}
+	if err := format.Validate(); err != nil {
+		return nil, err
+	}
+	return &Source{Format: format, r: r}, nil
+}
+
+// Options configures Run.
+type Options struct {
+	// ChunkDuration is the length of audio uploaded at a time.
+	ChunkDuration time.Duration
+	// MaxDuration stops reading after this much audio. Zero reads until the
+	// end of the source.
+	MaxDuration time.Duration
+	// PollInterval is the delay between result requests.
+	PollInterval time.Duration
+	Clock        poll.Clock
+	// Stop, when closed, ends reading early. The stream is then closed and
+	// its remaining results fetched as if the source had ended.
+	Stop <-chan struct{}
+	// OnDetections is called with each batch of new detections, in order.
+	OnDetections func([]Detection)
+}
+
+// Detection is a segment of the stream's results.
+type Detection struct {
+	client.InferenceResult
+	// LatencyMs is the time from uploading the chunk that completed the
+	// segment to receiving the segment, in milliseconds.
+	LatencyMs int64 `json:"latency_ms"`
+}
+
+// Result is the outcome of a stream analysis.
+type Result struct {
+	Detections []Detection `json:"detections"`
+	Stats      Stats       `json:"stats"`
+}
+
+// Stats summarizes a stream analysis.
+type Stats struct {
+	Chunks       int          `json:"chunks"`
+	Bytes        int          `json:"bytes"`
+	AudioSeconds float64      `json:"audio_seconds"`
+	Detections   int          `json:"detections"`
+	Latency      LatencyStats `json:"latency_ms"`
+}
+
+// LatencyStats summarizes detection latencies, in milliseconds.
+type LatencyStats struct {
+	Min  int64 `json:"min"`
+	Mean int64 `json:"mean"`
+	P95  int64 `json:"p95"`
+	Max  int64 `json:"max"`
+}
+
+// upload records when the audio up to endMs was uploaded.
+type upload struct {
+	endMs int
+	at    time.Time
+}
+
+type streamer struct {
+	c       client.CochlSense
+	session string
+	src     *Source
+	opts    Options
+
+	mu sync.Mutex
+	// uploads is appended to by the upload loop and read by the poller.
+	uploads []upload
+	result  Result
+}
+
+// Run streams src to the open stream session and polls its results until
+// the source ends, MaxDuration is reached or Stop is closed, and every
+// uploaded chunk is analyzed. It returns the detections received so far with
+// any error. Run does not close src; a read still blocked when Run returns
+// ends when the caller closes it.
+func Run(ctx context.Context, c client.CochlSense, session *client.RespCreateSession, src *Source, opts Options) (*Result, error) {
+	if opts.ChunkDuration <= 0 {
+		opts.ChunkDuration = DefaultChunkDuration
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = DefaultPollInterval
+	}
+	if opts.Clock == nil {
+		opts.Clock = poll.SystemClock
+	}
+	s := &streamer{
+		c:       c,
+		session: session.SessionID,
+		src:     src,
+		opts:    opts,
+		result:  Result{Detections: []Detection{}},
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	polled := make(chan struct{})
+	go func() {
+		defer close(polled)
+		if err := s.poll(ctx); err != nil {
+			cancel(err)
+		}
+	}()
+
+	err := s.upload(ctx, session.ChunkSequence)
+	if err == nil {
+		if err = c.CloseStream(ctx, s.session); err != nil {
+			err = fmt.Errorf("failed to close stream: %w", err)
+		}
+	}
+	if err != nil {
+		cancel(err)
+	}
+	<-polled
+
+	s.result.Stats.Detections = len(s.result.Detections)
+	s.result.Stats.Latency = latencyStats(s.result.Detections)
+	return &s.result, context.Cause(ctx)
+}
+
+// upload reads chunks from the source and uploads them until it ends.
+func (s *streamer) upload(ctx context.Context, seq int) error {
+	bytesPerSecond := s.src.Format.bytesPerSecond()
+	frame := s.src.Format.frameSize()
+	chunkSize := max(int(s.opts.ChunkDuration.Seconds()*float64(bytesPerSecond))/frame*frame, frame)
+	remaining := -1
+	if s.opts.MaxDuration > 0 {
+		remaining = int(s.opts.MaxDuration.Seconds()*float64(bytesPerSecond)) / frame * frame
+	}
+
+	chunks := make(chan []byte)
+	readErr := make(chan error, 1)
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		defer close(chunks)
+		for remaining != 0 {
+			size := chunkSize
+			if remaining > 0 {
+				size = min(size, remaining)
+				remaining -= size
+			}
+			buf := make([]byte, size)
+			n, err := io.ReadFull(s.src.r, buf)
+			// A trailing partial sample is dropped.
+			if n -= n % frame; n > 0 {
+				select {
+				case chunks <- buf[:n]:
+				case <-stop:
+					return
+				}
+			}
+			if err != nil {
+				if !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+					readErr <- fmt.Errorf("failed to read audio: %w", err)
+				}
+				return
+			}
+		}
+	}()
+
+	for {
+		var chunk []byte
+		select {
+		case c, ok := <-chunks:
+			if !ok {
+				select {
+				case err := <-readErr:
+					return err
+				default:
+					return nil
+				}
+			}
+			chunk = c
+		case <-s.opts.Stop:
+			return nil
+		case <-ctx.Done():
+			return context.Cause(ctx)
+		}
+
+		resp, err := s.c.UploadChunk(ctx, s.session, seq, chunk)
+		if err != nil {
+			return fmt.Errorf("failed to upload chunk %d: %w", seq, err)
+		}
+		seq = resp.ChunkSequence
+
+		s.result.Stats.Chunks++
+		s.result.Stats.Bytes += len(chunk)
+		s.result.Stats.AudioSeconds = float64(s.result.Stats.Bytes) / float64(bytesPerSecond)
+		s.mu.Lock()
+		s.uploads = append(s.uploads, upload{
+			endMs: s.result.Stats.Bytes * 1000 / bytesPerSecond,
+			at:    s.opts.Clock.Now(),
+		})
+		s.mu.Unlock()
+	}
+}
+
+// poll fetches results until the session is done, following pages as soon
+// as they are available.
+func (s *streamer) poll(ctx context.Context) error {
+	schedule := poll.Strategy{
+		InitialDelay: s.opts.PollInterval,
+		Multiplier:   1,
+		MaxDelay:     s.opts.PollInterval,
+		Clock:        s.opts.Clock,
+	}.NewSchedule(0)
+
+	var (
+		nextToken  string
+		retryAfter time.Duration
+		more       bool
+		received   int
+	)
+	for {
+		if !more {
+			if err := schedule.Wait(ctx, retryAfter); err != nil {
+				return err
+			}
+		}
+
+		res, err := s.c.GetInferenceResult(ctx, s.session, nextToken)
+		if err != nil {
+			return fmt.Errorf("failed to get stream results: %w", err)
+		}
+		retryAfter = res.RetryAfter
+
+		segments := res.Data
+		if page := res.Page; page != nil {
+			if page.NextToken != "" {
+				nextToken = page.NextToken
+			}
+			more = page.HasMore()
+		} else {
+			// Without pages, every response holds all segments so far.
+			segments = segments[min(received, len(segments)):]
+			more = false
+		}
+		received += len(segments)
+		s.detect(segments)
+
+		if res.State == "done" && !more {
+			return nil
+		}
+	}
+}
+
+// detect records segments as detections.
+func (s *streamer) detect(segments []client.InferenceResult) {
+	if len(segments) == 0 {
+		return
+	}
+
+	now := s.opts.Clock.Now()
+	s.mu.Lock()
+	uploads := s.uploads
+	s.mu.Unlock()
+
+	detections := make([]Detection, 0, len(segments))
+	for _, segment := range segments {
+		d := Detection{InferenceResult: segment}
+		i, _ := slices.BinarySearchFunc(uploads, segment.EndTime, func(u upload, endMs int) int {
+			return u.endMs - endMs
+		})
+		if i < len(uploads) {
+			d.LatencyMs = now.Sub(uploads[i].at).Milliseconds()
+		}
+		detections = append(detections, d)
+	}
+	s.result.Detections = append(s.result.Detections, detections...)
+	if s.opts.OnDetections != nil {
+		s.opts.OnDetections(detections)
+	}
+}
+
+func latencyStats(detections []Detection) LatencyStats {
+	if len(detections) == 0 {
+		return LatencyStats{}
+	}
+
+	latencies := make([]int64, len(detections))
+	var sum int64
+	for i, d := range detections {
+		latencies[i] = d.LatencyMs
+		sum += d.LatencyMs
+	}
+	slices.Sort(latencies)
+	return LatencyStats{
+		Min:  latencies[0],
+		Mean: sum / int64(len(latencies)),
+		P95:  latencies[(len(latencies)*95+99)/100-1],
+		Max:  latencies[len(latencies)-1],
+	}
+}
@@ -0,0 +1,211 @@
+package stream
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cochlearai/cochl-mcp-server/client"
+	"github.com/cochlearai/cochl-mcp-server/client/fakesense"
+)
+
+const testWAV = "../util/audio/testdata/wav-test.wav"
+
+// segments returns one-second segments every half second up to endMs.
+func segments(endMs int) []client.InferenceResult {
+	var results []client.InferenceResult
+	for start := 0; start+1000 <= endMs; start += 500 {
+		results = append(results, client.InferenceResult{
+			StartTime: start,
+			EndTime:   start + 1000,
+			Tags:      []client.Tags{{Name: "Speech", Probability: 0.9}},
+		})
+	}
+	return results
+}
+
+// startStream creates a stream session on a new fake server.
+func startStream(t *testing.T, format Format, opts ...fakesense.Option) (*fakesense.Server, client.CochlSense, *client.RespCreateSession) {
+	t.Helper()
+
+	fake := fakesense.NewServer(opts...)
+	t.Cleanup(fake.Close)
+	c := client.NewCochlSense("test-key", fake.URL, "test")
+	session, err := c.CreateStreamSession(context.Background(), format.ContentType())
+	if err != nil {
+		t.Fatalf("failed to create stream session: %v", err)
+	}
+	return fake, c, session
+}
+
+func TestNewSource(t *testing.T) {
+	f, err := os.Open(testWAV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	src, err := NewSource(f, "wav", Format{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := (Format{SampleRate: 48000, Channels: 1}); src.Format != want {
+		t.Errorf("got format %+v, want %+v", src.Format, want)
+	}
+
+	for _, tt := range []struct {
+		name      string
+		data      string
+		container string
+		format    Format
+		wantErr   string
+	}{
+		{name: "Not a WAV file", data: "RIFF\x00\x00\x00\x00AVI LIST", container: "wav", wantErr: "invalid WAV header"},
+		{name: "Truncated WAV header", data: "RIFF\x00\x00\x00\x00WAVE", container: "wav", wantErr: "failed to read WAV header"},
+		{name: "Oversized format chunk", data: "RIFF\x00\x00\x00\x00WAVEfmt \xf0\xff\xff\xff\x01\x00\x01\x00\x80\xbb\x00\x00\x00\x77\x01\x00\x02\x00\x10\x00", container: "wav", wantErr: "failed to read WAV header: EOF"},
+		{name: "Unknown container", container: "mp3", wantErr: "unsupported stream format"},
+		{name: "PCM without sample rate", container: "pcm", format: Format{Channels: 1}, wantErr: "sample rate"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewSource(strings.NewReader(tt.data), tt.container, tt.format)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("got error %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	format := Format{SampleRate: 8000, Channels: 1}
+	want := segments(3000)
+	fake, c, session := startStream(t, format, fakesense.WithResults(want...), fakesense.WithPagedResults(2))
+
+	audio := make([]byte, 3*8000*2+1)
+	src, err := NewSource(bytes.NewReader(audio), "pcm", format)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var reported []client.InferenceResult
+	result, err := Run(context.Background(), c, session, src, Options{
+		ChunkDuration: 500 * time.Millisecond,
+		PollInterval:  time.Millisecond,
+		OnDetections: func(detections []Detection) {
+			for _, d := range detections {
+				reported = append(reported, d.InferenceResult)
+			}
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var got []client.InferenceResult
+	for _, d := range result.Detections {
+		got = append(got, d.InferenceResult)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got detections %+v, want %+v", got, want)
+	}
+	if !reflect.DeepEqual(reported, want) {
+		t.Errorf("got reported detections %+v, want %+v", reported, want)
+	}
+
+	// The odd trailing byte is not a whole sample and is dropped.
+	wantStats := Stats{Chunks: 6, Bytes: 3 * 8000 * 2, AudioSeconds: 3, Detections: len(want)}
+	gotStats := result.Stats
+	gotStats.Latency = LatencyStats{}
+	if gotStats != wantStats {
+		t.Errorf("got stats %+v, want %+v", gotStats, wantStats)
+	}
+	if l := result.Stats.Latency; l.Min > l.Mean || l.Mean > l.P95 || l.P95 > l.Max {
+		t.Errorf("inconsistent latency stats %+v", l)
+	}
+
+	s := fake.Sessions()[0]
+	if s.Type != "stream" || s.ContentType != "audio/x-raw; rate=8000; format=s16le; channels=1" {
+		t.Errorf("got session type %q and content type %q", s.Type, s.ContentType)
+	}
+	if !s.ReadOnly || len(s.Chunks) != 6 || len(s.Chunks[0]) != 8000 {
+		t.Errorf("expected six chunks of half a second in a closed stream, got %+v", s)
+	}
+}
+
+func TestRunMaxDuration(t *testing.T) {
+	format := Format{SampleRate: 8000, Channels: 2}
+	fake, c, session := startStream(t, format)
+
+	src, err := NewSource(bytes.NewReader(make([]byte, 10*8000*4)), "pcm", format)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := Run(context.Background(), c, session, src, Options{
+		MaxDuration:  1500 * time.Millisecond,
+		PollInterval: time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Stats.AudioSeconds != 1.5 || result.Stats.Chunks != 2 {
+		t.Errorf("got stats %+v, want 1.5 s in 2 chunks", result.Stats)
+	}
+	if n := len(fake.Sessions()[0].Data()); n != 1500*8*4 {
+		t.Errorf("got %d bytes uploaded, want %d", n, 1500*8*4)
+	}
+}
+
+func TestRunStop(t *testing.T) {
+	format := Format{SampleRate: 8000, Channels: 1}
+	fake, c, session := startStream(t, format, fakesense.WithResults(segments(10000)...))
+
+	r, w := io.Pipe()
+	defer w.Close()
+	src, err := NewSource(r, "pcm", format)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stop := make(chan struct{})
+	var once sync.Once
+	go w.Write(make([]byte, 2*8000*2))
+	result, err := Run(context.Background(), c, session, src, Options{
+		PollInterval: time.Millisecond,
+		Stop:         stop,
+		OnDetections: func([]Detection) { once.Do(func() { close(stop) }) },
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Once the stream is closed, the fake releases all of its segments.
+	if !fake.Sessions()[0].ReadOnly {
+		t.Error("expected the stream to be closed")
+	}
+	if n := len(result.Detections); n != len(segments(10000)) {
+		t.Errorf("got %d detections after closing the stream, want %d", n, len(segments(10000)))
+	}
+}
+
+func TestRunUploadError(t *testing.T) {
+	format := Format{SampleRate: 8000, Channels: 1}
+	fake, c, session := startStream(t, format)
+	fake.Script(fakesense.EndpointUploadChunk, fakesense.Response{Status: 500, Body: `{"error":"boom"}`})
+
+	src, err := NewSource(bytes.NewReader(make([]byte, 8000*2)), "pcm", format)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = Run(context.Background(), c, session, src, Options{PollInterval: time.Millisecond})
+	if err == nil || !strings.Contains(err.Error(), "failed to upload chunk 0") {
+		t.Errorf("got error %v, want an upload failure", err)
+	}
+	if fake.Sessions()[0].ReadOnly {
+		t.Error("expected the failed stream to stay open")
+	}
+}
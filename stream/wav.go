@@ -0,0 +1,72 @@
+package stream
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// pcmFormatSize is the part of a format chunk describing PCM audio. Any
+// extension after it is skipped, so a chunk's untrusted size never decides
+// how much memory is allocated.
+const pcmFormatSize = 16
+
+// readWAVHeader reads a WAV header up to the start of its audio data, which
+// can be streamed as raw PCM. The sizes in the header are ignored since a
+// live recording does not know them when it starts.
+func readWAVHeader(r io.Reader) (Format, error) {
+	riff := make([]byte, 12)
+	if _, err := io.ReadFull(r, riff); err != nil {
+		return Format{}, fmt.Errorf("failed to read WAV header: %w", err)
+	}
+	if string(riff[0:4]) != "RIFF" || string(riff[8:12]) != "WAVE" {
+		return Format{}, errors.New("invalid WAV header")
+	}
+
+	var (
+		format    Format
+		formatSet bool
+	)
+	chunk := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(r, chunk); err != nil {
+			return Format{}, fmt.Errorf("failed to read WAV header: %w", err)
+		}
+		id := string(chunk[0:4])
+		size := int64(binary.LittleEndian.Uint32(chunk[4:8]))
+
+		switch id {
+		case "fmt ":
+			if size < pcmFormatSize {
+				return Format{}, errors.New("invalid WAV format chunk")
+			}
+			body := make([]byte, pcmFormatSize)
+			if _, err := io.ReadFull(r, body); err != nil {
+				return Format{}, fmt.Errorf("failed to read WAV header: %w", err)
+			}
+			if _, err := io.CopyN(io.Discard, r, size+size%2-pcmFormatSize); err != nil {
+				return Format{}, fmt.Errorf("failed to read WAV header: %w", err)
+			}
+			encoding := binary.LittleEndian.Uint16(body[0:2])
+			bits := binary.LittleEndian.Uint16(body[14:16])
+			if encoding != 1 || bits != 16 {
+				return Format{}, fmt.Errorf("only 16-bit PCM WAV audio can be streamed, got encoding %d with %d bits", encoding, bits)
+			}
+			format.Channels = int(binary.LittleEndian.Uint16(body[2:4]))
+			format.SampleRate = int(binary.LittleEndian.Uint32(body[4:8]))
+			formatSet = true
+
+		case "data":
+			if !formatSet {
+				return Format{}, errors.New("WAV data chunk precedes its format chunk")
+			}
+			return format, nil
+
+		default:
+			if _, err := io.CopyN(io.Discard, r, size+size%2); err != nil {
+				return Format{}, fmt.Errorf("failed to read WAV header: %w", err)
+			}
+		}
+	}
+}
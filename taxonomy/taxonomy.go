@@ -0,0 +1,278 @@
+// Package taxonomy describes the sound tags Cochl Sense can return, grouped
+// into categories. The taxonomy is embedded in the binary and versioned so
+// clients can tell which tag set they are looking at.
+package taxonomy
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+//go:embed tags.json
+var tagsJSON []byte
+
+type Taxonomy struct {
+	Version    string     `json:"version"`
+	Categories []Category `json:"categories"`
+
+	byName map[string]Tag
+}
+
+type Category struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Tags        []Tag  `json:"tags"`
+}
+
+type Tag struct {
+	Name        string `json:"name"`
+	Category    string `json:"category,omitempty"`
+	Description string `json:"description"`
+}
+
+// Match is a search result. Score ranges from 0 to 1, where 1 is an exact
+// name match.
+type Match struct {
+	Tag
+	Score float64 `json:"score"`
+}
+
+var defaultTaxonomy = mustParse(tagsJSON)
+
+// Default returns the embedded taxonomy.
+func Default() *Taxonomy {
+	return defaultTaxonomy
+}
+
+// Parse decodes a taxonomy from JSON.
+func Parse(data []byte) (*Taxonomy, error) {
+	var t Taxonomy
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, fmt.Errorf("failed to parse taxonomy: %w", err)
+	}
+
+	t.byName = make(map[string]Tag)
+	for i := range t.Categories {
+		c := &t.Categories[i]
+		for j := range c.Tags {
+			c.Tags[j].Category = c.Name
+			key := normalize(c.Tags[j].Name)
+			if _, ok := t.byName[key]; ok {
+				return nil, fmt.Errorf("duplicate tag %q in taxonomy", c.Tags[j].Name)
+			}
+			t.byName[key] = c.Tags[j]
+		}
+	}
+	return &t, nil
+}
+
+func mustParse(data []byte) *Taxonomy {
+	t, err := Parse(data)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// Tags returns every tag in taxonomy order.
+func (t *Taxonomy) Tags() []Tag {
+	var tags []Tag
+	for _, c := range t.Categories {
+		tags = append(tags, c.Tags...)
+	}
+	return tags
+}
+
+// Category returns the category with the given name, ignoring case.
+func (t *Taxonomy) Category(name string) (Category, bool) {
+	for _, c := range t.Categories {
+		if strings.EqualFold(c.Name, name) {
+			return c, true
+		}
+	}
+	return Category{}, false
+}
+
+// Lookup returns the tag called name. Case and the choice of spaces, hyphens
+// or underscores between words are ignored, so "dog bark" finds "Dog_bark".
+func (t *Taxonomy) Lookup(name string) (Tag, bool) {
+	tag, ok := t.byName[normalize(name)]
+	return tag, ok
+}
+
+// Search ranks tags by how well their name, description or category match
+// query, tolerating typos in tag names. At most limit matches are returned;
+// a limit of zero or less returns every match.
+func (t *Taxonomy) Search(query string, limit int) []Match {
+	q := normalize(query)
+	if q == "" {
+		return nil
+	}
+
+	var matches []Match
+	for _, c := range t.Categories {
+		for _, tag := range c.Tags {
+			if s := score(q, tag); s > 0 {
+				matches = append(matches, Match{Tag: tag, Score: s})
+			}
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].Name < matches[j].Name
+	})
+
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
+
+// UnknownTagsError reports tag names that are not in the taxonomy, with the
+// closest known tags for each.
+type UnknownTagsError struct {
+	Unknown     []string
+	Suggestions map[string][]string
+}
+
+func (e *UnknownTagsError) Error() string {
+	var parts []string
+	for _, name := range e.Unknown {
+		part := fmt.Sprintf("%q", name)
+		if s := e.Suggestions[name]; len(s) > 0 {
+			part += fmt.Sprintf(" (did you mean %s?)", strings.Join(s, ", "))
+		}
+		parts = append(parts, part)
+	}
+	return "unknown sound tags: " + strings.Join(parts, "; ")
+}
+
+// Validate resolves names to tags. If any name is not in the taxonomy it
+// returns an *UnknownTagsError listing every such name.
+func (t *Taxonomy) Validate(names []string) ([]Tag, error) {
+	var tags []Tag
+	unknown := &UnknownTagsError{Suggestions: make(map[string][]string)}
+
+	for _, name := range names {
+		if tag, ok := t.Lookup(name); ok {
+			tags = append(tags, tag)
+			continue
+		}
+
+		unknown.Unknown = append(unknown.Unknown, name)
+		for _, m := range t.Search(name, 3) {
+			unknown.Suggestions[name] = append(unknown.Suggestions[name], m.Name)
+		}
+	}
+
+	if len(unknown.Unknown) > 0 {
+		return nil, unknown
+	}
+	return tags, nil
+}
+
+// normalize lowercases s and joins its words with single spaces.
+func normalize(s string) string {
+	s = strings.ToLower(s)
+	s = strings.NewReplacer("_", " ", "-", " ").Replace(s)
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func score(q string, tag Tag) float64 {
+	name := normalize(tag.Name)
+	desc := strings.ToLower(tag.Description)
+
+	switch {
+	case name == q:
+		return 1
+	case strings.HasPrefix(name, q):
+		return 0.9
+	case strings.Contains(name, q):
+		return 0.8
+	}
+
+	nameWords := strings.Fields(name)
+	queryWords := strings.Fields(q)
+
+	// Every query word appears as a whole word in the name or description.
+	if containsAllWords(nameWords, queryWords) {
+		return 0.75
+	}
+	if strings.Contains(desc, q) || containsAllWords(strings.Fields(normalize(desc)), queryWords) {
+		return 0.6
+	}
+	if strings.EqualFold(tag.Category, q) {
+		return 0.5
+	}
+
+	// Fall back to edit distance so typos such as "siern" still match. A typo
+	// of the whole name ranks above a typo of one word in a longer name.
+	if sim := similarity(q, name); sim >= 0.7 {
+		return 0.5 * sim
+	}
+	var total float64
+	for _, qw := range queryWords {
+		var best float64
+		for _, nw := range nameWords {
+			best = max(best, similarity(qw, nw))
+		}
+		total += best
+	}
+	if avg := total / float64(len(queryWords)); avg >= 0.7 {
+		return 0.45 * avg
+	}
+	return 0
+}
+
+func containsAllWords(words, want []string) bool {
+	set := make(map[string]bool, len(words))
+	for _, w := range words {
+		set[strings.Trim(w, ".,")] = true
+	}
+	for _, w := range want {
+		if !set[w] {
+			return false
+		}
+	}
+	return true
+}
+
+// similarity returns 1 minus the normalized edit distance of a and b, where
+// insertions, deletions, substitutions and transpositions of adjacent
+// characters each count as one edit.
+func similarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) == 0 && len(rb) == 0 {
+		return 1
+	}
+
+	// d[i][j] is the distance between ra[:i] and rb[:j].
+	d := make([][]int, len(ra)+1)
+	for i := range d {
+		d[i] = make([]int, len(rb)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+
+	return 1 - float64(d[len(ra)][len(rb)])/float64(max(len(ra), len(rb)))
+}
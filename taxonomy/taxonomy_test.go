@@ -0,0 +1,84 @@
+package taxonomy
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestLookup(t *testing.T) {
+	tax := Default()
+
+	for _, name := range []string{"Dog_bark", "dog bark", "DOG-BARK", "  dog   bark "} {
+		tag, ok := tax.Lookup(name)
+		if !ok || tag.Name != "Dog_bark" || tag.Category != "Animal" {
+			t.Errorf("Lookup(%q) = %+v, %v", name, tag, ok)
+		}
+	}
+	if _, ok := tax.Lookup("Dogbark"); ok {
+		t.Error("expected no exact match for Dogbark")
+	}
+}
+
+func TestParseRejectsDuplicates(t *testing.T) {
+	data := `{"version":"1","categories":[
+		{"name":"A","tags":[{"name":"Dog_bark"}]},
+		{"name":"B","tags":[{"name":"dog bark"}]}]}`
+	if _, err := Parse([]byte(data)); err == nil || !strings.Contains(err.Error(), "duplicate") {
+		t.Errorf("expected duplicate tag error, got %v", err)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	tax := Default()
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{query: "siren", want: "Siren"},
+		{query: "siern", want: "Siren"},
+		{query: "dog", want: "Dog_bark"},
+		{query: "bark dog", want: "Dog_bark"},
+	}
+	for _, tt := range tests {
+		matches := tax.Search(tt.query, 5)
+		if len(matches) == 0 || matches[0].Name != tt.want {
+			t.Errorf("Search(%q) = %+v, want %s first", tt.query, matches, tt.want)
+		}
+	}
+
+	if m := tax.Search("siren", 1); len(m) != 1 || m[0].Score != 1 {
+		t.Errorf("expected a single exact match, got %+v", m)
+	}
+	if m := tax.Search("xyzzy", 0); len(m) != 0 {
+		t.Errorf("expected no matches, got %+v", m)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tax := Default()
+
+	tags, err := tax.Validate([]string{"speech", "Dog bark"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(tags) != 2 || tags[0].Name != "Speech" || tags[1].Name != "Dog_bark" {
+		t.Errorf("unexpected tags: %+v", tags)
+	}
+
+	_, err = tax.Validate([]string{"Speech", "siern", "xyzzy"})
+	var unknown *UnknownTagsError
+	if !errors.As(err, &unknown) {
+		t.Fatalf("expected UnknownTagsError, got %v", err)
+	}
+	if len(unknown.Unknown) != 2 {
+		t.Errorf("expected 2 unknown tags, got %v", unknown.Unknown)
+	}
+	if s := unknown.Suggestions["siern"]; len(s) == 0 || s[0] != "Siren" {
+		t.Errorf("expected Siren suggestion, got %v", s)
+	}
+	if !strings.Contains(err.Error(), `"siern" (did you mean Siren`) {
+		t.Errorf("unexpected message: %s", err)
+	}
+}
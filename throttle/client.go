@@ -0,0 +1,127 @@
+package throttle
+
+import (
+	"context"
+
+	"github.com/cochlearai/cochl-mcp-server/client"
+)
+
+// Client is a client.CochlSense whose requests wait for the limits of its
+// project key. A session holds a slot from its creation until it is deleted.
+type Client struct {
+	client.CochlSense
+	throttle *Throttle
+	id       string
+}
+
+// Client returns c limited like every other client of key at baseURL.
+func (t *Throttle) Client(c client.CochlSense, baseURL, key string) *Client {
+	return &Client{CochlSense: c, throttle: t, id: limiterID(baseURL, key)}
+}
+
+// limiter returns the limiter of the client's key for one request, which
+// must call done with it when it ends. Limiters are looked up per request
+// since idle ones are dropped.
+func (c *Client) limiter() *limiter {
+	return c.throttle.use(c.id)
+}
+
+func (c *Client) done(l *limiter) {
+	c.throttle.done(l)
+}
+
+// BaseURL returns the API base URL of the wrapped client, if it has one.
+func (c *Client) BaseURL() string {
+	if b, ok := c.CochlSense.(interface{ BaseURL() string }); ok {
+		return b.BaseURL()
+	}
+	return ""
+}
+
+// KeyID identifies the project key of the wrapped client, if it has one.
+func (c *Client) KeyID() string {
+	if k, ok := c.CochlSense.(interface{ KeyID() string }); ok {
+		return k.KeyID()
+	}
+	return ""
+}
+
+func (c *Client) CreateSession(ctx context.Context, fileName, contentType string, duration float64, fileSize int) (*client.RespCreateSession, error) {
+	return c.createSession(ctx, func() (*client.RespCreateSession, error) {
+		return c.CochlSense.CreateSession(ctx, fileName, contentType, duration, fileSize)
+	})
+}
+
+func (c *Client) CreateStreamSession(ctx context.Context, contentType string) (*client.RespCreateSession, error) {
+	return c.createSession(ctx, func() (*client.RespCreateSession, error) {
+		return c.CochlSense.CreateStreamSession(ctx, contentType)
+	})
+}
+
+// createSession waits for a session slot, then for the rate limit, and
+// keeps the slot if create succeeds.
+func (c *Client) createSession(ctx context.Context, create func() (*client.RespCreateSession, error)) (*client.RespCreateSession, error) {
+	l := c.limiter()
+	defer c.done(l)
+	if err := l.acquire(ctx); err != nil {
+		return nil, err
+	}
+	if err := l.wait(ctx); err != nil {
+		l.releaseSlot()
+		return nil, err
+	}
+	resp, err := create()
+	if err != nil {
+		l.releaseSlot()
+		return nil, err
+	}
+	l.hold(resp.SessionID)
+	return resp, nil
+}
+
+func (c *Client) UploadChunk(ctx context.Context, sessionID string, chunkSequence int, chunk []byte) (*client.RespUploadChunk, error) {
+	l := c.limiter()
+	defer c.done(l)
+	if err := l.wait(ctx); err != nil {
+		return nil, err
+	}
+	return c.CochlSense.UploadChunk(ctx, sessionID, chunkSequence, chunk)
+}
+
+func (c *Client) GetInferenceResult(ctx context.Context, sessionID, nextToken string) (*client.RespInferenceResult, error) {
+	l := c.limiter()
+	defer c.done(l)
+	if err := l.wait(ctx); err != nil {
+		return nil, err
+	}
+	return c.CochlSense.GetInferenceResult(ctx, sessionID, nextToken)
+}
+
+func (c *Client) CloseStream(ctx context.Context, sessionID string) error {
+	l := c.limiter()
+	defer c.done(l)
+	if err := l.wait(ctx); err != nil {
+		return err
+	}
+	return c.CochlSense.CloseStream(ctx, sessionID)
+}
+
+// DeleteSession frees the slot of the session however the request ends.
+func (c *Client) DeleteSession(ctx context.Context, sessionID string) error {
+	l := c.limiter()
+	defer c.done(l)
+	defer l.release(sessionID)
+	if err := l.wait(ctx); err != nil {
+		return err
+	}
+	return c.CochlSense.DeleteSession(ctx, sessionID)
+}
+
+func (c *Client) CheckConnection(ctx context.Context) error {
+	l := c.limiter()
+	defer c.done(l)
+	if err := l.wait(ctx); err != nil {
+		return err
+	}
+	return c.CochlSense.CheckConnection(ctx)
+}
@@ -0,0 +1,382 @@
+// Package throttle paces the requests sent to Cochl Sense with each project
+// key, so agents sharing a server do not trip the throttling of the API: a
+// token bucket caps the request rate and a semaphore caps the sessions open
+// at once. Queued requests are served in order, report their place in the
+// queue and give up when their context is done.
+package throttle
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/cochlearai/cochl-mcp-server/metrics"
+	"github.com/cochlearai/cochl-mcp-server/poll"
+)
+
+// Config sets the limits applied to each project key of each API.
+type Config struct {
+	// Rate is the requests allowed per second, in bursts of up to Burst.
+	// Zero means no limit.
+	Rate  float64
+	Burst int
+	// MaxSessions is the number of sessions open at once. Zero means no
+	// limit.
+	MaxSessions int
+}
+
+// Enabled reports whether c limits anything.
+func (c Config) Enabled() bool {
+	return c.Rate > 0 || c.MaxSessions > 0
+}
+
+// The limits a request can wait for.
+const (
+	LimitRate     = "rate"
+	LimitSessions = "sessions"
+)
+
+// Wait describes a request waiting for a limit.
+type Wait struct {
+	// Limit is LimitRate or LimitSessions.
+	Limit string
+	// Position is the place of the request in the queue, from 1.
+	Position int
+	// Delay is how long a request waiting for the rate limit will wait.
+	Delay time.Duration
+}
+
+type waitFuncKey struct{}
+
+// WithWaitFunc returns a copy of ctx whose requests call fn while they are
+// queued: when they start waiting, and whenever they move up the queue of
+// sessions.
+func WithWaitFunc(ctx context.Context, fn func(Wait)) context.Context {
+	return context.WithValue(ctx, waitFuncKey{}, fn)
+}
+
+func reportWait(ctx context.Context, w Wait) {
+	if fn, ok := ctx.Value(waitFuncKey{}).(func(Wait)); ok {
+		fn(w)
+	}
+}
+
+// sweepInterval is how often limiters left in their initial state are
+// dropped, so keys that stopped sending requests are forgotten.
+const sweepInterval = time.Minute
+
+// Throttle holds the limiters of every project key in use. It is safe for
+// concurrent use.
+type Throttle struct {
+	cfg   Config
+	clock poll.Clock
+
+	mu       sync.Mutex
+	limiters map[string]*limiter
+	swept    time.Time
+}
+
+// Option configures a Throttle.
+type Option func(*Throttle)
+
+// WithClock sets the clock that refills the token buckets.
+func WithClock(c poll.Clock) Option {
+	return func(t *Throttle) {
+		t.clock = c
+	}
+}
+
+// New returns a throttle applying cfg to each project key.
+func New(cfg Config, opts ...Option) *Throttle {
+	t := &Throttle{cfg: cfg, clock: poll.SystemClock, limiters: make(map[string]*limiter)}
+	for _, opt := range opts {
+		opt(t)
+	}
+	t.swept = t.clock.Now()
+	return t
+}
+
+// limiterID identifies the limiter shared by the clients of key at baseURL.
+func limiterID(baseURL, key string) string {
+	sum := sha256.Sum256([]byte(baseURL + "\x00" + key))
+	return hex.EncodeToString(sum[:8])
+}
+
+// use returns the limiter with id, creating it if needed, and keeps it from
+// being dropped until done is called with it.
+func (t *Throttle) use(id string) *limiter {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.clock.Now()
+	if now.Sub(t.swept) >= sweepInterval {
+		t.sweep(now)
+	}
+	l, ok := t.limiters[id]
+	if !ok {
+		l = &limiter{clock: t.clock, open: make(map[string]bool)}
+		if t.cfg.Rate > 0 {
+			burst := float64(max(t.cfg.Burst, 1))
+			l.bucket = &bucket{rate: t.cfg.Rate, burst: burst, tokens: burst, last: t.clock.Now()}
+		}
+		if t.cfg.MaxSessions > 0 {
+			l.sessions = &semaphore{size: t.cfg.MaxSessions}
+		}
+		t.limiters[id] = l
+	}
+	l.users++
+	return l
+}
+
+// done ends a use of l.
+func (t *Throttle) done(l *limiter) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	l.users--
+}
+
+// sweep drops the limiters that are not in use, hold no session slots and
+// have a full bucket: a new limiter would be no different. t.mu must be held.
+func (t *Throttle) sweep(now time.Time) {
+	t.swept = now
+	for id, l := range t.limiters {
+		if l.users == 0 && l.idle(now) {
+			delete(t.limiters, id)
+		}
+	}
+}
+
+// limiter applies the limits of one project key.
+type limiter struct {
+	clock poll.Clock
+	// users counts the requests using the limiter. It is guarded by the
+	// mutex of the Throttle.
+	users int
+
+	// bucket and sessions are nil when their limit is off.
+	bucket   *bucket
+	sessions *semaphore
+
+	mu sync.Mutex
+	// open holds the IDs of the sessions holding a slot.
+	open map[string]bool
+}
+
+// wait blocks until the rate limit allows a request or ctx is done.
+func (l *limiter) wait(ctx context.Context) error {
+	if l.bucket == nil {
+		return nil
+	}
+	delay, position := l.bucket.reserve(l.clock.Now())
+	if delay <= 0 {
+		return nil
+	}
+
+	reportWait(ctx, Wait{Limit: LimitRate, Position: position, Delay: delay})
+	metrics.QueuedRequests.Inc(LimitRate)
+	defer metrics.QueuedRequests.Dec(LimitRate)
+	select {
+	case <-l.clock.After(delay):
+		return nil
+	case <-ctx.Done():
+		l.bucket.cancel()
+		return ctx.Err()
+	}
+}
+
+// acquire blocks until a session slot is free or ctx is done.
+func (l *limiter) acquire(ctx context.Context) error {
+	if l.sessions == nil {
+		return nil
+	}
+	return l.sessions.acquire(ctx)
+}
+
+// hold records that the session with id holds the slot acquired for it.
+func (l *limiter) hold(id string) {
+	if l.sessions == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.open[id] = true
+}
+
+// release frees the slot of the session with id, if it holds one.
+func (l *limiter) release(id string) {
+	if l.sessions == nil {
+		return
+	}
+	l.mu.Lock()
+	held := l.open[id]
+	delete(l.open, id)
+	l.mu.Unlock()
+	if held {
+		l.sessions.release()
+	}
+}
+
+// idle reports whether l holds no session slots and its bucket is full at
+// now.
+func (l *limiter) idle(now time.Time) bool {
+	l.mu.Lock()
+	open := len(l.open)
+	l.mu.Unlock()
+	return open == 0 && (l.bucket == nil || l.bucket.full(now))
+}
+
+// releaseSlot frees a slot acquired for a session that was not created.
+func (l *limiter) releaseSlot() {
+	if l.sessions != nil {
+		l.sessions.release()
+	}
+}
+
+// bucket is a token bucket. Requests reserve tokens in the order they
+// arrive, driving the balance negative while they wait for it to refill.
+type bucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// reserve takes a token and returns how long to wait for it and how many
+// requests, this one included, are waiting.
+func (b *bucket) reserve(now time.Time) (time.Duration, int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if now.After(b.last) {
+		b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+		b.last = now
+	}
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0, 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second)), int(math.Ceil(-b.tokens))
+}
+
+// full reports whether the bucket has refilled to its burst at now.
+func (b *bucket) full(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.tokens+now.Sub(b.last).Seconds()*b.rate >= b.burst
+}
+
+// cancel returns the token of a request that stopped waiting.
+func (b *bucket) cancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens = min(b.burst, b.tokens+1)
+}
+
+// semaphore hands out a fixed number of slots in the order they are asked
+// for.
+type semaphore struct {
+	mu    sync.Mutex
+	size  int
+	held  int
+	queue []*waiter
+}
+
+type waiter struct {
+	// ready is closed when the waiter is given a slot.
+	ready chan struct{}
+	// moved is signaled when the waiter moves up the queue.
+	moved chan struct{}
+}
+
+func (s *semaphore) acquire(ctx context.Context) error {
+	s.mu.Lock()
+	if s.held < s.size && len(s.queue) == 0 {
+		s.held++
+		s.mu.Unlock()
+		return nil
+	}
+	w := &waiter{ready: make(chan struct{}), moved: make(chan struct{}, 1)}
+	s.queue = append(s.queue, w)
+	position := len(s.queue)
+	s.mu.Unlock()
+
+	metrics.QueuedRequests.Inc(LimitSessions)
+	defer metrics.QueuedRequests.Dec(LimitSessions)
+	reportWait(ctx, Wait{Limit: LimitSessions, Position: position})
+	for {
+		select {
+		case <-w.ready:
+			return nil
+		case <-w.moved:
+			if position := s.position(w); position > 0 {
+				reportWait(ctx, Wait{Limit: LimitSessions, Position: position})
+			}
+		case <-ctx.Done():
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			select {
+			case <-w.ready:
+				// The slot was handed over as ctx was done.
+				s.releaseLocked()
+			default:
+				s.remove(w)
+			}
+			return ctx.Err()
+		}
+	}
+}
+
+// position returns the place of w in the queue, or 0 if it left the queue.
+func (s *semaphore) position(w *waiter) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, q := range s.queue {
+		if q == w {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// remove takes w out of the queue. s.mu must be held.
+func (s *semaphore) remove(w *waiter) {
+	for i, q := range s.queue {
+		if q == w {
+			s.queue = append(s.queue[:i], s.queue[i+1:]...)
+			s.notifyFrom(i)
+			return
+		}
+	}
+}
+
+func (s *semaphore) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.releaseLocked()
+}
+
+// releaseLocked hands the slot to the first waiter, if any. s.mu must be
+// held.
+func (s *semaphore) releaseLocked() {
+	if len(s.queue) == 0 {
+		s.held--
+		return
+	}
+	w := s.queue[0]
+	s.queue = s.queue[1:]
+	close(w.ready)
+	s.notifyFrom(0)
+}
+
+// notifyFrom tells the waiters from index i on that they moved up. s.mu
+// must be held.
+func (s *semaphore) notifyFrom(i int) {
+	for _, w := range s.queue[i:] {
+		select {
+		case w.moved <- struct{}{}:
+		default:
+		}
+	}
+}
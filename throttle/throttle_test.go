@@ -0,0 +1,201 @@
+package throttle
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cochlearai/cochl-mcp-server/client"
+	"github.com/cochlearai/cochl-mcp-server/client/fakesense"
+	"github.com/cochlearai/cochl-mcp-server/poll"
+)
+
+func newTestClient(t *testing.T, key string) (client.CochlSense, string) {
+	t.Helper()
+	fake := fakesense.NewServer()
+	t.Cleanup(fake.Close)
+	return client.NewCochlSense(key, fake.URL, "test"), fake.URL
+}
+
+func TestRateLimit(t *testing.T) {
+	clock := &poll.InstantClock{}
+	th := New(Config{Rate: 2, Burst: 2}, WithClock(clock))
+	c, url := newTestClient(t, "test-key")
+	limited := th.Client(c, url, "test-key")
+
+	var waits []Wait
+	ctx := WithWaitFunc(context.Background(), func(w Wait) { waits = append(waits, w) })
+	for range 4 {
+		if err := limited.CheckConnection(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := []time.Duration{500 * time.Millisecond, 500 * time.Millisecond}
+	if got := clock.Waits(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got waits %v, want %v after the burst", got, want)
+	}
+	if len(waits) != 2 || waits[0] != (Wait{Limit: LimitRate, Position: 1, Delay: 500 * time.Millisecond}) {
+		t.Errorf("got reported waits %+v", waits)
+	}
+
+	// Another key has its own bucket.
+	other := th.Client(c, url, "other-key")
+	if err := other.CheckConnection(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(clock.Waits()); got != 2 {
+		t.Errorf("expected no wait for another key, got %d waits", got)
+	}
+}
+
+// positions receives the queue positions reported to a request.
+type positions struct {
+	ch chan int
+}
+
+func newPositions() *positions {
+	return &positions{ch: make(chan int, 10)}
+}
+
+func (p *positions) ctx(ctx context.Context) context.Context {
+	return WithWaitFunc(ctx, func(w Wait) { p.ch <- w.Position })
+}
+
+func (p *positions) next(t *testing.T) int {
+	t.Helper()
+	select {
+	case pos := <-p.ch:
+		return pos
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a queue position")
+		return 0
+	}
+}
+
+func TestSessionLimit(t *testing.T) {
+	th := New(Config{MaxSessions: 1})
+	c, url := newTestClient(t, "test-key")
+	limited := th.Client(c, url, "test-key")
+	ctx := context.Background()
+
+	first, err := limited.CreateSession(ctx, "a.wav", "audio/wav", 1, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second := newPositions()
+	secondCtx, cancelSecond := context.WithCancel(second.ctx(ctx))
+	secondErr := make(chan error, 1)
+	go func() {
+		_, err := limited.CreateSession(secondCtx, "b.wav", "audio/wav", 1, 100)
+		secondErr <- err
+	}()
+	if pos := second.next(t); pos != 1 {
+		t.Errorf("got position %d for the second session, want 1", pos)
+	}
+
+	third := newPositions()
+	thirdDone := make(chan *client.RespCreateSession, 1)
+	go func() {
+		resp, err := limited.CreateStreamSession(third.ctx(ctx), "audio/x-raw; rate=22050; channels=1")
+		if err != nil {
+			t.Error(err)
+		}
+		thirdDone <- resp
+	}()
+	if pos := third.next(t); pos != 2 {
+		t.Errorf("got position %d for the third session, want 2", pos)
+	}
+
+	cancelSecond()
+	if err := <-secondErr; !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v for the canceled session, want context.Canceled", err)
+	}
+	if pos := third.next(t); pos != 1 {
+		t.Errorf("got position %d after the second session left, want 1", pos)
+	}
+
+	select {
+	case <-thirdDone:
+		t.Fatal("third session created while the first is open")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if err := limited.DeleteSession(ctx, first.SessionID); err != nil {
+		t.Fatal(err)
+	}
+	var resp *client.RespCreateSession
+	select {
+	case resp = <-thirdDone:
+	case <-time.After(5 * time.Second):
+		t.Fatal("third session not created after the first was deleted")
+	}
+
+	// The slot of the third session is freed once it is deleted.
+	if err := limited.DeleteSession(ctx, resp.SessionID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := limited.CreateSession(ctx, "d.wav", "audio/wav", 1, 100); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFailedCreateFreesSlot(t *testing.T) {
+	fake := fakesense.NewServer()
+	defer fake.Close()
+	th := New(Config{MaxSessions: 1})
+	limited := th.Client(client.NewCochlSense("test-key", fake.URL, "test"), fake.URL, "test-key")
+
+	fake.Script(fakesense.EndpointCreateSession, fakesense.Response{Status: 500, Body: "boom"})
+	if _, err := limited.CreateSession(context.Background(), "a.wav", "audio/wav", 1, 100); err == nil {
+		t.Fatal("expected the scripted error")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := limited.CreateSession(ctx, "a.wav", "audio/wav", 1, 100); err != nil {
+		t.Errorf("expected the slot of the failed session to be free, got %v", err)
+	}
+}
+
+func TestIdleLimitersAreDropped(t *testing.T) {
+	clock := &poll.InstantClock{}
+	th := New(Config{Rate: 1, Burst: 1, MaxSessions: 1}, WithClock(clock))
+	c, url := newTestClient(t, "test-key")
+	ctx := context.Background()
+
+	idle := th.Client(c, url, "idle-key")
+	if err := idle.CheckConnection(ctx); err != nil {
+		t.Fatal(err)
+	}
+	busy := th.Client(c, url, "busy-key")
+	if _, err := busy.CreateSession(ctx, "a.wav", "audio/wav", 1, 100); err != nil {
+		t.Fatal(err)
+	}
+
+	// The next request after the sweep interval drops the limiter of the
+	// idle key, but not that of the key holding a session.
+	clock.After(sweepInterval)
+	if err := th.Client(c, url, "other-key").CheckConnection(ctx); err != nil {
+		t.Fatal(err)
+	}
+	th.mu.Lock()
+	_, idleKept := th.limiters[limiterID(url, "idle-key")]
+	_, busyKept := th.limiters[limiterID(url, "busy-key")]
+	n := len(th.limiters)
+	th.mu.Unlock()
+	if idleKept || !busyKept || n != 2 {
+		t.Errorf("got %d limiters (idle kept %v, busy kept %v), want the idle one dropped", n, idleKept, busyKept)
+	}
+
+	// A dropped limiter comes back in its initial state.
+	waits := len(clock.Waits())
+	if err := idle.CheckConnection(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(clock.Waits()); got != waits {
+		t.Errorf("expected a full bucket for the dropped key, got %d waits", got-waits)
+	}
+}
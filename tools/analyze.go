@@ -0,0 +1,339 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/cochlearai/cochl-mcp-server/cache"
+	"github.com/cochlearai/cochl-mcp-server/client"
+	"github.com/cochlearai/cochl-mcp-server/common"
+	"github.com/cochlearai/cochl-mcp-server/history"
+	"github.com/cochlearai/cochl-mcp-server/metrics"
+	"github.com/cochlearai/cochl-mcp-server/poll"
+	"github.com/cochlearai/cochl-mcp-server/tracing"
+	"github.com/cochlearai/cochl-mcp-server/usage"
+	"github.com/cochlearai/cochl-mcp-server/util"
+	"github.com/cochlearai/cochl-mcp-server/util/audio"
+)
+
+// defaultPolling schedules result requests unless WithPolling is given.
+var defaultPolling = poll.DefaultStrategy()
+
+// SenseOption configures the tools that analyze audio.
+type SenseOption func(*senseConfig)
+
+type senseConfig struct {
+	cache    *cache.Cache
+	history  *history.Store
+	inflight *Inflight
+	polling  poll.Strategy
+	limits   audio.Limits
+}
+
+func newSenseConfig(opts []SenseOption) *senseConfig {
+	cfg := &senseConfig{polling: defaultPolling, limits: audio.DefaultLimits()}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// WithCache serves repeated analyses of the same audio from c instead of
+// sending it to Cochl Sense again.
+func WithCache(c *cache.Cache) SenseOption {
+	return func(cfg *senseConfig) {
+		cfg.cache = c
+	}
+}
+
+// WithPolling schedules inference result requests with strategy.
+func WithPolling(strategy poll.Strategy) SenseOption {
+	return func(cfg *senseConfig) {
+		cfg.polling = strategy
+	}
+}
+
+// WithAudioLimits rejects audio files outside limits before they are
+// uploaded. Files over the size limit are rejected before they are read.
+func WithAudioLimits(limits audio.Limits) SenseOption {
+	return func(cfg *senseConfig) {
+		cfg.limits = limits
+	}
+}
+
+// WithHistory records every new analysis in store.
+func WithHistory(store *history.Store) SenseOption {
+	return func(cfg *senseConfig) {
+		cfg.history = store
+	}
+}
+
+// analysis is the outcome of analyzing one audio file.
+type analysis struct {
+	info    *audio.AudioInfo
+	results []client.InferenceResult
+	// cachedAt is when the results were originally computed, or zero if
+	// they were not served from the cache.
+	cachedAt time.Time
+	// historyID identifies the analysis in the history store, if it was
+	// recorded.
+	historyID string
+}
+
+// cacheOptions are the parts of an analysis request, besides the audio
+// itself, that can change its results.
+type cacheOptions struct {
+	Format  string `json:"format"`
+	Backend string `json:"backend"`
+	// Tenant keeps the results of one project key from being served to
+	// callers with another.
+	Tenant string `json:"tenant,omitempty"`
+}
+
+// analyzeFile runs the whole analysis pipeline for the audio file at
+// filePath: reading it, consulting the cache and running a Cochl Sense
+// session.
+func (cfg *senseConfig) analyzeFile(ctx context.Context, filePath string, bypassCache bool) (a *analysis, err error) {
+	// A missing project key is reported before any audio is read.
+	cochlSenseClient, clientErr := cochlSenseClient(ctx)
+	var te *toolError
+	if errors.As(clientErr, &te) {
+		return nil, clientErr
+	}
+
+	if cfg.inflight != nil {
+		var done func()
+		if ctx, done, err = cfg.inflight.start(ctx); err != nil {
+			return nil, err
+		}
+		defer done()
+	}
+
+	normalizedPath, err := util.NormalizePath(filePath)
+	if err != nil {
+		return nil, newToolError(err, "invalid file path. Provide an absolute path without URL-encoded characters")
+	}
+	filePath = normalizedPath
+
+	fileInfo, err := os.Stat(filePath)
+	if err != nil {
+		return nil, audioFileError(filePath, err)
+	}
+	if err := cfg.limits.CheckSize(fileInfo.Size()); err != nil {
+		return nil, newToolError(nil, "cannot analyze %s: %v", filePath, err)
+	}
+
+	_, span := tracing.Start(ctx, "GetAudioInfo", attribute.String("file.path", filePath))
+	audioInfo, err := audio.GetAudioInfo(filePath)
+	if err == nil {
+		span.SetAttributes(
+			attribute.String("audio.format", audioInfo.Format),
+			attribute.Float64("audio.duration", audioInfo.Duration),
+			attribute.Int("file.size", audioInfo.Size))
+	}
+	tracing.EndSpan(span, err)
+	if err != nil {
+		return nil, audioFileError(filePath, err)
+	}
+	if err := cfg.limits.Check(audioInfo); err != nil {
+		return nil, newToolError(nil, "cannot analyze %s: %v", filePath, err)
+	}
+	startProgress(ctx, filePath, audioInfo.Duration)
+
+	rawData, err := audio.GetRawAudioData(filePath)
+	if err != nil {
+		return nil, audioFileError(filePath, err)
+	}
+
+	if clientErr != nil {
+		return nil, clientErr
+	}
+
+	var cacheKey cache.Key
+	if cfg.cache != nil {
+		cacheKey, err = cache.NewKey(rawData, cacheOptions{
+			Format:  audioInfo.Format,
+			Backend: backendID(cochlSenseClient),
+			Tenant:  common.Tenant(cochlSenseClient),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if !bypassCache {
+			if entry, ok := cfg.cache.Get(cacheKey); ok {
+				slog.Debug("Serving analysis from cache", "file", filePath, "key", cacheKey)
+				return &analysis{info: audioInfo, results: entry.Results, cachedAt: entry.CreatedAt}, nil
+			}
+		}
+	}
+
+	results, err := cfg.runSession(ctx, cochlSenseClient, filePath, audioInfo, rawData)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.cache != nil {
+		cfg.cache.Put(cacheKey, results)
+	}
+
+	a = &analysis{info: audioInfo, results: results}
+	if cfg.history != nil {
+		record, err := cfg.history.Add(history.Record{
+			Owner:    common.Tenant(cochlSenseClient),
+			FilePath: filePath,
+			FileName: audioInfo.FileName,
+			Format:   audioInfo.Format,
+			Duration: audioInfo.Duration,
+			Size:     audioInfo.Size,
+			Results:  results,
+		})
+		if err != nil {
+			slog.Warn("Failed to record analysis in history", "file", filePath, "error", err)
+		} else {
+			a.historyID = record.ID
+		}
+	}
+
+	return a, nil
+}
+
+// cochlSenseClient returns the client for the request. A missing project key
+// is reported to the caller; a missing client is a server bug.
+func cochlSenseClient(ctx context.Context) (client.CochlSense, error) {
+	c, err := common.CochlSenseClient(ctx)
+	if errors.Is(err, common.ErrMissingProjectKey) {
+		return nil, newToolError(nil, "%v", err)
+	}
+	return c, err
+}
+
+// runSession uploads data to a new Cochl Sense session and waits for its
+// inference results, reporting segments to the caller as they arrive. The
+// session is deleted however the analysis ends.
+func (cfg *senseConfig) runSession(ctx context.Context, c client.CochlSense, filePath string, audioInfo *audio.AudioInfo, data []byte) ([]client.InferenceResult, error) {
+	resp, err := c.CreateSession(ctx,
+		audioInfo.FileName,
+		audioInfo.Format,
+		audioInfo.Duration,
+		audioInfo.Size)
+	if errors.Is(err, usage.ErrLimitExceeded) {
+		return nil, newToolError(nil, "%v", err)
+	}
+	if err != nil {
+		return nil, newToolError(err, "Cochl Sense API failed to create session")
+	}
+
+	if cfg.inflight != nil {
+		cfg.inflight.trackSession(resp.SessionID, c)
+	}
+	defer cfg.deleteSession(ctx, c, resp.SessionID)
+	metrics.ActiveSessions.Inc()
+	defer metrics.ActiveSessions.Dec()
+
+	//TODO: if file is too large, upload in chunks
+	_, err = c.UploadChunk(ctx,
+		resp.SessionID,
+		resp.ChunkSequence,
+		data)
+	if err != nil {
+		return nil, sessionError(ctx, err, "Cochl Sense API failed to upload audio")
+	}
+	metrics.UploadBytes.Add(float64(len(data)))
+	uploaded := time.Now()
+
+	schedule := cfg.polling.NewSchedule(audioInfo.Duration)
+	var (
+		results    []client.InferenceResult
+		reported   int
+		nextToken  string
+		retryAfter time.Duration
+		more       bool
+	)
+	for polls := 1; ; polls++ {
+		// Pages that are already available are fetched without waiting.
+		if !more {
+			err := schedule.Wait(ctx, retryAfter)
+			if errors.Is(err, poll.ErrMaxWait) {
+				return nil, newToolError(nil, "Cochl Sense did not finish analyzing %s within %s", filePath, cfg.polling.MaxWait)
+			}
+			if err != nil {
+				return nil, sessionError(ctx, err, "")
+			}
+		}
+
+		pollCtx, span := tracing.Start(ctx, "poll", attribute.Int("poll.iteration", polls))
+		inferenceResult, err := c.GetInferenceResult(pollCtx, resp.SessionID, nextToken)
+		if err == nil {
+			span.SetAttributes(
+				attribute.String("poll.state", inferenceResult.State),
+				attribute.Int("poll.segments", len(inferenceResult.Data)))
+		}
+		tracing.EndSpan(span, err)
+		if err != nil {
+			return nil, sessionError(ctx, err, "Cochl Sense API failed to return inference result")
+		}
+
+		retryAfter = inferenceResult.RetryAfter
+		if page := inferenceResult.Page; page != nil {
+			results = append(results, inferenceResult.Data...)
+			if page.NextToken != "" {
+				nextToken = page.NextToken
+			}
+			more = page.HasMore()
+		} else {
+			// Without pages, every response holds all segments so far.
+			results = inferenceResult.Data
+			more = false
+		}
+
+		if inferenceResult.State == "done" && !more {
+			metrics.InferenceLatency.Observe(time.Since(uploaded).Seconds())
+			metrics.PollIterations.Observe(float64(polls))
+			return results, nil
+		}
+		if len(results) > reported {
+			reportPartialResults(ctx, filePath, audioInfo.Duration, results, results[reported:])
+			reported = len(results)
+		}
+	}
+}
+
+// sessionError reports a failed request to an open session, or the
+// cancellation of the analysis if ctx is done, since the session may have been
+// deleted under it.
+func sessionError(ctx context.Context, err error, msg string) error {
+	if ctx.Err() != nil {
+		return newToolError(ctx.Err(), "analysis canceled before Cochl Sense returned a result")
+	}
+	return newToolError(err, msg)
+}
+
+// deleteSession deletes a Cochl Sense session unless the inflight tracker
+// already did so during shutdown. The request outlives the cancellation of
+// ctx, the context of the analysis, but not deleteTimeout.
+func (cfg *senseConfig) deleteSession(ctx context.Context, c client.CochlSense, sessionID string) {
+	if cfg.inflight != nil && !cfg.inflight.untrackSession(sessionID) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), deleteTimeout)
+	defer cancel()
+	if err := c.DeleteSession(ctx, sessionID); err != nil {
+		slog.Warn("Failed to delete session", "session_id", sessionID, "error", err)
+	}
+}
+
+// backendID identifies the API a client talks to, so results from different
+// backends are cached separately.
+func backendID(c client.CochlSense) string {
+	if b, ok := c.(interface{ BaseURL() string }); ok {
+		return b.BaseURL()
+	}
+	return fmt.Sprintf("%T", c)
+}
@@ -0,0 +1,106 @@
+package tools
+
+import (
+	"math"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func requiredString(request mcp.CallToolRequest, name string) (string, error) {
+	v, ok := request.Params.Arguments[name]
+	if !ok {
+		return "", newToolError(nil, "missing required argument %q", name)
+	}
+
+	s, ok := v.(string)
+	if !ok || s == "" {
+		return "", newToolError(nil, "argument %q must be a non-empty string", name)
+	}
+	return s, nil
+}
+
+func optionalBool(request mcp.CallToolRequest, name string) (bool, error) {
+	v, ok := request.Params.Arguments[name]
+	if !ok || v == nil {
+		return false, nil
+	}
+
+	b, ok := v.(bool)
+	if !ok {
+		return false, newToolError(nil, "argument %q must be a boolean", name)
+	}
+	return b, nil
+}
+
+func optionalString(request mcp.CallToolRequest, name string) (string, error) {
+	v, ok := request.Params.Arguments[name]
+	if !ok || v == nil {
+		return "", nil
+	}
+
+	s, ok := v.(string)
+	if !ok {
+		return "", newToolError(nil, "argument %q must be a string", name)
+	}
+	return s, nil
+}
+
+// optionalInt reads a whole-number argument, returning def if it is absent.
+// JSON numbers arrive as float64.
+func optionalInt(request mcp.CallToolRequest, name string, def int) (int, error) {
+	v, ok := request.Params.Arguments[name]
+	if !ok || v == nil {
+		return def, nil
+	}
+
+	f, ok := v.(float64)
+	if !ok || f != math.Trunc(f) {
+		return 0, newToolError(nil, "argument %q must be a whole number", name)
+	}
+	return int(f), nil
+}
+
+// requiredStrings reads an argument holding one or more non-empty strings.
+// A single string is accepted in place of an array.
+func requiredStrings(request mcp.CallToolRequest, name string) ([]string, error) {
+	v, ok := request.Params.Arguments[name]
+	if !ok {
+		return nil, newToolError(nil, "missing required argument %q", name)
+	}
+
+	var values []any
+	switch v := v.(type) {
+	case string:
+		values = []any{v}
+	case []any:
+		values = v
+	}
+
+	var strs []string
+	for _, v := range values {
+		s, ok := v.(string)
+		if !ok || s == "" {
+			return nil, newToolError(nil, "argument %q must be a list of non-empty strings", name)
+		}
+		strs = append(strs, s)
+	}
+	if len(strs) == 0 {
+		return nil, newToolError(nil, "argument %q must be a list of non-empty strings", name)
+	}
+	return strs, nil
+}
+
+// optionalProbability reads a number between 0 and 1, returning def if it is
+// absent.
+func optionalProbability(request mcp.CallToolRequest, name string, def float64) (float64, error) {
+	v, ok := request.Params.Arguments[name]
+	if !ok || v == nil {
+		return def, nil
+	}
+
+	f, ok := v.(float64)
+	if !ok || f < 0 || f > 1 {
+		return 0, newToolError(nil, "argument %q must be a number between 0 and 1", name)
+	}
+	return f, nil
+}
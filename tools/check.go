@@ -0,0 +1,107 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+
+	"github.com/cochlearai/cochl-mcp-server/client"
+	"github.com/cochlearai/cochl-mcp-server/common"
+)
+
+// Statuses of a ConnectionReport.
+const (
+	ConnectionOK                = "ok"
+	ConnectionNoProjectKey      = "no_project_key"
+	ConnectionInvalidProjectKey = "invalid_project_key"
+	ConnectionUnreachable       = "unreachable"
+	ConnectionAPIError          = "api_error"
+)
+
+// checkTimeout bounds the request of a connection check.
+const checkTimeout = 10 * time.Second
+
+// ConnectionReport tells whether the Cochl Sense API can be used with the
+// credentials of a request and, if not, how to fix it.
+type ConnectionReport struct {
+	Status    string `json:"status"`
+	BaseURL   string `json:"base_url,omitempty"`
+	Mock      bool   `json:"mock,omitempty"`
+	LatencyMs int64  `json:"latency_ms,omitempty"`
+	Problem   string `json:"problem,omitempty"`
+	Fix       string `json:"fix,omitempty"`
+}
+
+// Diagnose checks that the Cochl Sense API is reachable with the client of
+// ctx and accepts its project key, without creating a session.
+func Diagnose(ctx context.Context) ConnectionReport {
+	report := ConnectionReport{Mock: common.MockMode()}
+	c, err := common.CochlSenseClient(ctx)
+	if err != nil {
+		report.Status = ConnectionNoProjectKey
+		report.Problem = err.Error()
+		report.Fix = "Configure a Cochl Sense project key for this client, as the problem describes."
+		return report
+	}
+	if c, ok := c.(interface{ BaseURL() string }); ok {
+		report.BaseURL = c.BaseURL()
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+	start := time.Now()
+	err = c.CheckConnection(ctx)
+	report.LatencyMs = time.Since(start).Milliseconds()
+
+	var apiErr *client.APIError
+	switch {
+	case err == nil:
+		report.Status = ConnectionOK
+	case errors.Is(err, client.ErrNoProjectKey):
+		report.Status = ConnectionNoProjectKey
+		report.Problem = err.Error()
+		report.Fix = "Set the COCHL_SENSE_PROJECT_KEY environment variable of the server, or send the key in the X-Api-Key header, to the project key shown in the Cochl dashboard."
+	case errors.Is(err, client.ErrInvalidProjectKey):
+		report.Status = ConnectionInvalidProjectKey
+		report.Problem = err.Error()
+		report.Fix = "Check that the project key is copied completely from the Cochl dashboard and belongs to a Cochl Sense project that is still active."
+	case errors.As(err, &apiErr):
+		report.Status = ConnectionAPIError
+		report.Problem = err.Error()
+		report.Fix = "The API is reachable but failing. Check that the base URL points at the Cochl Sense API, and retry later if the problem persists."
+	default:
+		report.Status = ConnectionUnreachable
+		report.Problem = fmt.Sprintf("the Cochl Sense API is unreachable: %v", err)
+		report.Fix = "Check the network connection, proxy settings and the COCHL_SENSE_BASE_URL environment variable of the server."
+	}
+	return report
+}
+
+// CheckConnection returns the tool "check_cochl_connection", which reports
+// whether analyses can run and how to fix the setup if not.
+func CheckConnection() (tool mcp.Tool, handler server.ToolHandlerFunc) {
+	tool = mcp.NewTool("check_cochl_connection",
+		mcp.WithDescription(
+			"Check that the server can reach the Cochl Sense API and that the project key is valid, "+
+				"without analyzing any audio. Use it when an analysis fails with an authentication or "+
+				"connection error, or before the first analysis. Reports a status (ok, no_project_key, "+
+				"invalid_project_key, unreachable or api_error), the API URL, the latency and, on "+
+				"failure, the problem and how to fix it.",
+		),
+	)
+
+	handler = func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		jsonResult, err := json.Marshal(Diagnose(ctx))
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal connection report: %v", err)
+		}
+		return mcp.NewToolResultText(string(jsonResult)), nil
+	}
+
+	return tool, withToolErrors(handler)
+}
@@ -0,0 +1,106 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/cochlearai/cochl-mcp-server/client"
+	"github.com/cochlearai/cochl-mcp-server/client/fakesense"
+	"github.com/cochlearai/cochl-mcp-server/common"
+)
+
+func TestCheckConnection(t *testing.T) {
+	fake := fakesense.NewServer(fakesense.WithAPIKey("test-key"))
+	defer fake.Close()
+	closed := fakesense.NewServer()
+	closed.Close()
+
+	tests := []struct {
+		name        string
+		ctx         context.Context
+		script      []fakesense.Response
+		wantStatus  string
+		wantProblem string
+	}{
+		{
+			name:       "Valid key",
+			ctx:        common.WithCochlSenseClient(context.Background(), client.NewCochlSense("test-key", fake.URL, "test")),
+			wantStatus: ConnectionOK,
+		},
+		{
+			name:        "Invalid key",
+			ctx:         common.WithCochlSenseClient(context.Background(), client.NewCochlSense("wrong-key", fake.URL, "test")),
+			wantStatus:  ConnectionInvalidProjectKey,
+			wantProblem: "rejected the project key",
+		},
+		{
+			name:        "Missing key",
+			ctx:         common.WithCochlSenseClient(context.Background(), client.NewCochlSense("", fake.URL, "test")),
+			wantStatus:  ConnectionNoProjectKey,
+			wantProblem: "no project key is set",
+		},
+		{
+			name:        "No client",
+			ctx:         context.Background(),
+			wantStatus:  ConnectionNoProjectKey,
+			wantProblem: "cochl sense client not found",
+		},
+		{
+			name:        "Unreachable",
+			ctx:         common.WithCochlSenseClient(context.Background(), client.NewCochlSense("test-key", closed.URL, "test")),
+			wantStatus:  ConnectionUnreachable,
+			wantProblem: "unreachable",
+		},
+		{
+			name:        "API error",
+			ctx:         common.WithCochlSenseClient(context.Background(), client.NewCochlSense("test-key", fake.URL, "test")),
+			script:      []fakesense.Response{{Status: http.StatusBadGateway, Body: "upstream down"}},
+			wantStatus:  ConnectionAPIError,
+			wantProblem: "status 502: upstream down",
+		},
+		{
+			name:        "Unrelated not found",
+			ctx:         common.WithCochlSenseClient(context.Background(), client.NewCochlSense("test-key", fake.URL, "test")),
+			script:      []fakesense.Response{{Status: http.StatusNotFound, Body: "<html>Not Found</html>"}},
+			wantStatus:  ConnectionAPIError,
+			wantProblem: "status 404",
+		},
+		{
+			name:        "Wrong base URL",
+			ctx:         common.WithCochlSenseClient(context.Background(), client.NewCochlSense("test-key", fake.URL+"/proxy", "test")),
+			wantStatus:  ConnectionAPIError,
+			wantProblem: "status 404: 404 page not found",
+		},
+	}
+
+	_, handler := CheckConnection()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake.Script(fakesense.EndpointGetResults, tt.script...)
+
+			result, err := handler(tt.ctx, newCallToolRequest(nil))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result.IsError {
+				t.Fatalf("unexpected tool error: %s", resultText(t, result))
+			}
+			var report ConnectionReport
+			if err := json.Unmarshal([]byte(resultText(t, result)), &report); err != nil {
+				t.Fatalf("invalid report: %v", err)
+			}
+			if report.Status != tt.wantStatus || !strings.Contains(report.Problem, tt.wantProblem) {
+				t.Errorf("got status %q with problem %q, want %q with %q", report.Status, report.Problem, tt.wantStatus, tt.wantProblem)
+			}
+			if (report.Status == ConnectionOK) != (report.Fix == "") {
+				t.Errorf("expected a fix exactly when the check fails, got %q", report.Fix)
+			}
+		})
+	}
+	if sessions := fake.Sessions(); len(sessions) != 0 {
+		t.Errorf("expected no session to be created, got %d", len(sessions))
+	}
+}
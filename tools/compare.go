@@ -0,0 +1,334 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+
+	"github.com/cochlearai/cochl-mcp-server/client"
+)
+
+// tagStats summarizes how one tag appears in a recording.
+type tagStats struct {
+	// Duration is the total length of the tag's occurrences in milliseconds.
+	Duration    int `json:"duration"`
+	Occurrences int `json:"occurrences"`
+}
+
+type tagChange struct {
+	Tag        string   `json:"tag"`
+	Baseline   tagStats `json:"baseline"`
+	Comparison tagStats `json:"comparison"`
+	// DurationChange is the comparison duration minus the baseline duration,
+	// in milliseconds.
+	DurationChange int `json:"duration_change"`
+}
+
+// timeDifference is a stretch of time in which the two recordings contain
+// different sounds.
+type timeDifference struct {
+	StartTime        int      `json:"start_time"`
+	EndTime          int      `json:"end_time"`
+	OnlyInBaseline   []string `json:"only_in_baseline,omitempty"`
+	OnlyInComparison []string `json:"only_in_comparison,omitempty"`
+}
+
+type recordingSummary struct {
+	FileName string  `json:"file_name"`
+	Duration float64 `json:"duration"`
+}
+
+type audioComparison struct {
+	Baseline         recordingSummary `json:"baseline"`
+	Comparison       recordingSummary `json:"comparison"`
+	OnlyInBaseline   []string         `json:"only_in_baseline"`
+	OnlyInComparison []string         `json:"only_in_comparison"`
+	TagChanges       []tagChange      `json:"tag_changes"`
+	TimeDifferences  []timeDifference `json:"time_differences"`
+}
+
+func CompareAudio(opts ...SenseOption) (tool mcp.Tool, handler server.ToolHandlerFunc) {
+	cfg := newSenseConfig(opts)
+
+	tool = mcp.NewTool("compare_audio",
+		mcp.WithDescription(
+			"Compare the sounds detected in two audio files, such as a baseline recording and a new "+
+				"one. Both files are analyzed and the result lists:\n"+
+				"  - Tags detected in only one of the files\n"+
+				"  - Changes in total duration (milliseconds) and number of occurrences per tag\n"+
+				"  - Time ranges, measured from the start of each file, where the detected sounds differ",
+		),
+		mcp.WithString(
+			"baseline_file_absolute_path",
+			mcp.Required(),
+			mcp.Description(
+				"Absolute path of the baseline audio file.\n"+
+					"Avoid using URL-encoded characters.",
+			),
+		),
+		mcp.WithString(
+			"comparison_file_absolute_path",
+			mcp.Required(),
+			mcp.Description(
+				"Absolute path of the audio file to compare against the baseline.\n"+
+					"Avoid using URL-encoded characters.",
+			),
+		),
+		mcp.WithNumber(
+			"min_probability",
+			mcp.Description(
+				"Only count a tag in a segment if its probability is at least this value. Defaults to 0.5.",
+			),
+		),
+		mcp.WithBoolean(
+			"bypass_cache",
+			mcp.Description(
+				"Analyze the files again even if cached results for the same audio exist.",
+			),
+		),
+	)
+
+	handler = func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		baselinePath, err := requiredString(request, "baseline_file_absolute_path")
+		if err != nil {
+			return nil, err
+		}
+
+		comparisonPath, err := requiredString(request, "comparison_file_absolute_path")
+		if err != nil {
+			return nil, err
+		}
+
+		minProbability, err := optionalProbability(request, "min_probability", 0.5)
+		if err != nil {
+			return nil, err
+		}
+
+		bypassCache, err := optionalBool(request, "bypass_cache")
+		if err != nil {
+			return nil, err
+		}
+
+		// Check credentials once so a missing key is not reported per file.
+		var te *toolError
+		if _, err := cochlSenseClient(ctx); errors.As(err, &te) {
+			return nil, err
+		}
+
+		paths := []string{baselinePath, comparisonPath}
+		expectFiles(ctx, paths)
+		analyses := make([]*analysis, len(paths))
+		errs := make([]error, len(paths))
+		var wg sync.WaitGroup
+		for i, path := range paths {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				analyses[i], errs[i] = cfg.analyzeFile(ctx, path, bypassCache)
+			}()
+		}
+		wg.Wait()
+
+		for i, err := range errs {
+			if err == nil {
+				continue
+			}
+			var te *toolError
+			if errors.As(err, &te) {
+				return nil, newToolError(te.err, "%s file: %s", []string{"baseline", "comparison"}[i], te.msg)
+			}
+			return nil, err
+		}
+
+		c := compareResults(analyses[0].results, analyses[1].results, minProbability)
+		c.Baseline = recordingSummary{FileName: analyses[0].info.FileName, Duration: analyses[0].info.Duration}
+		c.Comparison = recordingSummary{FileName: analyses[1].info.FileName, Duration: analyses[1].info.Duration}
+
+		jsonResult, err := json.Marshal(c)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal comparison: %v", err)
+		}
+
+		result := mcp.NewToolResultText(string(jsonResult))
+		result.Content = append(result.Content, mcp.NewTextContent(describeComparison(c)))
+		return result, nil
+	}
+
+	return tool, withToolErrors(withProgress(handler))
+}
+
+// compareResults diffs the tags detected in two analyses. Tags count in a
+// segment when their probability is at least minProbability; "Others" is
+// ignored.
+func compareResults(baseline, comparison []client.InferenceResult, minProbability float64) *audioComparison {
+	baseStats := collectTagStats(baseline, minProbability)
+	compStats := collectTagStats(comparison, minProbability)
+
+	c := &audioComparison{
+		OnlyInBaseline:   []string{},
+		OnlyInComparison: []string{},
+		TagChanges:       []tagChange{},
+	}
+
+	var tags []string
+	for tag := range baseStats {
+		tags = append(tags, tag)
+	}
+	for tag := range compStats {
+		if _, ok := baseStats[tag]; !ok {
+			tags = append(tags, tag)
+		}
+	}
+	sort.Strings(tags)
+
+	for _, tag := range tags {
+		b, inBase := baseStats[tag]
+		n, inComp := compStats[tag]
+		switch {
+		case !inComp:
+			c.OnlyInBaseline = append(c.OnlyInBaseline, tag)
+		case !inBase:
+			c.OnlyInComparison = append(c.OnlyInComparison, tag)
+		}
+		if b != n {
+			c.TagChanges = append(c.TagChanges, tagChange{
+				Tag:            tag,
+				Baseline:       b,
+				Comparison:     n,
+				DurationChange: n.Duration - b.Duration,
+			})
+		}
+	}
+
+	c.TimeDifferences = diffTimelines(baseline, comparison, minProbability)
+	return c
+}
+
+func collectTagStats(results []client.InferenceResult, minProbability float64) map[string]tagStats {
+	stats := make(map[string]tagStats)
+	for tag := range detectedTags(results, minProbability) {
+		var s tagStats
+		for _, o := range findOccurrences(results, tag, minProbability) {
+			s.Duration += o.EndTime - o.StartTime
+			s.Occurrences++
+		}
+		stats[tag] = s
+	}
+	return stats
+}
+
+func detectedTags(results []client.InferenceResult, minProbability float64) map[string]bool {
+	tags := make(map[string]bool)
+	for _, s := range results {
+		for _, t := range s.Tags {
+			if t.Name != "Others" && t.Probability >= minProbability {
+				tags[t.Name] = true
+			}
+		}
+	}
+	return tags
+}
+
+// diffTimelines lines up the segments of both analyses by start time and
+// reports where the detected tags differ. Neighbouring segments with the same
+// difference are merged into one range.
+func diffTimelines(baseline, comparison []client.InferenceResult, minProbability float64) []timeDifference {
+	type slot struct {
+		end        int
+		baseline   map[string]bool
+		comparison map[string]bool
+	}
+	slots := make(map[int]*slot)
+	add := func(results []client.InferenceResult, isBaseline bool) {
+		for _, s := range results {
+			sl, ok := slots[s.StartTime]
+			if !ok {
+				sl = &slot{}
+				slots[s.StartTime] = sl
+			}
+			sl.end = max(sl.end, s.EndTime)
+			// Segments starting together share a slot, so their tags are
+			// merged rather than replaced.
+			tags := &sl.comparison
+			if isBaseline {
+				tags = &sl.baseline
+			}
+			if *tags == nil {
+				*tags = make(map[string]bool)
+			}
+			maps.Copy(*tags, detectedTags([]client.InferenceResult{s}, minProbability))
+		}
+	}
+	add(baseline, true)
+	add(comparison, false)
+
+	starts := make([]int, 0, len(slots))
+	for start := range slots {
+		starts = append(starts, start)
+	}
+	sort.Ints(starts)
+
+	diffs := []timeDifference{}
+	for _, start := range starts {
+		sl := slots[start]
+		d := timeDifference{
+			StartTime:        start,
+			EndTime:          sl.end,
+			OnlyInBaseline:   missingFrom(sl.baseline, sl.comparison),
+			OnlyInComparison: missingFrom(sl.comparison, sl.baseline),
+		}
+		if len(d.OnlyInBaseline) == 0 && len(d.OnlyInComparison) == 0 {
+			continue
+		}
+
+		if n := len(diffs); n > 0 && start <= diffs[n-1].EndTime &&
+			slices.Equal(diffs[n-1].OnlyInBaseline, d.OnlyInBaseline) &&
+			slices.Equal(diffs[n-1].OnlyInComparison, d.OnlyInComparison) {
+			diffs[n-1].EndTime = max(diffs[n-1].EndTime, d.EndTime)
+			continue
+		}
+		diffs = append(diffs, d)
+	}
+	return diffs
+}
+
+// missingFrom returns the tags in a that are not in b, sorted.
+func missingFrom(a, b map[string]bool) []string {
+	var tags []string
+	for tag := range a {
+		if !b[tag] {
+			tags = append(tags, tag)
+		}
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+func describeComparison(c *audioComparison) string {
+	var lines []string
+	if len(c.OnlyInBaseline) > 0 {
+		lines = append(lines, fmt.Sprintf("Only in %s: %s.", c.Baseline.FileName, strings.Join(c.OnlyInBaseline, ", ")))
+	}
+	if len(c.OnlyInComparison) > 0 {
+		lines = append(lines, fmt.Sprintf("Only in %s: %s.", c.Comparison.FileName, strings.Join(c.OnlyInComparison, ", ")))
+	}
+	for _, tc := range c.TagChanges {
+		lines = append(lines, fmt.Sprintf("%s: %.1fs in %d occurrences -> %.1fs in %d occurrences.",
+			tc.Tag,
+			float64(tc.Baseline.Duration)/1000, tc.Baseline.Occurrences,
+			float64(tc.Comparison.Duration)/1000, tc.Comparison.Occurrences))
+	}
+	if len(lines) == 0 {
+		return "No differences in detected sounds."
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,131 @@
+package tools
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/cochlearai/cochl-mcp-server/client"
+	"github.com/cochlearai/cochl-mcp-server/client/fakesense"
+)
+
+func TestCompareResults(t *testing.T) {
+	baseline := []client.InferenceResult{
+		segment(0, client.Tags{Name: "Speech", Probability: 0.9}),
+		segment(500, client.Tags{Name: "Speech", Probability: 0.9}),
+		segment(1000, client.Tags{Name: "Knock", Probability: 0.8}),
+		segment(1500, client.Tags{Name: "Others", Probability: 0}),
+	}
+	comparison := []client.InferenceResult{
+		segment(0, client.Tags{Name: "Speech", Probability: 0.9}),
+		segment(500, client.Tags{Name: "Others", Probability: 0}),
+		segment(1000, client.Tags{Name: "Dog_bark", Probability: 0.7}),
+		segment(1500, client.Tags{Name: "Dog_bark", Probability: 0.4}),
+	}
+
+	got := compareResults(baseline, comparison, 0.5)
+
+	if !reflect.DeepEqual(got.OnlyInBaseline, []string{"Knock"}) ||
+		!reflect.DeepEqual(got.OnlyInComparison, []string{"Dog_bark"}) {
+		t.Errorf("unexpected exclusive tags: %v, %v", got.OnlyInBaseline, got.OnlyInComparison)
+	}
+
+	wantChanges := []tagChange{
+		{Tag: "Dog_bark", Comparison: tagStats{Duration: 1000, Occurrences: 1}, DurationChange: 1000},
+		{Tag: "Knock", Baseline: tagStats{Duration: 1000, Occurrences: 1}, DurationChange: -1000},
+		{
+			Tag:            "Speech",
+			Baseline:       tagStats{Duration: 1500, Occurrences: 1},
+			Comparison:     tagStats{Duration: 1000, Occurrences: 1},
+			DurationChange: -500,
+		},
+	}
+	if !reflect.DeepEqual(got.TagChanges, wantChanges) {
+		t.Errorf("got changes %+v, want %+v", got.TagChanges, wantChanges)
+	}
+
+	wantDiffs := []timeDifference{
+		{StartTime: 500, EndTime: 1500, OnlyInBaseline: []string{"Speech"}},
+		{StartTime: 1000, EndTime: 2000, OnlyInBaseline: []string{"Knock"}, OnlyInComparison: []string{"Dog_bark"}},
+	}
+	if !reflect.DeepEqual(got.TimeDifferences, wantDiffs) {
+		t.Errorf("got differences %+v, want %+v", got.TimeDifferences, wantDiffs)
+	}
+}
+
+func TestDiffTimelinesSameStart(t *testing.T) {
+	// Segments of different lengths starting together keep all their tags.
+	baseline := []client.InferenceResult{
+		segment(0, client.Tags{Name: "Speech", Probability: 0.9}),
+		{StartTime: 0, EndTime: 2000, Tags: []client.Tags{{Name: "Music", Probability: 0.8}}},
+	}
+	comparison := []client.InferenceResult{
+		{StartTime: 0, EndTime: 2000, Tags: []client.Tags{{Name: "Music", Probability: 0.8}}},
+		segment(0, client.Tags{Name: "Speech", Probability: 0.9}),
+	}
+	if got := diffTimelines(baseline, comparison, 0.5); len(got) != 0 {
+		t.Errorf("got differences %+v, want none", got)
+	}
+
+	comparison = comparison[:1]
+	want := []timeDifference{{StartTime: 0, EndTime: 2000, OnlyInBaseline: []string{"Speech"}}}
+	if got := diffTimelines(baseline, comparison, 0.5); !reflect.DeepEqual(got, want) {
+		t.Errorf("got differences %+v, want %+v", got, want)
+	}
+}
+
+func TestCompareAudio(t *testing.T) {
+	setInstantPolling(t)
+
+	ctx, fake := newFakeSenseContext(t, fakesense.WithResultFunc(func(s fakesense.Session) []client.InferenceResult {
+		if s.FileName == "wav-test.wav" {
+			return []client.InferenceResult{segment(0, client.Tags{Name: "Speech", Probability: 0.9})}
+		}
+		return []client.InferenceResult{segment(0, client.Tags{Name: "Music", Probability: 0.9})}
+	}))
+
+	_, handler := CompareAudio()
+	result, err := handler(ctx, newCallToolRequest(map[string]any{
+		"baseline_file_absolute_path":   testdataPath(t, "wav-test.wav"),
+		"comparison_file_absolute_path": testdataPath(t, "mp3-test.mp3"),
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.IsError {
+		t.Fatalf("unexpected tool error: %s", resultText(t, result))
+	}
+
+	var got audioComparison
+	if err := json.Unmarshal([]byte(resultText(t, result)), &got); err != nil {
+		t.Fatalf("failed to decode result: %v", err)
+	}
+	if got.Baseline.FileName != "wav-test.wav" || got.Comparison.FileName != "mp3-test.mp3" {
+		t.Errorf("unexpected recordings: %+v, %+v", got.Baseline, got.Comparison)
+	}
+	if !reflect.DeepEqual(got.OnlyInBaseline, []string{"Speech"}) || !reflect.DeepEqual(got.OnlyInComparison, []string{"Music"}) {
+		t.Errorf("unexpected exclusive tags: %v, %v", got.OnlyInBaseline, got.OnlyInComparison)
+	}
+	if n := len(fake.Sessions()); n != 2 {
+		t.Errorf("expected 2 sessions, got %d", n)
+	}
+
+	answer := result.Content[1].(mcp.TextContent).Text
+	if !strings.Contains(answer, "Only in wav-test.wav: Speech.") {
+		t.Errorf("unexpected answer:\n%s", answer)
+	}
+
+	result, err = handler(ctx, newCallToolRequest(map[string]any{
+		"baseline_file_absolute_path":   testdataPath(t, "wav-test.wav"),
+		"comparison_file_absolute_path": testdataPath(t, "missing.wav"),
+	}))
+	if err != nil {
+		t.Fatalf("expected tool error result, got protocol error: %v", err)
+	}
+	if text := resultText(t, result); !result.IsError || !strings.HasPrefix(text, "comparison file: file not found") {
+		t.Errorf("expected comparison file error, got %q", text)
+	}
+}
@@ -0,0 +1,80 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+
+	"github.com/cochlearai/cochl-mcp-server/util/audio"
+)
+
+// toolError is a failure the caller can correct, such as a bad argument, an
+// unreadable file or a request rejected by the Cochl Sense API. It is reported
+// to the model as an isError tool result instead of a JSON-RPC error so the
+// model can read the message and try again.
+type toolError struct {
+	msg string
+	err error
+}
+
+func (e *toolError) Error() string {
+	if e.err == nil {
+		return e.msg
+	}
+	return fmt.Sprintf("%s: %v", e.msg, e.err)
+}
+
+func (e *toolError) Unwrap() error {
+	return e.err
+}
+
+func newToolError(err error, format string, args ...any) error {
+	return &toolError{msg: fmt.Sprintf(format, args...), err: err}
+}
+
+// withToolErrors converts toolErrors returned by handler into isError
+// results. Any other error is passed through and becomes a protocol error,
+// which is reserved for server bugs.
+func withToolErrors(handler server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		result, err := handler(ctx, request)
+		if err == nil {
+			return result, nil
+		}
+
+		var te *toolError
+		if errors.As(err, &te) {
+			slog.Debug("Tool call failed", "tool", request.Params.Name, "error", err)
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		slog.Error("Tool call failed with internal error", "tool", request.Params.Name, "error", err)
+		return nil, err
+	}
+}
+
+// audioFileError turns an error from reading an audio file into a toolError
+// that tells the caller what to fix.
+func audioFileError(filePath string, err error) error {
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return newToolError(nil, "file not found: %s. Check that the path is absolute and the file exists", filePath)
+	case errors.Is(err, os.ErrPermission):
+		return newToolError(nil, "permission denied reading %s", filePath)
+	case errors.Is(err, audio.ErrUnsupportedFormat):
+		return newToolError(nil, "unsupported audio format %q. Supported formats are: %s",
+			filepath.Ext(filePath), strings.Join(audio.SupportedFormats, ", "))
+	case errors.Is(err, audio.ErrCorrupt):
+		return newToolError(nil, "failed to read audio file %s, it may be corrupt or truncated (%v). "+
+			"Check that it was copied completely, or export it again", filePath, err)
+	default:
+		return newToolError(err, "failed to read audio file %s, it may be corrupt", filePath)
+	}
+}
@@ -0,0 +1,271 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+
+	"github.com/cochlearai/cochl-mcp-server/client"
+	"github.com/cochlearai/cochl-mcp-server/taxonomy"
+)
+
+// maxNearMisses is how many near-miss tags are reported for a sound that was
+// not found.
+const maxNearMisses = 3
+
+// occurrence is a stretch of audio in which a target tag was detected,
+// merged from consecutive or overlapping segments.
+type occurrence struct {
+	Tag            string  `json:"tag"`
+	StartTime      int     `json:"start_time"`
+	EndTime        int     `json:"end_time"`
+	MaxProbability float64 `json:"max_probability"`
+	Segments       int     `json:"segments"`
+}
+
+// nearMiss is a detected tag that may be what the caller was looking for: the
+// target itself below the probability threshold, or a sound from the same
+// category.
+type nearMiss struct {
+	Tag            string  `json:"tag"`
+	Category       string  `json:"category,omitempty"`
+	MaxProbability float64 `json:"max_probability"`
+	Reason         string  `json:"reason"`
+}
+
+type findResult struct {
+	Tag         string       `json:"tag"`
+	Found       bool         `json:"found"`
+	Occurrences []occurrence `json:"occurrences"`
+	NearMisses  []nearMiss   `json:"near_misses,omitempty"`
+}
+
+func FindSound(opts ...SenseOption) (tool mcp.Tool, handler server.ToolHandlerFunc) {
+	cfg := newSenseConfig(opts)
+	tax := taxonomy.Default()
+
+	tool = mcp.NewTool("find_sound",
+		mcp.WithDescription(
+			"Find when specific sounds occur in an audio file. Analyzes the file and returns each "+
+				"occurrence of the requested tags with its start and end time in milliseconds and its "+
+				"highest probability, followed by a short answer. If a sound is not found, the closest "+
+				"detected tags are reported instead. Use search_sound_tags to find tag names.",
+		),
+		mcp.WithString(
+			"file_absolute_path",
+			mcp.Required(),
+			mcp.Description(
+				"Please provide the absolute path to the file.\n"+
+					"Avoid using URL-encoded characters.",
+			),
+		),
+		mcp.WithArray(
+			"tags",
+			mcp.Required(),
+			mcp.Items(map[string]any{"type": "string"}),
+			mcp.Description(
+				"Sound tags to look for, such as [\"Dog_bark\", \"Siren\"].",
+			),
+		),
+		mcp.WithNumber(
+			"min_probability",
+			mcp.Description(
+				"Only count segments where the tag's probability is at least this value. Defaults to 0.5.",
+			),
+		),
+		mcp.WithBoolean(
+			"bypass_cache",
+			mcp.Description(
+				"Analyze the file again even if a cached result for the same audio exists.",
+			),
+		),
+	)
+
+	handler = func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		filePath, err := requiredString(request, "file_absolute_path")
+		if err != nil {
+			return nil, err
+		}
+
+		names, err := requiredStrings(request, "tags")
+		if err != nil {
+			return nil, err
+		}
+		targets, err := tax.Validate(names)
+		if err != nil {
+			return nil, newToolError(nil, "%v. Use search_sound_tags to find tag names", err)
+		}
+
+		minProbability, err := optionalProbability(request, "min_probability", 0.5)
+		if err != nil {
+			return nil, err
+		}
+
+		bypassCache, err := optionalBool(request, "bypass_cache")
+		if err != nil {
+			return nil, err
+		}
+
+		a, err := cfg.analyzeFile(ctx, filePath, bypassCache)
+		if err != nil {
+			return nil, err
+		}
+
+		var found []findResult
+		for _, target := range targets {
+			r := findResult{Tag: target.Name, Occurrences: findOccurrences(a.results, target.Name, minProbability)}
+			r.Found = len(r.Occurrences) > 0
+			if !r.Found {
+				r.NearMisses = findNearMisses(tax, a.results, target, minProbability)
+			}
+			found = append(found, r)
+		}
+
+		jsonResult, err := json.Marshal(found)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal find result: %v", err)
+		}
+
+		result := mcp.NewToolResultText(string(jsonResult))
+		result.Content = append(result.Content, mcp.NewTextContent(describeFindResults(found, minProbability)))
+		return result, nil
+	}
+
+	return tool, withToolErrors(withProgress(handler))
+}
+
+// findOccurrences merges the segments in which tag has at least
+// minProbability into occurrences. Segments that overlap or touch belong to
+// the same occurrence.
+func findOccurrences(results []client.InferenceResult, tag string, minProbability float64) []occurrence {
+	segments := append([]client.InferenceResult(nil), results...)
+	sort.SliceStable(segments, func(i, j int) bool {
+		return segments[i].StartTime < segments[j].StartTime
+	})
+
+	occurrences := []occurrence{}
+	for _, s := range segments {
+		p, ok := tagProbability(s, tag)
+		if !ok || p < minProbability {
+			continue
+		}
+
+		if n := len(occurrences); n > 0 && s.StartTime <= occurrences[n-1].EndTime {
+			last := &occurrences[n-1]
+			last.EndTime = max(last.EndTime, s.EndTime)
+			last.MaxProbability = max(last.MaxProbability, p)
+			last.Segments++
+			continue
+		}
+		occurrences = append(occurrences, occurrence{
+			Tag:            tag,
+			StartTime:      s.StartTime,
+			EndTime:        s.EndTime,
+			MaxProbability: p,
+			Segments:       1,
+		})
+	}
+	return occurrences
+}
+
+// findNearMisses lists detected tags that come closest to target: target
+// itself if it was only detected below minProbability, then tags from the
+// same category, most probable first.
+func findNearMisses(tax *taxonomy.Taxonomy, results []client.InferenceResult, target taxonomy.Tag, minProbability float64) []nearMiss {
+	peaks := make(map[string]float64)
+	for _, s := range results {
+		for _, t := range s.Tags {
+			if t.Name != "Others" {
+				peaks[t.Name] = max(peaks[t.Name], t.Probability)
+			}
+		}
+	}
+
+	var misses []nearMiss
+	if p, ok := peaks[target.Name]; ok {
+		misses = append(misses, nearMiss{
+			Tag:            target.Name,
+			Category:       target.Category,
+			MaxProbability: p,
+			Reason:         fmt.Sprintf("detected below min_probability %.2f", minProbability),
+		})
+	}
+
+	var related []nearMiss
+	for name, p := range peaks {
+		tag, ok := tax.Lookup(name)
+		if !ok || name == target.Name || tag.Category != target.Category {
+			continue
+		}
+		related = append(related, nearMiss{
+			Tag:            name,
+			Category:       tag.Category,
+			MaxProbability: p,
+			Reason:         "detected sound in the same category",
+		})
+	}
+	sort.Slice(related, func(i, j int) bool {
+		if related[i].MaxProbability != related[j].MaxProbability {
+			return related[i].MaxProbability > related[j].MaxProbability
+		}
+		return related[i].Tag < related[j].Tag
+	})
+	misses = append(misses, related...)
+
+	if len(misses) > maxNearMisses {
+		misses = misses[:maxNearMisses]
+	}
+	return misses
+}
+
+func tagProbability(s client.InferenceResult, name string) (float64, bool) {
+	for _, t := range s.Tags {
+		if t.Name == name {
+			return t.Probability, true
+		}
+	}
+	return 0, false
+}
+
+// describeFindResults writes a short answer such as "Dog_bark occurs 2
+// times: 00:01.0-00:03.5 (max probability 0.91), ...".
+func describeFindResults(found []findResult, minProbability float64) string {
+	var lines []string
+	for _, r := range found {
+		if r.Found {
+			var spans []string
+			for _, o := range r.Occurrences {
+				spans = append(spans, fmt.Sprintf("%s-%s (max probability %.2f)",
+					formatMillis(o.StartTime), formatMillis(o.EndTime), o.MaxProbability))
+			}
+			times := "times"
+			if len(r.Occurrences) == 1 {
+				times = "time"
+			}
+			lines = append(lines, fmt.Sprintf("%s occurs %d %s: %s.",
+				r.Tag, len(r.Occurrences), times, strings.Join(spans, ", ")))
+			continue
+		}
+
+		line := fmt.Sprintf("%s was not found with a probability of at least %.2f.", r.Tag, minProbability)
+		if len(r.NearMisses) > 0 {
+			var misses []string
+			for _, m := range r.NearMisses {
+				misses = append(misses, fmt.Sprintf("%s (max probability %.2f, %s)", m.Tag, m.MaxProbability, m.Reason))
+			}
+			line += " Nearest detected tags: " + strings.Join(misses, "; ") + "."
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// formatMillis formats a time offset in milliseconds as mm:ss.s.
+func formatMillis(ms int) string {
+	return fmt.Sprintf("%02d:%04.1f", ms/60000, float64(ms%60000)/1000)
+}
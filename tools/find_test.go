@@ -0,0 +1,107 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/cochlearai/cochl-mcp-server/client"
+	"github.com/cochlearai/cochl-mcp-server/client/fakesense"
+)
+
+func segment(start int, tags ...client.Tags) client.InferenceResult {
+	return client.InferenceResult{StartTime: start, EndTime: start + 1000, Tags: tags}
+}
+
+func TestFindOccurrences(t *testing.T) {
+	results := []client.InferenceResult{
+		segment(0, client.Tags{Name: "Dog_bark", Probability: 0.6}),
+		segment(500, client.Tags{Name: "Dog_bark", Probability: 0.9}),
+		segment(1000, client.Tags{Name: "Dog_bark", Probability: 0.3}),
+		segment(1500, client.Tags{Name: "Speech", Probability: 0.8}),
+		segment(2000, client.Tags{Name: "Others", Probability: 0}),
+		segment(2500, client.Tags{Name: "Dog_bark", Probability: 0.7}),
+	}
+
+	want := []occurrence{
+		{Tag: "Dog_bark", StartTime: 0, EndTime: 1500, MaxProbability: 0.9, Segments: 2},
+		{Tag: "Dog_bark", StartTime: 2500, EndTime: 3500, MaxProbability: 0.7, Segments: 1},
+	}
+	if got := findOccurrences(results, "Dog_bark", 0.5); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if got := findOccurrences(results, "Siren", 0.5); len(got) != 0 {
+		t.Errorf("expected no occurrences, got %+v", got)
+	}
+}
+
+func TestFindSound(t *testing.T) {
+	setInstantPolling(t)
+
+	ctx, _ := newFakeSenseContext(t, fakesense.WithResults(
+		segment(0, client.Tags{Name: "Dog_bark", Probability: 0.9}),
+		segment(500, client.Tags{Name: "Dog_bark", Probability: 0.8}),
+		segment(1000, client.Tags{Name: "Dog_growl", Probability: 0.7}, client.Tags{Name: "Cat_meow", Probability: 0.4}),
+		segment(1500, client.Tags{Name: "Cat_meow", Probability: 0.3}),
+	))
+
+	_, handler := FindSound()
+	result, err := handler(ctx, newCallToolRequest(map[string]any{
+		"file_absolute_path": testdataPath(t, "wav-test.wav"),
+		"tags":               []any{"dog bark", "Cat_meow"},
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.IsError {
+		t.Fatalf("unexpected tool error: %s", resultText(t, result))
+	}
+
+	var got []findResult
+	if err := json.Unmarshal([]byte(resultText(t, result)), &got); err != nil {
+		t.Fatalf("failed to decode result: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected 2 results, got %+v", got)
+	}
+	if !got[0].Found || len(got[0].Occurrences) != 1 || got[0].Occurrences[0].EndTime != 1500 {
+		t.Errorf("unexpected Dog_bark result: %+v", got[0])
+	}
+	if got[1].Found || len(got[1].NearMisses) != 3 {
+		t.Fatalf("unexpected Cat_meow result: %+v", got[1])
+	}
+	if m := got[1].NearMisses; m[0].Tag != "Cat_meow" || m[1].Tag != "Dog_bark" || m[2].Tag != "Dog_growl" {
+		t.Errorf("unexpected near misses: %+v", m)
+	}
+
+	answer, ok := result.Content[1].(mcp.TextContent)
+	if !ok {
+		t.Fatalf("expected text answer, got %T", result.Content[1])
+	}
+	for _, want := range []string{
+		"Dog_bark occurs 1 time: 00:00.0-00:01.5 (max probability 0.90).",
+		"Cat_meow was not found with a probability of at least 0.50. Nearest detected tags: Cat_meow (max probability 0.40",
+	} {
+		if !strings.Contains(answer.Text, want) {
+			t.Errorf("expected answer to contain %q, got:\n%s", want, answer.Text)
+		}
+	}
+}
+
+func TestFindSoundUnknownTag(t *testing.T) {
+	_, handler := FindSound()
+	result, err := handler(context.Background(), newCallToolRequest(map[string]any{
+		"file_absolute_path": testdataPath(t, "wav-test.wav"),
+		"tags":               []any{"siern"},
+	}))
+	if err != nil {
+		t.Fatalf("expected tool error result, got protocol error: %v", err)
+	}
+	if text := resultText(t, result); !result.IsError || !strings.Contains(text, "did you mean Siren") {
+		t.Errorf("expected unknown tag error with suggestion, got %q", text)
+	}
+}
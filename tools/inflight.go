@@ -0,0 +1,138 @@
+package tools
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/cochlearai/cochl-mcp-server/client"
+)
+
+const (
+	// cleanupGrace is how long canceled analyses get to delete their own
+	// sessions before Drain deletes the rest.
+	cleanupGrace = time.Second
+	// deleteTimeout bounds every session deletion, including those of Drain.
+	deleteTimeout = 5 * time.Second
+)
+
+// Inflight tracks running analyses and their open Cochl Sense sessions, so
+// the server can let them finish on shutdown and clean up those that don't.
+type Inflight struct {
+	mu       sync.Mutex
+	closed   bool
+	running  sync.WaitGroup
+	sessions map[string]client.CochlSense
+
+	// ctx is canceled when the drain deadline passes, aborting the
+	// analyses that are still running.
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func NewInflight() *Inflight {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Inflight{
+		sessions: make(map[string]client.CochlSense),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+// WithInflight tracks every analysis in inflight.
+func WithInflight(inflight *Inflight) SenseOption {
+	return func(cfg *senseConfig) {
+		cfg.inflight = inflight
+	}
+}
+
+// start registers an analysis. The returned context is canceled with ctx or
+// when the drain deadline passes; done must be called when the analysis ends.
+// It fails once Drain has been called.
+func (i *Inflight) start(ctx context.Context) (context.Context, func(), error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.closed {
+		return nil, nil, newToolError(nil, "the server is shutting down and does not accept new analyses")
+	}
+	i.running.Add(1)
+
+	ctx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(i.ctx, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+		i.running.Done()
+	}, nil
+}
+
+func (i *Inflight) trackSession(id string, c client.CochlSense) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.sessions[id] = c
+}
+
+// untrackSession reports whether the session was still tracked. Only the
+// caller that untracks a session may delete it.
+func (i *Inflight) untrackSession(id string) bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	_, ok := i.sessions[id]
+	delete(i.sessions, id)
+	return ok
+}
+
+// Drain stops new analyses and waits for running ones until ctx is done.
+// Analyses still running then are canceled, and the Cochl Sense sessions they
+// do not delete themselves in time are deleted.
+func (i *Inflight) Drain(ctx context.Context) {
+	i.mu.Lock()
+	i.closed = true
+	i.mu.Unlock()
+
+	finished := make(chan struct{})
+	go func() {
+		i.running.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		slog.Info("All analyses finished")
+		return
+	case <-ctx.Done():
+	}
+
+	slog.Warn("Shutdown deadline passed, canceling running analyses")
+	i.cancel()
+
+	// Canceled analyses delete their own sessions, untracking them first.
+	select {
+	case <-finished:
+	case <-time.After(cleanupGrace):
+	}
+
+	i.mu.Lock()
+	sessions := i.sessions
+	i.sessions = make(map[string]client.CochlSense)
+	i.mu.Unlock()
+
+	deleteCtx, cancel := context.WithTimeout(context.Background(), deleteTimeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for id, c := range sessions {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := c.DeleteSession(deleteCtx, id); err != nil {
+				slog.Warn("Failed to delete session", "session_id", id, "error", err)
+			} else {
+				slog.Info("Deleted session of canceled analysis", "session_id", id)
+			}
+		}()
+	}
+	wg.Wait()
+}
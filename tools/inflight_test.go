@@ -0,0 +1,154 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/cochlearai/cochl-mcp-server/client"
+	"github.com/cochlearai/cochl-mcp-server/client/fakesense"
+	"github.com/cochlearai/cochl-mcp-server/common"
+	"github.com/cochlearai/cochl-mcp-server/poll"
+)
+
+// startAnalysis calls handler in the background and waits until it has
+// created a Cochl Sense session.
+func startAnalysis(t *testing.T, ctx context.Context, fake *fakesense.Server, handler func(context.Context, mcp.CallToolRequest) (*mcp.CallToolResult, error)) <-chan *mcp.CallToolResult {
+	t.Helper()
+
+	results := make(chan *mcp.CallToolResult, 1)
+	go func() {
+		result, _ := handler(ctx, newCallToolRequest(map[string]any{"file_absolute_path": testdataPath(t, "wav-test.wav")}))
+		results <- result
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(fake.Sessions()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("analysis did not create a session")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return results
+}
+
+func TestInflightDrainWaitsForAnalyses(t *testing.T) {
+	setPolling(t, poll.Strategy{InitialDelay: 10 * time.Millisecond, Multiplier: 1, MaxDelay: 10 * time.Millisecond})
+	ctx, fake := newFakeSenseContext(t, fakesense.WithPendingPolls(5))
+
+	inflight := NewInflight()
+	_, handler := Sense(WithInflight(inflight))
+	results := startAnalysis(t, ctx, fake, handler)
+
+	drainCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	inflight.Drain(drainCtx)
+
+	select {
+	case result := <-results:
+		if result == nil || result.IsError {
+			t.Fatalf("expected the analysis to finish, got %+v", result)
+		}
+	default:
+		t.Fatal("Drain returned before the analysis finished")
+	}
+	if sessions := fake.Sessions(); !sessions[0].Deleted {
+		t.Error("expected session to be deleted")
+	}
+}
+
+func TestInflightDrainCancelsAnalysesAfterDeadline(t *testing.T) {
+	setPolling(t, poll.Strategy{InitialDelay: 10 * time.Millisecond, Multiplier: 1, MaxDelay: 10 * time.Millisecond})
+	ctx, fake := newFakeSenseContext(t, fakesense.WithPendingPolls(1<<20))
+
+	inflight := NewInflight()
+	_, handler := Sense(WithInflight(inflight))
+	results := startAnalysis(t, ctx, fake, handler)
+
+	drainCtx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	inflight.Drain(drainCtx)
+
+	result := <-results
+	if result == nil || !result.IsError || !strings.Contains(resultText(t, result), "analysis canceled") {
+		t.Fatalf("expected a canceled analysis, got %+v", result)
+	}
+	sessions := fake.Sessions()
+	if len(sessions) != 1 || !sessions[0].Deleted {
+		t.Errorf("expected one deleted session, got %+v", sessions)
+	}
+
+	// No analysis starts once draining has begun.
+	result, err := handler(ctx, newCallToolRequest(map[string]any{"file_absolute_path": testdataPath(t, "wav-test.wav")}))
+	if err != nil {
+		t.Fatalf("unexpected protocol error: %v", err)
+	}
+	if !result.IsError || !strings.Contains(resultText(t, result), "shutting down") {
+		t.Errorf("expected shutdown error, got %q", resultText(t, result))
+	}
+	if n := len(fake.Sessions()); n != 1 {
+		t.Errorf("expected no new session, got %d sessions", n)
+	}
+}
+
+type deleteCounter struct {
+	client.CochlSense
+	deletes atomic.Int32
+}
+
+func (c *deleteCounter) DeleteSession(ctx context.Context, sessionID string) error {
+	c.deletes.Add(1)
+	return c.CochlSense.DeleteSession(ctx, sessionID)
+}
+
+func TestInflightDrainDeletesEachSessionOnce(t *testing.T) {
+	setPolling(t, poll.Strategy{InitialDelay: 10 * time.Millisecond, Multiplier: 1, MaxDelay: 10 * time.Millisecond})
+	fake := fakesense.NewServer(fakesense.WithPendingPolls(1 << 20))
+	t.Cleanup(fake.Close)
+	c := &deleteCounter{CochlSense: client.NewCochlSense("test-key", fake.URL, "test")}
+	ctx := common.WithCochlSenseClient(context.Background(), c)
+
+	inflight := NewInflight()
+	_, handler := Sense(WithInflight(inflight))
+	const analyses = 3
+	results := make(chan *mcp.CallToolResult, analyses)
+	for range analyses {
+		go func() {
+			result, _ := handler(ctx, newCallToolRequest(map[string]any{"file_absolute_path": testdataPath(t, "wav-test.wav")}))
+			results <- result
+		}()
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for tracked := 0; tracked < analyses; {
+		if time.Now().After(deadline) {
+			t.Fatal("analyses did not create their sessions")
+		}
+		time.Sleep(5 * time.Millisecond)
+		inflight.mu.Lock()
+		tracked = len(inflight.sessions)
+		inflight.mu.Unlock()
+	}
+
+	drainCtx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	inflight.Drain(drainCtx)
+	for range analyses {
+		<-results
+	}
+
+	for _, s := range fake.Sessions() {
+		if !s.Deleted {
+			t.Errorf("expected session %s to be deleted", s.ID)
+		}
+	}
+	if n := c.deletes.Load(); n != analyses {
+		t.Errorf("expected %d deletions, got %d", analyses, n)
+	}
+	if len(inflight.sessions) != 0 {
+		t.Errorf("expected no tracked sessions, got %d", len(inflight.sessions))
+	}
+}
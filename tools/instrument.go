@@ -0,0 +1,53 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/cochlearai/cochl-mcp-server/metrics"
+	"github.com/cochlearai/cochl-mcp-server/tracing"
+)
+
+// Instrument counts the calls of a tool and their duration in the metrics
+// package, and traces each call in a span. It takes and returns a tool and
+// handler so it can wrap a tool constructor directly:
+//
+//	s.AddTool(tools.Instrument(tools.Sense()))
+func Instrument(tool mcp.Tool, handler server.ToolHandlerFunc) (mcp.Tool, server.ToolHandlerFunc) {
+	return tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		start := time.Now()
+		ctx, span := tracing.Start(ctx, "tools/call "+tool.Name, attribute.String("mcp.tool.name", tool.Name))
+		result, err := handler(ctx, request)
+
+		outcome := "success"
+		spanErr := err
+		switch {
+		case err != nil:
+			outcome = "error"
+		case result != nil && result.IsError:
+			outcome = "tool_error"
+			spanErr = errors.New(toolResultText(result))
+		}
+		span.SetAttributes(attribute.String("mcp.tool.result", outcome))
+		tracing.EndSpan(span, spanErr)
+
+		metrics.ToolCalls.Inc(tool.Name, outcome)
+		metrics.ToolCallDuration.Observe(time.Since(start).Seconds(), tool.Name)
+		return result, err
+	}
+}
+
+// toolResultText returns the message of an isError result.
+func toolResultText(result *mcp.CallToolResult) string {
+	for _, content := range result.Content {
+		if text, ok := content.(mcp.TextContent); ok {
+			return text.Text
+		}
+	}
+	return "tool error"
+}
@@ -0,0 +1,61 @@
+package tools
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/cochlearai/cochl-mcp-server/client/fakesense"
+	"github.com/cochlearai/cochl-mcp-server/metrics"
+)
+
+func TestInstrumentCountsResults(t *testing.T) {
+	tool, handler := Instrument(SearchSoundTags())
+	successes := metrics.ToolCalls.Value(tool.Name, "success")
+	toolErrors := metrics.ToolCalls.Value(tool.Name, "tool_error")
+	calls := metrics.ToolCallDuration.Count(tool.Name)
+
+	handler(context.Background(), newCallToolRequest(map[string]any{"query": "dog"}))
+	handler(context.Background(), newCallToolRequest(map[string]any{}))
+
+	if got := metrics.ToolCalls.Value(tool.Name, "success") - successes; got != 1 {
+		t.Errorf("got %v successful calls, want 1", got)
+	}
+	if got := metrics.ToolCalls.Value(tool.Name, "tool_error") - toolErrors; got != 1 {
+		t.Errorf("got %v failed calls, want 1", got)
+	}
+	if got := metrics.ToolCallDuration.Count(tool.Name) - calls; got != 2 {
+		t.Errorf("got %d durations, want 2", got)
+	}
+}
+
+func TestAnalysisMetrics(t *testing.T) {
+	setInstantPolling(t)
+	ctx, fake := newFakeSenseContext(t, fakesense.WithPendingPolls(2))
+
+	uploaded := metrics.UploadBytes.Value()
+	analyses := metrics.PollIterations.Count()
+	apiErrors := metrics.APIErrors.Value("create_session", "401")
+
+	_, handler := Sense()
+	args := map[string]any{"file_absolute_path": testdataPath(t, "wav-test.wav"), "bypass_cache": true}
+	if result, err := handler(ctx, newCallToolRequest(args)); err != nil || result.IsError {
+		t.Fatalf("analysis failed: %v %+v", err, result)
+	}
+
+	if got, want := metrics.UploadBytes.Value()-uploaded, float64(len(fake.Sessions()[0].Data())); got != want {
+		t.Errorf("got %v uploaded bytes, want %v", got, want)
+	}
+	if got := metrics.PollIterations.Count() - analyses; got != 1 {
+		t.Errorf("got %d poll observations, want 1", got)
+	}
+	if got := metrics.ActiveSessions.Value(); got != 0 {
+		t.Errorf("got %v active sessions after the analysis, want 0", got)
+	}
+
+	fake.Script(fakesense.EndpointCreateSession, fakesense.Response{Status: http.StatusUnauthorized, Body: `{}`})
+	handler(ctx, newCallToolRequest(args))
+	if got := metrics.APIErrors.Value("create_session", "401") - apiErrors; got != 1 {
+		t.Errorf("got %v API errors, want 1", got)
+	}
+}
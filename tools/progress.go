@@ -0,0 +1,186 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+
+	"github.com/cochlearai/cochl-mcp-server/client"
+	"github.com/cochlearai/cochl-mcp-server/throttle"
+	"github.com/cochlearai/cochl-mcp-server/util"
+)
+
+// partialResultsLogger names the log messages that carry partial results.
+const partialResultsLogger = "cochl-sense"
+
+type progressKey struct{}
+
+// progressReporter sends the partial results of the analyses of one tool
+// call to the client that made it: as notifications/progress if the call has
+// a progress token, and as notifications/message log messages holding the new
+// segments.
+type progressReporter struct {
+	server *server.MCPServer
+	token  mcp.ProgressToken
+
+	mu sync.Mutex
+	// files holds the progress of each file analyzed by the call, in
+	// milliseconds of audio, so concurrent analyses report one total.
+	files map[string]fileProgress
+	// expected is the number of files the call analyzes. The total is only
+	// reported once every file's length is known, so it never changes.
+	expected int
+}
+
+type fileProgress struct {
+	analyzed int
+	total    int
+}
+
+// withProgress lets the analyses run by handler report partial results.
+func withProgress(handler server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		srv := server.ServerFromContext(ctx)
+		if srv == nil {
+			return handler(ctx, request)
+		}
+
+		p := &progressReporter{server: srv, files: make(map[string]fileProgress)}
+		if request.Params.Meta != nil {
+			p.token = request.Params.Meta.ProgressToken
+		}
+		notifyCtx := ctx
+		ctx = throttle.WithWaitFunc(ctx, func(w throttle.Wait) { p.reportQueued(notifyCtx, w) })
+		return handler(context.WithValue(ctx, progressKey{}, p), request)
+	}
+}
+
+// expectFiles tells the progress reporter of ctx, if any, that the files at
+// paths are analyzed concurrently. Paths naming the same file count once.
+func expectFiles(ctx context.Context, paths []string) {
+	p, ok := ctx.Value(progressKey{}).(*progressReporter)
+	if !ok {
+		return
+	}
+	files := make(map[string]bool)
+	for _, path := range paths {
+		if normalized, err := util.NormalizePath(path); err == nil {
+			path = normalized
+		}
+		files[path] = true
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.expected = len(files)
+}
+
+// startProgress adds the length of the file at filePath to the total
+// progress of the call, before any segment of it arrives.
+func startProgress(ctx context.Context, filePath string, durationSeconds float64) {
+	if p, ok := ctx.Value(progressKey{}).(*progressReporter); ok {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if _, ok := p.files[filePath]; !ok {
+			p.files[filePath] = fileProgress{total: int(durationSeconds * 1000)}
+		}
+	}
+}
+
+// reportPartialResults sends the segments of the file at filePath that
+// arrived since the last report, given the segments so far and the length of
+// the audio.
+func reportPartialResults(ctx context.Context, filePath string, durationSeconds float64, segments, newSegments []client.InferenceResult) {
+	p, ok := ctx.Value(progressKey{}).(*progressReporter)
+	if !ok || len(newSegments) == 0 {
+		return
+	}
+
+	analyzed := 0
+	for _, s := range segments {
+		analyzed = max(analyzed, s.EndTime)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.files[filePath] = fileProgress{analyzed: analyzed, total: int(durationSeconds * 1000)}
+	message := fmt.Sprintf("%d segments of %s analyzed so far", len(segments), filepath.Base(filePath))
+
+	if p.token != nil {
+		var progress, total int
+		for _, f := range p.files {
+			progress += f.analyzed
+			total += f.total
+		}
+		params := map[string]any{
+			"progressToken": p.token,
+			"progress":      progress,
+			"message":       message,
+		}
+		if total > 0 && len(p.files) >= p.expected {
+			params["total"] = total
+		}
+		p.notify(ctx, "notifications/progress", params)
+	}
+
+	p.notify(ctx, "notifications/message", map[string]any{
+		"level":  mcp.LoggingLevelInfo,
+		"logger": partialResultsLogger,
+		"data": map[string]any{
+			"message":  message,
+			"file":     filePath,
+			"segments": newSegments,
+		},
+	})
+}
+
+// reportQueued tells the caller that an analysis waits for a limit of the
+// server before it can reach Cochl Sense.
+func (p *progressReporter) reportQueued(ctx context.Context, w throttle.Wait) {
+	var message string
+	switch w.Limit {
+	case throttle.LimitSessions:
+		message = fmt.Sprintf("waiting for a free Cochl Sense session, position %d in queue", w.Position)
+	default:
+		message = fmt.Sprintf("waiting %s for the Cochl Sense rate limit, position %d in queue",
+			w.Delay.Round(time.Millisecond), w.Position)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.token != nil {
+		var progress int
+		for _, f := range p.files {
+			progress += f.analyzed
+		}
+		p.notify(ctx, "notifications/progress", map[string]any{
+			"progressToken": p.token,
+			"progress":      progress,
+			"message":       message,
+		})
+	}
+
+	p.notify(ctx, "notifications/message", map[string]any{
+		"level":  mcp.LoggingLevelInfo,
+		"logger": partialResultsLogger,
+		"data": map[string]any{
+			"message":        message,
+			"limit":          w.Limit,
+			"queue_position": w.Position,
+		},
+	})
+}
+
+func (p *progressReporter) notify(ctx context.Context, method string, params map[string]any) {
+	if err := p.server.SendNotificationToClient(ctx, method, params); err != nil {
+		slog.Debug("Failed to send partial results", "method", method, "error", err)
+	}
+}
@@ -0,0 +1,209 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+
+	"github.com/cochlearai/cochl-mcp-server/client"
+	"github.com/cochlearai/cochl-mcp-server/client/fakesense"
+	"github.com/cochlearai/cochl-mcp-server/common"
+	"github.com/cochlearai/cochl-mcp-server/poll"
+	"github.com/cochlearai/cochl-mcp-server/throttle"
+	"github.com/cochlearai/cochl-mcp-server/util/audio"
+)
+
+type testSession struct {
+	notifications chan mcp.JSONRPCNotification
+}
+
+func (s *testSession) SessionID() string                                   { return "test-session" }
+func (s *testSession) NotificationChannel() chan<- mcp.JSONRPCNotification { return s.notifications }
+func (s *testSession) Initialize()                                         {}
+func (s *testSession) Initialized() bool                                   { return true }
+
+func TestSensePartialResults(t *testing.T) {
+	setInstantPolling(t)
+	want := []client.InferenceResult{
+		{StartTime: 0, EndTime: 1000, Tags: []client.Tags{{Name: "Speech", Probability: 0.9}}},
+		{StartTime: 500, EndTime: 1500, Tags: []client.Tags{{Name: "Speech", Probability: 0.8}}},
+		{StartTime: 1000, EndTime: 2000, Tags: []client.Tags{{Name: "Dog_bark", Probability: 0.7}}},
+		{StartTime: 1500, EndTime: 2500, Tags: []client.Tags{{Name: "Dog_bark", Probability: 0.6}}},
+		{StartTime: 2000, EndTime: 3000, Tags: []client.Tags{{Name: "Others", Probability: 0.5}}},
+	}
+	ctx, fake := newFakeSenseContext(t,
+		fakesense.WithResults(want...),
+		fakesense.WithPendingPolls(3),
+		fakesense.WithPagedResults(2),
+	)
+
+	s := server.NewMCPServer("test", "1.0.0", server.WithLogging())
+	s.AddTool(Sense())
+	session := &testSession{notifications: make(chan mcp.JSONRPCNotification, 16)}
+	ctx = s.WithContext(ctx, session)
+
+	request, _ := json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "tools/call",
+		"params": map[string]any{
+			"name":      "analyze_audio",
+			"arguments": map[string]any{"file_absolute_path": testdataPath(t, "wav-test.wav")},
+			"_meta":     map[string]any{"progressToken": "analysis-1"},
+		},
+	})
+	response, ok := s.HandleMessage(ctx, request).(mcp.JSONRPCResponse)
+	if !ok {
+		t.Fatal("expected a JSON-RPC response")
+	}
+	result := response.Result.(mcp.CallToolResult)
+	var got []client.InferenceResult
+	if err := json.Unmarshal([]byte(resultText(t, &result)), &got); err != nil {
+		t.Fatalf("failed to decode result: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	close(session.notifications)
+
+	// Two pending polls each released a page before the final one.
+	var progress []any
+	var partial [][]client.InferenceResult
+	for n := range session.notifications {
+		params := n.Params.AdditionalFields
+		switch n.Method {
+		case "notifications/progress":
+			if params["progressToken"] != "analysis-1" || params["total"] == nil {
+				t.Errorf("unexpected progress params: %v", params)
+			}
+			progress = append(progress, params["progress"])
+		case "notifications/message":
+			data := params["data"].(map[string]any)
+			partial = append(partial, data["segments"].([]client.InferenceResult))
+		}
+	}
+	if !reflect.DeepEqual(progress, []any{1500, 2500}) {
+		t.Errorf("got progress %v, want [1500 2500]", progress)
+	}
+	if !reflect.DeepEqual(partial, [][]client.InferenceResult{want[:2], want[2:4]}) {
+		t.Errorf("got partial results %+v", partial)
+	}
+	if polls := fake.Sessions()[0].Polls; polls != 4 {
+		t.Errorf("got %d polls, want 4", polls)
+	}
+}
+
+func TestSenseReportsQueuePosition(t *testing.T) {
+	setInstantPolling(t)
+	fake := fakesense.NewServer(fakesense.WithResults(client.InferenceResult{EndTime: 1000}))
+	t.Cleanup(fake.Close)
+	limits := throttle.New(throttle.Config{Rate: 1, Burst: 1}, throttle.WithClock(&poll.InstantClock{}))
+	c := limits.Client(client.NewCochlSense("test-key", fake.URL, "test"), fake.URL, "test-key")
+	ctx := common.WithCochlSenseClient(context.Background(), c)
+
+	s := server.NewMCPServer("test", "1.0.0", server.WithLogging())
+	s.AddTool(Sense())
+	session := &testSession{notifications: make(chan mcp.JSONRPCNotification, 16)}
+	ctx = s.WithContext(ctx, session)
+
+	request, _ := json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "tools/call",
+		"params": map[string]any{
+			"name":      "analyze_audio",
+			"arguments": map[string]any{"file_absolute_path": testdataPath(t, "wav-test.wav")},
+			"_meta":     map[string]any{"progressToken": "analysis-1"},
+		},
+	})
+	response, ok := s.HandleMessage(ctx, request).(mcp.JSONRPCResponse)
+	if !ok || response.Result.(mcp.CallToolResult).IsError {
+		t.Fatalf("analysis failed: %+v", response)
+	}
+	close(session.notifications)
+
+	// The session is created with the burst, then the upload waits.
+	var messages []string
+	for n := range session.notifications {
+		params := n.Params.AdditionalFields
+		if n.Method == "notifications/progress" {
+			messages = append(messages, params["message"].(string))
+		}
+	}
+	if len(messages) == 0 || messages[0] != "waiting 1s for the Cochl Sense rate limit, position 1 in queue" {
+		t.Errorf("got progress messages %q", messages)
+	}
+}
+
+func TestCompareProgressTotal(t *testing.T) {
+	setInstantPolling(t)
+	ctx, _ := newFakeSenseContext(t,
+		fakesense.WithResults(
+			client.InferenceResult{StartTime: 0, EndTime: 1000},
+			client.InferenceResult{StartTime: 1000, EndTime: 2000},
+		),
+		fakesense.WithPendingPolls(2),
+		fakesense.WithPagedResults(1),
+	)
+
+	s := server.NewMCPServer("test", "1.0.0", server.WithLogging())
+	s.AddTool(CompareAudio())
+	session := &testSession{notifications: make(chan mcp.JSONRPCNotification, 32)}
+	ctx = s.WithContext(ctx, session)
+
+	paths := []string{testdataPath(t, "wav-test.wav"), testdataPath(t, "mp3-test.mp3")}
+	wantTotal := 0
+	for _, path := range paths {
+		info, err := audio.GetAudioInfo(path)
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", path, err)
+		}
+		wantTotal += int(info.Duration * 1000)
+	}
+
+	request, _ := json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "tools/call",
+		"params": map[string]any{
+			"name": "compare_audio",
+			"arguments": map[string]any{
+				"baseline_file_absolute_path":   paths[0],
+				"comparison_file_absolute_path": paths[1],
+			},
+			"_meta": map[string]any{"progressToken": "compare-1"},
+		},
+	})
+	response, ok := s.HandleMessage(ctx, request).(mcp.JSONRPCResponse)
+	if !ok || response.Result.(mcp.CallToolResult).IsError {
+		t.Fatalf("comparison failed: %+v", response)
+	}
+	close(session.notifications)
+
+	// Both files are counted in the total from the first report on, so
+	// neither the total nor the progress goes backwards.
+	last, reports := 0, 0
+	for n := range session.notifications {
+		if n.Method != "notifications/progress" {
+			continue
+		}
+		params := n.Params.AdditionalFields
+		if total := params["total"]; total != nil && total != wantTotal {
+			t.Errorf("got total %v, want %d", total, wantTotal)
+		}
+		progress := params["progress"].(int)
+		if progress < last {
+			t.Errorf("progress went back from %d to %d", last, progress)
+		}
+		last = progress
+		reports++
+	}
+	// Each file reports its first segment while pending.
+	if reports != 2 || last != 2000 {
+		t.Errorf("got %d progress reports ending at %d, want 2 ending at 2000", reports, last)
+	}
+}
@@ -9,13 +9,12 @@ import (
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 
-	"github.com/cochlearai/cochl-mcp-server/client"
-	"github.com/cochlearai/cochl-mcp-server/common"
-	"github.com/cochlearai/cochl-mcp-server/util"
-	"github.com/cochlearai/cochl-mcp-server/util/audio"
+	"github.com/cochlearai/cochl-mcp-server/resources"
 )
 
-func Sense() (tool mcp.Tool, handler server.ToolHandlerFunc) {
+func Sense(opts ...SenseOption) (tool mcp.Tool, handler server.ToolHandlerFunc) {
+	cfg := newSenseConfig(opts)
+
 	tool = mcp.NewTool("analyze_audio",
 		mcp.WithDescription(
 			"Analyze an audio file and return detected sounds, events, and their probabilities. "+
@@ -32,77 +31,48 @@ func Sense() (tool mcp.Tool, handler server.ToolHandlerFunc) {
 					"Avoid using URL-encoded characters.",
 			),
 		),
+		mcp.WithBoolean(
+			"bypass_cache",
+			mcp.Description(
+				"Analyze the file again even if a cached result for the same audio exists.",
+			),
+		),
 	)
 
 	handler = func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-		filePath := request.Params.Arguments["file_absolute_path"].(string)
-
-		// normalize path
-		normalizedPath, err := util.NormalizePath(filePath)
+		filePath, err := requiredString(request, "file_absolute_path")
 		if err != nil {
-			return nil, fmt.Errorf("invalid file path: %v", err)
+			return nil, err
 		}
-		filePath = normalizedPath
 
-		audioInfo, err := audio.GetAudioInfo(filePath)
+		bypassCache, err := optionalBool(request, "bypass_cache")
 		if err != nil {
-			return nil, fmt.Errorf("failed to get audio info: %v", err)
+			return nil, err
 		}
 
-		// Get raw audio data
-		rawData, err := audio.GetRawAudioData(filePath)
+		a, err := cfg.analyzeFile(ctx, filePath, bypassCache)
 		if err != nil {
-			return nil, fmt.Errorf("failed to get raw audio data: %v", err)
+			return nil, err
 		}
 
-		cochlSenseClient := common.CochlSenseClientFromContext(ctx)
-		if cochlSenseClient == nil {
-			return nil, fmt.Errorf("cochl sense client not found")
-		}
-
-		resp, err := cochlSenseClient.CreateSession(
-			audioInfo.FileName,
-			audioInfo.Format,
-			audioInfo.Duration,
-			audioInfo.Size)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create session: %v", err)
-		}
-
-		//TODO: if file is too large, upload in chunks
-		_, err = cochlSenseClient.UploadChunk(
-			resp.SessionID,
-			resp.ChunkSequence,
-			rawData)
-		if err != nil {
-			return nil, fmt.Errorf("failed to upload chunk: %v", err)
-		}
-
-		var result *client.RespInferenceResult
-		//TODO: set timeout
-		for {
-			time.Sleep(2 * time.Second)
-			inferenceResult, err := cochlSenseClient.GetInferenceResult(resp.SessionID)
-			if err != nil {
-				return nil, fmt.Errorf("failed to get inference result: %v", err)
-			}
-
-			if inferenceResult.State == "done" {
-				result = inferenceResult
-				break
-			}
-		}
-		jsonResult, err := json.Marshal(result.Data)
+		jsonResult, err := json.Marshal(a.results)
 		if err != nil {
 			return nil, fmt.Errorf("failed to marshal inference result: %v", err)
 		}
 
-		if err := cochlSenseClient.DeleteSession(resp.SessionID); err != nil {
-			return nil, fmt.Errorf("failed to delete session: %v", err)
+		result := mcp.NewToolResultText(string(jsonResult))
+		if !a.cachedAt.IsZero() {
+			result.Content = append(result.Content, mcp.NewTextContent(fmt.Sprintf(
+				"Cache hit: this result was computed at %s for identical audio. "+
+					"Set bypass_cache to true to analyze the file again.",
+				a.cachedAt.Format(time.RFC3339))))
 		}
-
-		return mcp.NewToolResultText(string(jsonResult)), nil
+		if a.historyID != "" {
+			result.Content = append(result.Content, mcp.NewTextContent(fmt.Sprintf(
+				"This analysis was saved as the resource %s.", resources.AnalysisURI(a.historyID))))
+		}
+		return result, nil
 	}
 
-	return tool, handler
+	return tool, withToolErrors(withProgress(handler))
 }
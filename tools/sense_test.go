@@ -0,0 +1,435 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/cochlearai/cochl-mcp-server/cache"
+	"github.com/cochlearai/cochl-mcp-server/client"
+	"github.com/cochlearai/cochl-mcp-server/client/fakesense"
+	"github.com/cochlearai/cochl-mcp-server/common"
+	"github.com/cochlearai/cochl-mcp-server/poll"
+	"github.com/cochlearai/cochl-mcp-server/util/audio"
+)
+
+func newCallToolRequest(args map[string]any) mcp.CallToolRequest {
+	var req mcp.CallToolRequest
+	req.Params.Name = "analyze_audio"
+	req.Params.Arguments = args
+	return req
+}
+
+func testdataPath(t *testing.T, name string) string {
+	t.Helper()
+	p, err := filepath.Abs(filepath.Join("..", "util", "audio", "testdata", name))
+	if err != nil {
+		t.Fatalf("failed to resolve testdata path: %v", err)
+	}
+	return p
+}
+
+func resultText(t *testing.T, result *mcp.CallToolResult) string {
+	t.Helper()
+	if len(result.Content) == 0 {
+		t.Fatal("expected content in tool result")
+	}
+	text, ok := result.Content[0].(mcp.TextContent)
+	if !ok {
+		t.Fatalf("expected text content, got %T", result.Content[0])
+	}
+	return text.Text
+}
+
+func TestSenseToolErrors(t *testing.T) {
+	dir := t.TempDir()
+	unsupported := filepath.Join(dir, "audio.xyz")
+	if err := os.WriteFile(unsupported, []byte{0x00}, 0644); err != nil {
+		t.Fatalf("failed to create test file: %v", err)
+	}
+	corrupt := filepath.Join(dir, "corrupt.wav")
+	if err := os.WriteFile(corrupt, []byte("not a wav file"), 0644); err != nil {
+		t.Fatalf("failed to create test file: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		args        map[string]any
+		wantMessage string
+	}{
+		{
+			name:        "Missing argument",
+			args:        map[string]any{},
+			wantMessage: `missing required argument "file_absolute_path"`,
+		},
+		{
+			name:        "Non-string argument",
+			args:        map[string]any{"file_absolute_path": 42},
+			wantMessage: "must be a non-empty string",
+		},
+		{
+			name:        "Relative path",
+			args:        map[string]any{"file_absolute_path": "relative/file.wav"},
+			wantMessage: "Provide an absolute path",
+		},
+		{
+			name:        "Non-existent file",
+			args:        map[string]any{"file_absolute_path": filepath.Join(dir, "missing.wav")},
+			wantMessage: "file not found",
+		},
+		{
+			name:        "Unsupported format",
+			args:        map[string]any{"file_absolute_path": unsupported},
+			wantMessage: "Supported formats are: mp3, ogg, wav",
+		},
+		{
+			name:        "Corrupt file",
+			args:        map[string]any{"file_absolute_path": corrupt},
+			wantMessage: "may be corrupt",
+		},
+	}
+
+	_, handler := Sense()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := handler(context.Background(), newCallToolRequest(tt.args))
+			if err != nil {
+				t.Fatalf("expected tool error result, got protocol error: %v", err)
+			}
+			if !result.IsError {
+				t.Fatal("expected IsError to be set")
+			}
+			if text := resultText(t, result); !strings.Contains(text, tt.wantMessage) {
+				t.Errorf("got message %q, want it to contain %q", text, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestSenseValidatesAudio(t *testing.T) {
+	ctx, fake := newFakeSenseContext(t)
+	wav, err := os.ReadFile(testdataPath(t, "wav-test.wav"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	truncated := filepath.Join(t.TempDir(), "truncated.wav")
+	if err := os.WriteFile(truncated, wav[:1000], 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// A sparse file that is not Ogg at all: reading it would report it as
+	// corrupt instead of too large.
+	oversized := filepath.Join(t.TempDir(), "oversized.ogg")
+	if err := os.WriteFile(oversized, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(oversized, audio.DefaultMaxSize+1); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		path        string
+		limits      audio.Limits
+		wantMessage string
+	}{
+		{
+			name:        "Too large",
+			path:        oversized,
+			limits:      audio.DefaultLimits(),
+			wantMessage: "larger than the limit of 100.0 MB",
+		},
+		{
+			name:        "Truncated",
+			path:        truncated,
+			limits:      audio.DefaultLimits(),
+			wantMessage: "the data chunk declares 960862 bytes of audio but only 922 follow",
+		},
+		{
+			name:        "Too long",
+			path:        testdataPath(t, "wav-test.wav"),
+			limits:      audio.Limits{MaxDuration: 5},
+			wantMessage: "longer than the maximum of 5 s. Split it into recordings of at most 5 s",
+		},
+		{
+			name:        "Sample rate",
+			path:        testdataPath(t, "mp3-test.mp3"),
+			limits:      audio.Limits{SampleRates: []int{16000}},
+			wantMessage: "the sample rate of 48000 Hz is not supported",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, handler := Sense(WithAudioLimits(tt.limits))
+			result, err := handler(ctx, newCallToolRequest(map[string]any{"file_absolute_path": tt.path}))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if text := resultText(t, result); !result.IsError || !strings.Contains(text, tt.wantMessage) {
+				t.Errorf("got %q, want a tool error containing %q", text, tt.wantMessage)
+			}
+		})
+	}
+	if sessions := fake.Sessions(); len(sessions) != 0 {
+		t.Errorf("expected no session to be created, got %d", len(sessions))
+	}
+}
+
+func TestSenseMissingClientIsProtocolError(t *testing.T) {
+	_, handler := Sense()
+	args := map[string]any{"file_absolute_path": testdataPath(t, "wav-test.wav")}
+
+	result, err := handler(context.Background(), newCallToolRequest(args))
+	if err == nil {
+		t.Fatalf("expected protocol error, got result %+v", result)
+	}
+}
+
+// setPolling replaces the default polling strategy for the test.
+func setPolling(t *testing.T, strategy poll.Strategy) {
+	orig := defaultPolling
+	defaultPolling = strategy
+	t.Cleanup(func() { defaultPolling = orig })
+}
+
+// setInstantPolling makes result polls wait on a clock that fires at once.
+func setInstantPolling(t *testing.T) *poll.InstantClock {
+	clock := &poll.InstantClock{}
+	strategy := poll.DefaultStrategy()
+	strategy.Clock = clock
+	setPolling(t, strategy)
+	return clock
+}
+
+func newFakeSenseContext(t *testing.T, opts ...fakesense.Option) (context.Context, *fakesense.Server) {
+	t.Helper()
+	fake := fakesense.NewServer(opts...)
+	t.Cleanup(fake.Close)
+
+	c := client.NewCochlSense("test-key", fake.URL, "test")
+	return common.WithCochlSenseClient(context.Background(), c), fake
+}
+
+func TestSenseAnalyzesAudio(t *testing.T) {
+	setInstantPolling(t)
+
+	want := []client.InferenceResult{
+		{StartTime: 0, EndTime: 1000, Tags: []client.Tags{{Name: "Dog_bark", Probability: 0.9}}},
+		{StartTime: 500, EndTime: 1500, Tags: []client.Tags{{Name: "Siren", Probability: 0.7}}},
+	}
+	ctx, fake := newFakeSenseContext(t,
+		fakesense.WithAPIKey("test-key"),
+		fakesense.WithResults(want...),
+		fakesense.WithPendingPolls(2),
+	)
+
+	_, handler := Sense()
+	path := testdataPath(t, "wav-test.wav")
+	result, err := handler(ctx, newCallToolRequest(map[string]any{"file_absolute_path": path}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.IsError {
+		t.Fatalf("unexpected tool error: %s", resultText(t, result))
+	}
+
+	var got []client.InferenceResult
+	if err := json.Unmarshal([]byte(resultText(t, result)), &got); err != nil {
+		t.Fatalf("failed to decode result: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	sessions := fake.Sessions()
+	if len(sessions) != 1 {
+		t.Fatalf("expected 1 session, got %d", len(sessions))
+	}
+	s := sessions[0]
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read test file: %v", err)
+	}
+	if s.FileName != "wav-test.wav" || s.ContentType != "audio/wav" || s.TotalSize != len(raw) {
+		t.Errorf("unexpected session parameters: %+v", s)
+	}
+	if !bytes.Equal(s.Data(), raw) {
+		t.Error("uploaded data does not match file contents")
+	}
+	if s.Polls != 3 {
+		t.Errorf("expected 3 polls, got %d", s.Polls)
+	}
+	if !s.Deleted {
+		t.Error("expected session to be deleted")
+	}
+}
+
+func TestSensePollingSchedule(t *testing.T) {
+	clock := setInstantPolling(t)
+	ctx, _ := newFakeSenseContext(t,
+		fakesense.WithPendingPolls(2),
+		fakesense.WithRetryAfter(3*time.Second),
+	)
+
+	path := testdataPath(t, "wav-test.wav")
+	info, err := audio.GetAudioInfo(path)
+	if err != nil {
+		t.Fatalf("failed to read audio info: %v", err)
+	}
+
+	_, handler := Sense()
+	result, err := handler(ctx, newCallToolRequest(map[string]any{"file_absolute_path": path}))
+	if err != nil || result.IsError {
+		t.Fatalf("analysis failed: %v %+v", err, result)
+	}
+
+	// The first delay scales with the audio; pending responses then ask for
+	// three seconds.
+	first := defaultPolling.NewSchedule(info.Duration).Next(0)
+	want := []time.Duration{first, 3 * time.Second, 3 * time.Second}
+	if got := clock.Waits(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got waits %v, want %v", got, want)
+	}
+}
+
+func TestSensePollingMaxWait(t *testing.T) {
+	strategy := poll.DefaultStrategy()
+	strategy.Clock = &poll.InstantClock{}
+	strategy.MaxWait = time.Minute
+	setPolling(t, strategy)
+	ctx, fake := newFakeSenseContext(t, fakesense.WithPendingPolls(1000))
+
+	_, handler := Sense()
+	result, err := handler(ctx, newCallToolRequest(map[string]any{"file_absolute_path": testdataPath(t, "wav-test.wav")}))
+	if err != nil {
+		t.Fatalf("unexpected protocol error: %v", err)
+	}
+	if !result.IsError || !strings.Contains(resultText(t, result), "did not finish analyzing") {
+		t.Errorf("got %q, want a max wait error", resultText(t, result))
+	}
+	if sessions := fake.Sessions(); len(sessions) != 1 || !sessions[0].Deleted {
+		t.Errorf("expected the abandoned session to be deleted, got %+v", sessions)
+	}
+}
+
+func TestSenseAPIErrorIsToolError(t *testing.T) {
+	setInstantPolling(t)
+
+	tests := []struct {
+		name        string
+		endpoint    fakesense.Endpoint
+		wantMessage string
+	}{
+		{
+			name:        "Create session rejected",
+			endpoint:    fakesense.EndpointCreateSession,
+			wantMessage: "failed to create session",
+		},
+		{
+			name:        "Upload rejected",
+			endpoint:    fakesense.EndpointUploadChunk,
+			wantMessage: "failed to upload audio",
+		},
+		{
+			name:        "Result request rejected",
+			endpoint:    fakesense.EndpointGetResults,
+			wantMessage: "failed to return inference result",
+		},
+	}
+
+	_, handler := Sense()
+	args := map[string]any{"file_absolute_path": testdataPath(t, "wav-test.wav")}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, fake := newFakeSenseContext(t)
+			fake.Script(tt.endpoint, fakesense.Response{
+				Status: http.StatusUnauthorized,
+				Body:   `{"error":"invalid project key"}`,
+			})
+
+			result, err := handler(ctx, newCallToolRequest(args))
+			if err != nil {
+				t.Fatalf("expected tool error result, got protocol error: %v", err)
+			}
+			if !result.IsError {
+				t.Fatal("expected IsError to be set")
+			}
+			text := resultText(t, result)
+			if !strings.Contains(text, tt.wantMessage) || !strings.Contains(text, "invalid project key") {
+				t.Errorf("got message %q, want it to contain %q and the API error", text, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestSenseCache(t *testing.T) {
+	setInstantPolling(t)
+
+	c, err := cache.New()
+	if err != nil {
+		t.Fatalf("failed to create cache: %v", err)
+	}
+	ctx, fake := newFakeSenseContext(t, fakesense.WithResults(
+		client.InferenceResult{StartTime: 0, EndTime: 1000, Tags: []client.Tags{{Name: "Speech", Probability: 0.8}}},
+	))
+
+	_, handler := Sense(WithCache(c))
+	path := testdataPath(t, "wav-test.wav")
+
+	call := func(args map[string]any) *mcp.CallToolResult {
+		t.Helper()
+		result, err := handler(ctx, newCallToolRequest(args))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result.IsError {
+			t.Fatalf("unexpected tool error: %s", resultText(t, result))
+		}
+		return result
+	}
+
+	first := call(map[string]any{"file_absolute_path": path})
+	if len(first.Content) != 1 {
+		t.Errorf("expected first call not to report a cache hit, got %+v", first.Content)
+	}
+
+	second := call(map[string]any{"file_absolute_path": path})
+	if resultText(t, second) != resultText(t, first) {
+		t.Errorf("expected cached result %q, got %q", resultText(t, first), resultText(t, second))
+	}
+	if len(second.Content) != 2 || !strings.Contains(second.Content[1].(mcp.TextContent).Text, "Cache hit") {
+		t.Errorf("expected second call to report a cache hit, got %+v", second.Content)
+	}
+	if n := len(fake.Sessions()); n != 1 {
+		t.Errorf("expected cached call not to create a session, got %d sessions", n)
+	}
+
+	third := call(map[string]any{"file_absolute_path": path, "bypass_cache": true})
+	if len(third.Content) != 1 {
+		t.Errorf("expected bypass_cache call not to report a cache hit, got %+v", third.Content)
+	}
+	if n := len(fake.Sessions()); n != 2 {
+		t.Errorf("expected bypass_cache to create a new session, got %d sessions", n)
+	}
+
+	// Another project key does not share the cached results.
+	ctx = common.WithCochlSenseClient(context.Background(), client.NewCochlSense("other-key", fake.URL, "test"))
+	other := call(map[string]any{"file_absolute_path": path})
+	if len(other.Content) != 1 {
+		t.Errorf("expected a call with another key not to report a cache hit, got %+v", other.Content)
+	}
+	if n := len(fake.Sessions()); n != 3 {
+		t.Errorf("expected a call with another key to create a new session, got %d sessions", n)
+	}
+}
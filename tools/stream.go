@@ -0,0 +1,204 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+
+	"github.com/cochlearai/cochl-mcp-server/client"
+	"github.com/cochlearai/cochl-mcp-server/metrics"
+	"github.com/cochlearai/cochl-mcp-server/stream"
+	"github.com/cochlearai/cochl-mcp-server/usage"
+	"github.com/cochlearai/cochl-mcp-server/util"
+	"github.com/cochlearai/cochl-mcp-server/util/audio"
+)
+
+func AnalyzeStream(opts ...SenseOption) (tool mcp.Tool, handler server.ToolHandlerFunc) {
+	cfg := newSenseConfig(opts)
+
+	tool = mcp.NewTool("analyze_stream",
+		mcp.WithDescription(
+			"Analyze live audio read from a named pipe (FIFO) or any file that is still being written. "+
+				"The audio is uploaded to a Cochl Sense stream session as it arrives, detections are sent "+
+				"as log notifications while the stream runs, and the call returns every detection with "+
+				"its latency once the source ends. The stream stops at the server's limits on the "+
+				"duration and size of audio.",
+		),
+		mcp.WithString(
+			"source_absolute_path",
+			mcp.Required(),
+			mcp.Description(
+				"Absolute path to the pipe or file to read the audio from.\n"+
+					"Avoid using URL-encoded characters.",
+			),
+		),
+		mcp.WithString(
+			"format",
+			mcp.Enum("wav", "pcm"),
+			mcp.Description(
+				"Container of the audio: \"wav\" (16-bit PCM WAV, the default) or \"pcm\" "+
+					"(raw 16-bit little-endian samples described by sample_rate and channels).",
+			),
+		),
+		mcp.WithNumber(
+			"sample_rate",
+			mcp.Description("Sample rate of raw PCM audio in Hz. Defaults to 22050."),
+		),
+		mcp.WithNumber(
+			"channels",
+			mcp.Description("Number of channels of raw PCM audio. Defaults to 1."),
+		),
+		mcp.WithNumber(
+			"max_duration_seconds",
+			mcp.Description("Stop after this many seconds of audio. By default the stream runs until the source ends."),
+		),
+	)
+
+	handler = func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		sourcePath, err := requiredString(request, "source_absolute_path")
+		if err != nil {
+			return nil, err
+		}
+
+		container, err := optionalString(request, "format")
+		if err != nil {
+			return nil, err
+		}
+		if container == "" {
+			container = "wav"
+		}
+
+		var format stream.Format
+		if format.SampleRate, err = optionalInt(request, "sample_rate", 22050); err != nil {
+			return nil, err
+		}
+		if format.Channels, err = optionalInt(request, "channels", 1); err != nil {
+			return nil, err
+		}
+
+		maxSeconds, err := optionalInt(request, "max_duration_seconds", 0)
+		if err != nil {
+			return nil, err
+		}
+		if maxSeconds < 0 {
+			return nil, newToolError(nil, "argument %q must not be negative", "max_duration_seconds")
+		}
+
+		r, err := cfg.analyzeStream(ctx, sourcePath, container, format, time.Duration(maxSeconds)*time.Second)
+		if err != nil {
+			return nil, err
+		}
+
+		jsonResult, err := json.Marshal(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal stream result: %v", err)
+		}
+
+		result := mcp.NewToolResultText(string(jsonResult))
+		result.Content = append(result.Content, mcp.NewTextContent(fmt.Sprintf(
+			"Streamed %.1f s of audio in %d chunks and received %d detections, "+
+				"with a mean latency of %d ms (p95 %d ms, max %d ms).",
+			r.Stats.AudioSeconds, r.Stats.Chunks, r.Stats.Detections,
+			r.Stats.Latency.Mean, r.Stats.Latency.P95, r.Stats.Latency.Max)))
+		return result, nil
+	}
+
+	return tool, withToolErrors(withProgress(handler))
+}
+
+// analyzeStream streams the audio at sourcePath to a new Cochl Sense stream
+// session, reporting detections to the caller as they arrive. Opening a pipe
+// waits for its writer.
+func (cfg *senseConfig) analyzeStream(ctx context.Context, sourcePath, container string, format stream.Format, maxDuration time.Duration) (*stream.Result, error) {
+	c, err := cochlSenseClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.inflight != nil {
+		var done func()
+		if ctx, done, err = cfg.inflight.start(ctx); err != nil {
+			return nil, err
+		}
+		defer done()
+	}
+
+	sourcePath, err = util.NormalizePath(sourcePath)
+	if err != nil {
+		return nil, newToolError(err, "invalid source path. Provide an absolute path without URL-encoded characters")
+	}
+
+	f, err := os.Open(sourcePath)
+	if err != nil {
+		return nil, newToolError(err, "failed to open audio source %s", sourcePath)
+	}
+	defer f.Close()
+
+	src, err := stream.NewSource(f, container, format)
+	if err != nil {
+		return nil, newToolError(err, "unsupported audio source")
+	}
+	if err := cfg.limits.CheckFormat(src.Format.SampleRate, src.Format.Channels); err != nil {
+		return nil, newToolError(nil, "cannot analyze %s: %v", sourcePath, err)
+	}
+	maxDuration = streamLimit(maxDuration, cfg.limits, src.Format)
+
+	session, err := c.CreateStreamSession(ctx, src.Format.ContentType())
+	if errors.Is(err, usage.ErrLimitExceeded) {
+		return nil, newToolError(nil, "%v", err)
+	}
+	if err != nil {
+		return nil, newToolError(err, "Cochl Sense API failed to create stream session")
+	}
+
+	if cfg.inflight != nil {
+		cfg.inflight.trackSession(session.SessionID, c)
+	}
+	defer cfg.deleteSession(ctx, c, session.SessionID)
+	metrics.ActiveSessions.Inc()
+	defer metrics.ActiveSessions.Dec()
+
+	var segments []client.InferenceResult
+	result, err := stream.Run(ctx, c, session, src, stream.Options{
+		MaxDuration:  maxDuration,
+		PollInterval: cfg.polling.InitialDelay,
+		Clock:        cfg.polling.Clock,
+		OnDetections: func(detections []stream.Detection) {
+			for _, d := range detections {
+				segments = append(segments, d.InferenceResult)
+			}
+			reportPartialResults(ctx, sourcePath, 0, segments, segments[len(segments)-len(detections):])
+		},
+	})
+	metrics.UploadBytes.Add(float64(result.Stats.Bytes))
+	if err != nil {
+		return nil, sessionError(ctx, err, "streaming analysis failed")
+	}
+	return result, nil
+}
+
+// streamLimit returns the shortest of maxDuration and the durations allowed
+// by the duration and size limits, since neither is known when a stream
+// starts. Zero means no limit.
+func streamLimit(maxDuration time.Duration, limits audio.Limits, format stream.Format) time.Duration {
+	var caps []time.Duration
+	if limits.MaxDuration > 0 {
+		caps = append(caps, time.Duration(limits.MaxDuration*float64(time.Second)))
+	}
+	if limits.MaxSize > 0 {
+		bytesPerSecond := float64(format.SampleRate * format.Channels * 2)
+		caps = append(caps, time.Duration(float64(limits.MaxSize)/bytesPerSecond*float64(time.Second)))
+	}
+	for _, c := range caps {
+		if maxDuration == 0 || c < maxDuration {
+			maxDuration = c
+		}
+	}
+	return maxDuration
+}
@@ -0,0 +1,136 @@
+package tools
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/cochlearai/cochl-mcp-server/client"
+	"github.com/cochlearai/cochl-mcp-server/client/fakesense"
+	"github.com/cochlearai/cochl-mcp-server/stream"
+	"github.com/cochlearai/cochl-mcp-server/util/audio"
+)
+
+func TestAnalyzeStream(t *testing.T) {
+	setInstantPolling(t)
+	want := []client.InferenceResult{
+		{StartTime: 0, EndTime: 1000, Tags: []client.Tags{{Name: "Speech", Probability: 0.9}}},
+		{StartTime: 500, EndTime: 1500, Tags: []client.Tags{{Name: "Dog_bark", Probability: 0.8}}},
+	}
+	ctx, fake := newFakeSenseContext(t, fakesense.WithResults(want...))
+
+	_, handler := AnalyzeStream()
+	result, err := handler(ctx, newCallToolRequest(map[string]any{
+		"source_absolute_path": testdataPath(t, "wav-test.wav"),
+		"max_duration_seconds": float64(3),
+	}))
+	if err != nil || result.IsError {
+		t.Fatalf("analysis failed: %v %+v", err, result)
+	}
+
+	var got stream.Result
+	if err := json.Unmarshal([]byte(resultText(t, result)), &got); err != nil {
+		t.Fatalf("failed to decode result: %v", err)
+	}
+	if len(got.Detections) != len(want) || got.Detections[1].InferenceResult.Tags[0].Name != "Dog_bark" {
+		t.Errorf("got detections %+v, want %+v", got.Detections, want)
+	}
+	if got.Stats.AudioSeconds != 3 || got.Stats.Chunks != 3 {
+		t.Errorf("got stats %+v, want 3 s in 3 chunks", got.Stats)
+	}
+
+	sessions := fake.Sessions()
+	if len(sessions) != 1 || sessions[0].Type != "stream" || !sessions[0].ReadOnly || !sessions[0].Deleted {
+		t.Errorf("expected one closed and deleted stream session, got %+v", sessions)
+	}
+	if sessions[0].ContentType != "audio/x-raw; rate=48000; format=s16le; channels=1" {
+		t.Errorf("got content type %q", sessions[0].ContentType)
+	}
+}
+
+func TestAnalyzeStreamLimits(t *testing.T) {
+	setInstantPolling(t)
+	ctx, fake := newFakeSenseContext(t)
+
+	// wav-test.wav holds 48 kHz mono audio, 96000 bytes per second.
+	for _, tt := range []struct {
+		name        string
+		limits      audio.Limits
+		wantSeconds float64
+	}{
+		{name: "Duration", limits: audio.Limits{MaxDuration: 2}, wantSeconds: 2},
+		{name: "Size", limits: audio.Limits{MaxSize: 96000}, wantSeconds: 1},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, handler := AnalyzeStream(WithAudioLimits(tt.limits))
+			result, err := handler(ctx, newCallToolRequest(map[string]any{
+				"source_absolute_path": testdataPath(t, "wav-test.wav"),
+				"max_duration_seconds": float64(5),
+			}))
+			if err != nil || result.IsError {
+				t.Fatalf("analysis failed: %v %+v", err, result)
+			}
+			var got stream.Result
+			if err := json.Unmarshal([]byte(resultText(t, result)), &got); err != nil {
+				t.Fatalf("failed to decode result: %v", err)
+			}
+			if got.Stats.AudioSeconds != tt.wantSeconds {
+				t.Errorf("got %v s of audio, want the stream stopped at %v s", got.Stats.AudioSeconds, tt.wantSeconds)
+			}
+		})
+	}
+
+	_, handler := AnalyzeStream(WithAudioLimits(audio.Limits{SampleRates: []int{16000}}))
+	result, err := handler(ctx, newCallToolRequest(map[string]any{"source_absolute_path": testdataPath(t, "wav-test.wav")}))
+	if err != nil {
+		t.Fatalf("unexpected protocol error: %v", err)
+	}
+	if !result.IsError || !strings.Contains(resultText(t, result), "the sample rate of 48000 Hz is not supported") {
+		t.Errorf("got %q, want a sample rate error", resultText(t, result))
+	}
+	if n := len(fake.Sessions()); n != 2 {
+		t.Errorf("expected no session for the rejected stream, got %d sessions", n)
+	}
+}
+
+func TestAnalyzeStreamErrors(t *testing.T) {
+	setInstantPolling(t)
+	ctx, fake := newFakeSenseContext(t)
+
+	tests := []struct {
+		name    string
+		args    map[string]any
+		wantErr string
+	}{
+		{
+			name:    "Missing source",
+			args:    map[string]any{"source_absolute_path": testdataPath(t, "missing.wav")},
+			wantErr: "failed to open audio source",
+		},
+		{
+			name:    "Not a WAV stream",
+			args:    map[string]any{"source_absolute_path": testdataPath(t, "mp3-test.mp3")},
+			wantErr: "unsupported audio source",
+		},
+		{
+			name:    "Negative duration",
+			args:    map[string]any{"source_absolute_path": testdataPath(t, "wav-test.wav"), "max_duration_seconds": float64(-1)},
+			wantErr: "must not be negative",
+		},
+	}
+	_, handler := AnalyzeStream()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := handler(ctx, newCallToolRequest(tt.args))
+			if err != nil {
+				t.Fatalf("unexpected protocol error: %v", err)
+			}
+			if !result.IsError || !strings.Contains(resultText(t, result), tt.wantErr) {
+				t.Errorf("got %q, want an error containing %q", resultText(t, result), tt.wantErr)
+			}
+		})
+	}
+	if n := len(fake.Sessions()); n != 0 {
+		t.Errorf("expected no session, got %d", n)
+	}
+}
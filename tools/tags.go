@@ -0,0 +1,125 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+
+	"github.com/cochlearai/cochl-mcp-server/taxonomy"
+)
+
+func ListSoundTags() (tool mcp.Tool, handler server.ToolHandlerFunc) {
+	t := taxonomy.Default()
+
+	tool = mcp.NewTool("list_sound_tags",
+		mcp.WithDescription(
+			"List the sound tags Cochl Sense can detect, such as Dog_bark or Siren, with their "+
+				"category and a short description. Use the exact tag names when reasoning about "+
+				"analysis results.",
+		),
+		mcp.WithString(
+			"category",
+			mcp.Description(
+				"Only list tags in this category.",
+			),
+			mcp.Enum(categoryNames(t)...),
+		),
+	)
+
+	handler = func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		category, err := optionalString(request, "category")
+		if err != nil {
+			return nil, err
+		}
+
+		tags := t.Tags()
+		if category != "" {
+			c, ok := t.Category(category)
+			if !ok {
+				return nil, newToolError(nil, "unknown category %q. Known categories are: %s",
+					category, strings.Join(categoryNames(t), ", "))
+			}
+			tags = c.Tags
+		}
+
+		jsonResult, err := json.Marshal(map[string]any{
+			"version": t.Version,
+			"tags":    tags,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal tags: %v", err)
+		}
+		return mcp.NewToolResultText(string(jsonResult)), nil
+	}
+
+	return tool, withToolErrors(handler)
+}
+
+func SearchSoundTags() (tool mcp.Tool, handler server.ToolHandlerFunc) {
+	t := taxonomy.Default()
+
+	tool = mcp.NewTool("search_sound_tags",
+		mcp.WithDescription(
+			"Find Cochl Sense sound tags matching a free-text query, such as \"dog\", \"alarm\" or "+
+				"\"glass breaking\". Matches tag names, descriptions and categories, tolerates typos, "+
+				"and returns the best matches first with a score from 0 to 1.",
+		),
+		mcp.WithString(
+			"query",
+			mcp.Required(),
+			mcp.Description(
+				"Words describing the sound to look for.",
+			),
+		),
+		mcp.WithNumber(
+			"limit",
+			mcp.Description(
+				"Maximum number of matches to return. Defaults to 10.",
+			),
+		),
+	)
+
+	handler = func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		query, err := requiredString(request, "query")
+		if err != nil {
+			return nil, err
+		}
+
+		limit, err := optionalInt(request, "limit", 10)
+		if err != nil {
+			return nil, err
+		}
+		if limit <= 0 {
+			return nil, newToolError(nil, "argument \"limit\" must be greater than zero")
+		}
+
+		matches := t.Search(query, limit)
+		if len(matches) == 0 {
+			return mcp.NewToolResultText(fmt.Sprintf(
+				"No sound tags match %q. Use list_sound_tags to see every tag.", query)), nil
+		}
+
+		jsonResult, err := json.Marshal(map[string]any{
+			"version": t.Version,
+			"matches": matches,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal matches: %v", err)
+		}
+		return mcp.NewToolResultText(string(jsonResult)), nil
+	}
+
+	return tool, withToolErrors(handler)
+}
+
+func categoryNames(t *taxonomy.Taxonomy) []string {
+	names := make([]string, 0, len(t.Categories))
+	for _, c := range t.Categories {
+		names = append(names, c.Name)
+	}
+	return names
+}
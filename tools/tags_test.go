@@ -0,0 +1,70 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/cochlearai/cochl-mcp-server/taxonomy"
+)
+
+func TestListSoundTags(t *testing.T) {
+	_, handler := ListSoundTags()
+
+	result, err := handler(context.Background(), newCallToolRequest(map[string]any{"category": "animal"}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var got struct {
+		Version string         `json:"version"`
+		Tags    []taxonomy.Tag `json:"tags"`
+	}
+	if err := json.Unmarshal([]byte(resultText(t, result)), &got); err != nil {
+		t.Fatalf("failed to decode result: %v", err)
+	}
+	if got.Version != taxonomy.Default().Version || len(got.Tags) == 0 {
+		t.Fatalf("unexpected result: %+v", got)
+	}
+	for _, tag := range got.Tags {
+		if tag.Category != "Animal" {
+			t.Errorf("expected only Animal tags, got %+v", tag)
+		}
+	}
+
+	result, err = handler(context.Background(), newCallToolRequest(map[string]any{"category": "Plants"}))
+	if err != nil {
+		t.Fatalf("expected tool error result, got protocol error: %v", err)
+	}
+	if text := resultText(t, result); !result.IsError || !strings.Contains(text, "Known categories are") {
+		t.Errorf("expected unknown category error, got %q", text)
+	}
+}
+
+func TestSearchSoundTags(t *testing.T) {
+	_, handler := SearchSoundTags()
+
+	result, err := handler(context.Background(), newCallToolRequest(map[string]any{"query": "siern", "limit": float64(1)}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var got struct {
+		Matches []taxonomy.Match `json:"matches"`
+	}
+	if err := json.Unmarshal([]byte(resultText(t, result)), &got); err != nil {
+		t.Fatalf("failed to decode result: %v", err)
+	}
+	if len(got.Matches) != 1 || got.Matches[0].Name != "Siren" {
+		t.Errorf("unexpected matches: %+v", got.Matches)
+	}
+
+	result, err = handler(context.Background(), newCallToolRequest(map[string]any{"query": "xyzzy"}))
+	if err != nil || result.IsError || !strings.Contains(resultText(t, result), "No sound tags match") {
+		t.Errorf("expected no match message, got %+v, %v", result, err)
+	}
+
+	result, err = handler(context.Background(), newCallToolRequest(map[string]any{"query": "dog", "limit": 1.5}))
+	if err != nil || !result.IsError {
+		t.Errorf("expected tool error for fractional limit, got %+v, %v", result, err)
+	}
+}
@@ -0,0 +1,44 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+
+	"github.com/cochlearai/cochl-mcp-server/usage"
+)
+
+// GetUsage returns the tool "get_usage", which reports the audio submitted
+// with the project key of the session.
+func GetUsage() (tool mcp.Tool, handler server.ToolHandlerFunc) {
+	tool = mcp.NewTool("get_usage",
+		mcp.WithDescription(
+			"Report how much audio has been submitted to Cochl Sense with the project key of this "+
+				"session: sessions, seconds and bytes today, this month (UTC) and in total, broken down "+
+				"by client, with the daily and monthly limits of the server and what they still allow. "+
+				"Use it before analyzing many or long recordings.",
+		),
+	)
+
+	handler = func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		c, err := cochlSenseClient(ctx)
+		if err != nil {
+			return nil, err
+		}
+		report, ok := usage.ReportFor(c)
+		if !ok {
+			return nil, newToolError(nil, "usage is not tracked for this session: the server runs in mock mode or has no project key")
+		}
+
+		jsonResult, err := json.Marshal(report)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal usage: %v", err)
+		}
+		return mcp.NewToolResultText(string(jsonResult)), nil
+	}
+
+	return tool, withToolErrors(handler)
+}
@@ -0,0 +1,61 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/cochlearai/cochl-mcp-server/client"
+	"github.com/cochlearai/cochl-mcp-server/client/fakesense"
+	"github.com/cochlearai/cochl-mcp-server/common"
+	"github.com/cochlearai/cochl-mcp-server/usage"
+)
+
+func TestUsageLimits(t *testing.T) {
+	setInstantPolling(t)
+	fake := fakesense.NewServer()
+	t.Cleanup(fake.Close)
+	meter, err := usage.Open("", usage.WithLimits(usage.Limits{DailyBytes: 1}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := meter.Client(client.NewCochlSense("test-key", fake.URL, "test"), "test-key", "alice")
+	ctx := common.WithCochlSenseClient(context.Background(), c)
+
+	_, sense := Sense()
+	result, err := sense(ctx, newCallToolRequest(map[string]any{
+		"file_absolute_path": testdataPath(t, "wav-test.wav"),
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.IsError || !strings.Contains(resultText(t, result), "daily limit of 1 bytes") {
+		t.Errorf("expected a limit tool error, got %s", resultText(t, result))
+	}
+	if sessions := fake.Sessions(); len(sessions) != 0 {
+		t.Errorf("expected no session to be created, got %d", len(sessions))
+	}
+
+	_, getUsage := GetUsage()
+	result, err = getUsage(ctx, newCallToolRequest(nil))
+	if err != nil || result.IsError {
+		t.Fatalf("get_usage failed: %v %+v", err, result)
+	}
+	var report usage.Report
+	if err := json.Unmarshal([]byte(resultText(t, result)), &report); err != nil {
+		t.Fatalf("invalid report: %v", err)
+	}
+	if report.Limits.DailyBytes != 1 || report.Total.Sessions != 0 || report.Remaining["daily_bytes"] != 1 {
+		t.Errorf("got report %+v", report)
+	}
+
+	ctx, _ = newFakeSenseContext(t)
+	result, err = getUsage(ctx, newCallToolRequest(nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.IsError || !strings.Contains(resultText(t, result), "not tracked") {
+		t.Errorf("expected an error for an unmetered client, got %s", resultText(t, result))
+	}
+}
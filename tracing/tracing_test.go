@@ -0,0 +1,101 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// restoreGlobals resets the global provider and propagator changed by Setup.
+func restoreGlobals(t *testing.T) {
+	provider, propagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	t.Cleanup(func() {
+		otel.SetTracerProvider(provider)
+		otel.SetTextMapPropagator(propagator)
+	})
+}
+
+func TestFileExporter(t *testing.T) {
+	restoreGlobals(t)
+	file := filepath.Join(t.TempDir(), "traces.jsonl")
+
+	shutdown, err := Setup(context.Background(), Config{Exporter: ExporterFile, File: file, ServiceVersion: "1.2.3"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ctx, parent := Start(context.Background(), "parent")
+	_, child := Start(ctx, "child")
+	EndSpan(child, errors.New("failed"))
+	EndSpan(parent, nil)
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("failed to shut down: %v", err)
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatalf("failed to open trace file: %v", err)
+	}
+	defer f.Close()
+
+	type span struct {
+		Name        string
+		SpanContext struct{ TraceID, SpanID string }
+		Parent      struct{ SpanID string }
+		Status      struct{ Code string }
+	}
+	spans := make(map[string]span)
+	dec := json.NewDecoder(f)
+	for {
+		var s span
+		if err := dec.Decode(&s); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("failed to decode span: %v", err)
+		}
+		spans[s.Name] = s
+	}
+
+	p, c := spans["parent"], spans["child"]
+	if p.SpanContext.TraceID == "" || c.SpanContext.TraceID != p.SpanContext.TraceID || c.Parent.SpanID != p.SpanContext.SpanID {
+		t.Errorf("expected child of parent in one trace, got %+v", spans)
+	}
+	if c.Status.Code != "Error" {
+		t.Errorf("expected error status on child, got %q", c.Status.Code)
+	}
+}
+
+func TestSetupErrors(t *testing.T) {
+	if _, err := Setup(context.Background(), Config{Exporter: "jaeger"}); err == nil {
+		t.Error("expected error for unknown exporter")
+	}
+	if _, err := Setup(context.Background(), Config{Exporter: ExporterFile}); err == nil {
+		t.Error("expected error for file exporter without file")
+	}
+}
+
+func TestExtractHTTP(t *testing.T) {
+	restoreGlobals(t)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	var got trace.SpanContext
+	handler := ExtractHTTP(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = trace.SpanContextFromContext(r.Context())
+	}))
+	req := httptest.NewRequest(http.MethodPost, "/mcp", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if got.TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" || !got.IsRemote() {
+		t.Errorf("expected remote trace context from header, got %+v", got)
+	}
+}
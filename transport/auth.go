@@ -0,0 +1,139 @@
+package transport
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// AuthTokenHeader is an alternative to "Authorization: Bearer" for clients
+// that cannot set the Authorization header.
+const AuthTokenHeader = "X-Mcp-Auth-Token"
+
+// Tokens maps each accepted client token to the name of the client it
+// belongs to.
+type Tokens map[string]string
+
+// LoadTokens reads tokens from a file with one token per line, optionally
+// preceded by a client name: "<name> <token>". Blank lines and lines starting
+// with # are ignored.
+func LoadTokens(path string) (Tokens, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open token file: %w", err)
+	}
+	defer f.Close()
+
+	tokens, err := ParseTokens(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token file %s: %w", path, err)
+	}
+	return tokens, nil
+}
+
+// ParseTokens reads tokens in the format described by LoadTokens.
+func ParseTokens(r io.Reader) (Tokens, error) {
+	tokens := make(Tokens)
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		var name, token string
+		switch len(fields) {
+		case 1:
+			name, token = fmt.Sprintf("client-%d", line), fields[0]
+		case 2:
+			name, token = fields[0], fields[1]
+		default:
+			return nil, fmt.Errorf("line %d: expected \"<token>\" or \"<name> <token>\"", line)
+		}
+		if _, ok := tokens[token]; ok {
+			return nil, fmt.Errorf("line %d: duplicate token", line)
+		}
+		tokens[token] = name
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+type clientNameKey struct{}
+
+// ClientNameFromContext returns the name of the authenticated client that
+// sent the request, if any.
+func ClientNameFromContext(ctx context.Context) (string, bool) {
+	name, ok := ctx.Value(clientNameKey{}).(string)
+	return name, ok
+}
+
+// Authenticate identifies the client of every request before passing it to
+// next. A client is identified by a token from tokens, sent as a bearer token
+// or in the X-Mcp-Auth-Token header, or by a verified TLS client certificate,
+// whose common name becomes the client name. If tokens is empty, no token is
+// required.
+func Authenticate(next http.Handler, tokens Tokens) http.Handler {
+	// Comparing fixed-size digests keeps the comparison constant-time
+	// regardless of token length.
+	digests := make(map[[sha256.Size]byte]string, len(tokens))
+	for token, name := range tokens {
+		digests[sha256.Sum256([]byte(token))] = name
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+			ctx = context.WithValue(ctx, clientNameKey{}, r.TLS.VerifiedChains[0][0].Subject.CommonName)
+		}
+
+		if len(digests) > 0 {
+			token := requestToken(r)
+			if token == "" {
+				unauthorized(w, "missing client token")
+				return
+			}
+
+			got := sha256.Sum256([]byte(token))
+			var name string
+			for digest, n := range digests {
+				if subtle.ConstantTimeCompare(got[:], digest[:]) == 1 {
+					name = n
+				}
+			}
+			if name == "" {
+				slog.Warn("Rejected request with invalid client token", "remote", r.RemoteAddr)
+				unauthorized(w, "invalid client token")
+				return
+			}
+			ctx = context.WithValue(ctx, clientNameKey{}, name)
+		}
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func requestToken(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		scheme, token, ok := strings.Cut(auth, " ")
+		if ok && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token)
+		}
+	}
+	return r.Header.Get(AuthTokenHeader)
+}
+
+func unauthorized(w http.ResponseWriter, msg string) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="cochl-mcp-server"`)
+	http.Error(w, msg, http.StatusUnauthorized)
+}
@@ -0,0 +1,74 @@
+package transport
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTokens(t *testing.T) {
+	input := `
+# clients
+alice s3cret
+b0bt0ken
+`
+	tokens, err := ParseTokens(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := Tokens{"s3cret": "alice", "b0bt0ken": "client-4"}
+	if !reflect.DeepEqual(tokens, want) {
+		t.Errorf("got %v, want %v", tokens, want)
+	}
+
+	if _, err := ParseTokens(strings.NewReader("a b c")); err == nil {
+		t.Error("expected error for malformed line")
+	}
+	if _, err := ParseTokens(strings.NewReader("a tok\nb tok")); err == nil {
+		t.Error("expected error for duplicate token")
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	handler := Authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name, _ := ClientNameFromContext(r.Context())
+		io.WriteString(w, name)
+	}), Tokens{"s3cret": "alice"})
+
+	tests := []struct {
+		name       string
+		header     string
+		value      string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "Bearer token", header: "Authorization", value: "Bearer s3cret", wantStatus: http.StatusOK, wantBody: "alice"},
+		{name: "Token header", header: AuthTokenHeader, value: "s3cret", wantStatus: http.StatusOK, wantBody: "alice"},
+		{name: "Missing token", wantStatus: http.StatusUnauthorized},
+		{name: "Invalid token", header: "Authorization", value: "Bearer wrong", wantStatus: http.StatusUnauthorized},
+		{name: "Other scheme", header: "Authorization", value: "Basic s3cret", wantStatus: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/mcp", nil)
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("got status %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusOK && rec.Body.String() != tt.wantBody {
+				t.Errorf("got body %q, want %q", rec.Body.String(), tt.wantBody)
+			}
+			if tt.wantStatus == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+				t.Error("expected WWW-Authenticate header")
+			}
+		})
+	}
+}
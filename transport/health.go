@@ -0,0 +1,79 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ReadinessCheck returns an error when the server cannot serve requests.
+type ReadinessCheck func(ctx context.Context) error
+
+// readinessTimeout bounds the checks of a single readiness probe.
+const readinessTimeout = 3 * time.Second
+
+// HealthHandler answers liveness probes: it responds as long as the process
+// serves HTTP.
+func HealthHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		io.WriteString(w, "ok\n")
+	})
+}
+
+// ReadyHandler answers readiness probes with 200 if every check passes and
+// 503 listing the failures otherwise.
+func ReadyHandler(checks ...ReadinessCheck) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+		defer cancel()
+
+		var failures []string
+		for _, check := range checks {
+			if err := check(ctx); err != nil {
+				failures = append(failures, err.Error())
+			}
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if len(failures) > 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			io.WriteString(w, strings.Join(failures, "\n")+"\n")
+			return
+		}
+		io.WriteString(w, "ok\n")
+	})
+}
+
+// ShutdownCheck fails once ctx, the context that ends the server, is done, so
+// load balancers stop routing to a server that is draining.
+func ShutdownCheck(ctx context.Context) ReadinessCheck {
+	return func(context.Context) error {
+		if ctx.Err() != nil {
+			return errors.New("server is shutting down")
+		}
+		return nil
+	}
+}
+
+// ReachabilityCheck fails when url does not answer HTTP requests. Any
+// response counts as reachable, since the API rejects unauthenticated
+// requests.
+func ReachabilityCheck(url string) ReadinessCheck {
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+		if err != nil {
+			return fmt.Errorf("invalid URL %s: %w", url, err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return fmt.Errorf("%s is unreachable: %w", url, err)
+		}
+		resp.Body.Close()
+		return nil
+	}
+}
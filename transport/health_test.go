@@ -0,0 +1,53 @@
+package transport
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestReadyHandler(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer api.Close()
+
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	running, stop := context.WithCancel(context.Background())
+	defer stop()
+
+	tests := []struct {
+		name       string
+		checks     []ReadinessCheck
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "No checks", wantStatus: http.StatusOK},
+		{name: "Reachable API", checks: []ReadinessCheck{ReachabilityCheck(api.URL)}, wantStatus: http.StatusOK},
+		{name: "Unreachable API", checks: []ReadinessCheck{ReachabilityCheck(closed.URL)}, wantStatus: http.StatusServiceUnavailable, wantBody: "is unreachable"},
+		{name: "Running", checks: []ReadinessCheck{ShutdownCheck(running)}, wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			ReadyHandler(tt.checks...).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			if rec.Code != tt.wantStatus {
+				t.Errorf("got status %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("got body %q, want it to contain %q", rec.Body.String(), tt.wantBody)
+			}
+		})
+	}
+
+	stop()
+	rec := httptest.NewRecorder()
+	ReadyHandler(ShutdownCheck(running)).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), "shutting down") {
+		t.Errorf("expected shutting down, got %d %q", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,83 @@
+package transport
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// TLSFiles names the PEM files used to serve HTTPS. ClientCAFile is optional;
+// when set, clients must present a certificate signed by one of its CAs.
+type TLSFiles struct {
+	CertFile     string
+	KeyFile      string
+	ClientCAFile string
+}
+
+// Enabled reports whether a certificate was configured.
+func (f TLSFiles) Enabled() bool {
+	return f.CertFile != "" || f.KeyFile != ""
+}
+
+// Config loads the certificate and client CAs into a TLS configuration.
+func (f TLSFiles) Config() (*tls.Config, error) {
+	if f.CertFile == "" || f.KeyFile == "" {
+		return nil, errors.New("both a TLS certificate and key file are required")
+	}
+
+	cert, err := tls.LoadX509KeyPair(f.CertFile, f.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if f.ClientCAFile != "" {
+		pem, err := os.ReadFile(f.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in client CA file %s", f.ClientCAFile)
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return cfg, nil
+}
+
+// NewHTTPServer returns a server for handler on addr, using TLS if files
+// configures a certificate.
+func NewHTTPServer(addr string, handler http.Handler, files TLSFiles) (*http.Server, error) {
+	srv := &http.Server{Addr: addr, Handler: handler}
+	if files.Enabled() || files.ClientCAFile != "" {
+		cfg, err := files.Config()
+		if err != nil {
+			return nil, err
+		}
+		srv.TLSConfig = cfg
+	}
+	return srv, nil
+}
+
+// ListenAndServe serves srv over TLS if it has a TLS configuration and over
+// plain HTTP otherwise. It returns nil once the server is shut down.
+func ListenAndServe(srv *http.Server) error {
+	var err error
+	if srv.TLSConfig != nil {
+		err = srv.ListenAndServeTLS("", "")
+	} else {
+		err = srv.ListenAndServe()
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,174 @@
+package transport
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	tlsCert tls.Certificate
+}
+
+// newTestCert creates a certificate for commonName signed by parent, or a
+// self-signed CA if parent is nil.
+func newTestCert(t *testing.T, commonName string, parent *testCert) *testCert {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+
+	signer, signerKey := tmpl, key
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("failed to parse certificate: %v", err)
+	}
+	return &testCert{
+		cert:    cert,
+		key:     key,
+		tlsCert: tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key},
+	}
+}
+
+// writePEM writes the certificate and key of c to dir and returns their
+// paths.
+func (c *testCert) writePEM(t *testing.T, dir, name string) (certFile, keyFile string) {
+	t.Helper()
+
+	keyDER, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+	certFile = filepath.Join(dir, name+".crt")
+	keyFile = filepath.Join(dir, name+".key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.cert.Raw}), 0600); err != nil {
+		t.Fatalf("failed to write certificate: %v", err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatalf("failed to write key: %v", err)
+	}
+	return certFile, keyFile
+}
+
+// startTLSServer serves a handler that echoes the authenticated client name,
+// using the TLS configuration loaded from files.
+func startTLSServer(t *testing.T, files TLSFiles) *httptest.Server {
+	t.Helper()
+
+	cfg, err := files.Config()
+	if err != nil {
+		t.Fatalf("failed to load TLS config: %v", err)
+	}
+	ts := httptest.NewUnstartedServer(Authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name, _ := ClientNameFromContext(r.Context())
+		io.WriteString(w, name)
+	}), nil))
+	ts.TLS = cfg
+	ts.StartTLS()
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func newTLSClient(ca *testCert, clientCert *testCert) *http.Client {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	cfg := &tls.Config{RootCAs: pool}
+	if clientCert != nil {
+		cfg.Certificates = []tls.Certificate{clientCert.tlsCert}
+	}
+	return &http.Client{Transport: &http.Transport{TLSClientConfig: cfg}}
+}
+
+func TestTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, "test-ca", nil)
+	certFile, keyFile := newTestCert(t, "server", ca).writePEM(t, dir, "server")
+
+	ts := startTLSServer(t, TLSFiles{CertFile: certFile, KeyFile: keyFile})
+
+	resp, err := newTLSClient(ca, nil).Get(ts.URL)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	if _, err := http.Get(ts.URL); err == nil {
+		t.Error("expected client without the CA to reject the certificate")
+	}
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, "test-ca", nil)
+	certFile, keyFile := newTestCert(t, "server", ca).writePEM(t, dir, "server")
+	caFile, _ := ca.writePEM(t, dir, "ca")
+
+	ts := startTLSServer(t, TLSFiles{CertFile: certFile, KeyFile: keyFile, ClientCAFile: caFile})
+
+	resp, err := newTLSClient(ca, newTestCert(t, "alice", ca)).Get(ts.URL)
+	if err != nil {
+		t.Fatalf("request with client certificate failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if name, _ := io.ReadAll(resp.Body); string(name) != "alice" {
+		t.Errorf("expected client name from certificate, got %q", name)
+	}
+
+	if _, err := newTLSClient(ca, nil).Get(ts.URL); err == nil {
+		t.Error("expected request without client certificate to fail")
+	}
+
+	other := newTestCert(t, "other-ca", nil)
+	if _, err := newTLSClient(ca, newTestCert(t, "mallory", other)).Get(ts.URL); err == nil {
+		t.Error("expected request with untrusted client certificate to fail")
+	}
+}
+
+func TestTLSFilesConfigErrors(t *testing.T) {
+	if _, err := (TLSFiles{CertFile: "server.crt"}).Config(); err == nil {
+		t.Error("expected error without key file")
+	}
+	if _, err := (TLSFiles{CertFile: "missing.crt", KeyFile: "missing.key"}).Config(); err == nil {
+		t.Error("expected error for missing files")
+	}
+}
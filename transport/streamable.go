@@ -0,0 +1,809 @@
+// Package transport serves an MCP server over the Streamable HTTP transport,
+// which mcp-go does not provide yet. A single endpoint accepts JSON-RPC
+// messages with POST, streams server-initiated messages to GET requests as
+// server-sent events and ends sessions on DELETE.
+//
+// Every event on a GET stream carries an ID. A client that reconnects with
+// the Last-Event-ID header receives the events it missed, as long as they are
+// still in the session's buffer.
+//
+// Notifications sent while a POST request is handled, such as progress, are
+// sent on the response to that request, which becomes an event stream, if
+// the client accepts one. Otherwise they go to the GET stream.
+//
+// A session lasts until the client deletes it, the server shuts down, or it
+// has been idle, with no request being handled and no open GET stream, for
+// the idle timeout. Idle sessions are swept periodically and whenever the session
+// limit is reached; an initialize request is refused while the server is
+// still at the limit after the sweep.
+package transport
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const (
+	// SessionIDHeader carries the session ID assigned in the initialize
+	// response. Clients send it with every later request.
+	SessionIDHeader = "Mcp-Session-Id"
+
+	lastEventIDHeader = "Last-Event-ID"
+
+	defaultEndpoint    = "/mcp"
+	defaultBufferSize  = 256
+	defaultIdleTimeout = 30 * time.Minute
+	defaultMaxSessions = 1000
+	// defaultMaxBodySize leaves ample room for JSON-RPC messages, which
+	// refer to audio files by path instead of carrying their content.
+	defaultMaxBodySize = 4 << 20
+)
+
+var errTooManySessions = errors.New("too many sessions")
+
+// HTTPContextFunc adds request-scoped values, such as a Cochl Sense client
+// built from the request headers, to the context of each POST request.
+type HTTPContextFunc func(ctx context.Context, r *http.Request) context.Context
+
+type StreamableHTTPOption func(*StreamableHTTPServer)
+
+// WithEndpoint sets the path of the MCP endpoint. The default is /mcp.
+func WithEndpoint(path string) StreamableHTTPOption {
+	return func(s *StreamableHTTPServer) {
+		s.endpoint = path
+	}
+}
+
+// WithHTTPContextFunc sets the function applied to the context of every POST
+// request before its messages are handled. It accepts the same functions as
+// server.WithSSEContextFunc.
+func WithHTTPContextFunc(fn func(ctx context.Context, r *http.Request) context.Context) StreamableHTTPOption {
+	return func(s *StreamableHTTPServer) {
+		s.contextFunc = fn
+	}
+}
+
+// WithEventBufferSize sets how many server-initiated messages each session
+// keeps for clients that reconnect with Last-Event-ID.
+func WithEventBufferSize(n int) StreamableHTTPOption {
+	return func(s *StreamableHTTPServer) {
+		s.bufferSize = n
+	}
+}
+
+// WithAllowedOrigins lists the origins, besides the server's own host, that
+// browsers may send requests from. Requests without an Origin header are
+// always allowed.
+func WithAllowedOrigins(origins ...string) StreamableHTTPOption {
+	return func(s *StreamableHTTPServer) {
+		s.allowedOrigins = append(s.allowedOrigins, origins...)
+	}
+}
+
+// WithSessionOwner sets the function identifying the caller of a request
+// from its context, after the HTTP context function was applied. A session
+// only accepts requests from the caller that created it.
+func WithSessionOwner(fn func(ctx context.Context) string) StreamableHTTPOption {
+	return func(s *StreamableHTTPServer) {
+		s.owner = fn
+	}
+}
+
+// WithSessionIdleTimeout sets how long a session is kept without requests
+// or an open GET stream. The default is 30 minutes; 0 keeps idle sessions
+// until they are deleted.
+func WithSessionIdleTimeout(d time.Duration) StreamableHTTPOption {
+	return func(s *StreamableHTTPServer) {
+		s.idleTimeout = d
+	}
+}
+
+// WithMaxSessions sets how many sessions may exist at once. The default is
+// 1000; 0 means no limit.
+func WithMaxSessions(n int) StreamableHTTPOption {
+	return func(s *StreamableHTTPServer) {
+		s.maxSessions = n
+	}
+}
+
+// WithMaxBodySize sets the largest POST body accepted, in bytes. The default
+// is 4 MiB.
+func WithMaxBodySize(n int64) StreamableHTTPOption {
+	return func(s *StreamableHTTPServer) {
+		s.maxBodySize = n
+	}
+}
+
+type StreamableHTTPServer struct {
+	server         *server.MCPServer
+	endpoint       string
+	contextFunc    HTTPContextFunc
+	owner          func(ctx context.Context) string
+	bufferSize     int
+	allowedOrigins []string
+	idleTimeout    time.Duration
+	maxSessions    int
+	maxBodySize    int64
+	now            func() time.Time
+
+	mu         sync.Mutex
+	sessions   map[string]*streamableSession
+	httpServer *http.Server
+
+	sweepOnce sync.Once
+	sweepCtx  context.Context
+	stopSweep context.CancelFunc
+}
+
+func NewStreamableHTTPServer(s *server.MCPServer, opts ...StreamableHTTPOption) *StreamableHTTPServer {
+	h := &StreamableHTTPServer{
+		server:      s,
+		endpoint:    defaultEndpoint,
+		bufferSize:  defaultBufferSize,
+		idleTimeout: defaultIdleTimeout,
+		maxSessions: defaultMaxSessions,
+		maxBodySize: defaultMaxBodySize,
+		now:         time.Now,
+		sessions:    make(map[string]*streamableSession),
+	}
+	h.sweepCtx, h.stopSweep = context.WithCancel(context.Background())
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// Start listens on addr and serves the MCP endpoint until Shutdown is called.
+func (h *StreamableHTTPServer) Start(addr string) error {
+	h.mu.Lock()
+	h.httpServer = &http.Server{Addr: addr, Handler: h}
+	srv := h.httpServer
+	h.mu.Unlock()
+
+	return ListenAndServe(srv)
+}
+
+// Shutdown ends every session and stops the HTTP server started by Start.
+func (h *StreamableHTTPServer) Shutdown(ctx context.Context) error {
+	h.stopSweep()
+
+	h.mu.Lock()
+	sessions := h.sessions
+	h.sessions = make(map[string]*streamableSession)
+	srv := h.httpServer
+	h.mu.Unlock()
+
+	for _, session := range sessions {
+		h.closeSession(session)
+	}
+	if srv != nil {
+		return srv.Shutdown(ctx)
+	}
+	return nil
+}
+
+func (h *StreamableHTTPServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != h.endpoint {
+		http.NotFound(w, r)
+		return
+	}
+	if !h.originAllowed(r) {
+		http.Error(w, "Origin not allowed", http.StatusForbidden)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		h.handlePost(w, r)
+	case http.MethodGet:
+		h.handleGet(w, r)
+	case http.MethodDelete:
+		h.handleDelete(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// originAllowed guards against DNS rebinding: browsers may only reach the
+// endpoint from the server's own host or an explicitly allowed origin.
+func (h *StreamableHTTPServer) originAllowed(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	for _, allowed := range h.allowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+func (h *StreamableHTTPServer) handlePost(w http.ResponseWriter, r *http.Request) {
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+		http.Error(w, "Content-Type must be application/json", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, h.maxBodySize)
+	var body json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeJSONRPCError(w, http.StatusRequestEntityTooLarge, mcp.INVALID_REQUEST,
+				fmt.Sprintf("Request body is larger than %d bytes", tooLarge.Limit))
+			return
+		}
+		writeJSONRPCError(w, http.StatusBadRequest, mcp.PARSE_ERROR, "Parse error")
+		return
+	}
+
+	messages, batch, err := splitBatch(body)
+	if err != nil {
+		writeJSONRPCError(w, http.StatusBadRequest, mcp.INVALID_REQUEST, err.Error())
+		return
+	}
+
+	ctx := h.requestContext(r)
+	var session *streamableSession
+	if containsInitialize(messages) {
+		if len(messages) > 1 {
+			writeJSONRPCError(w, http.StatusBadRequest, mcp.INVALID_REQUEST, "initialize must not be sent in a batch")
+			return
+		}
+		if session, err = h.newSession(h.ownerOf(ctx)); errors.Is(err, errTooManySessions) {
+			slog.Warn("Refusing new session, the session limit is reached", "max_sessions", h.maxSessions)
+			writeJSONRPCError(w, http.StatusServiceUnavailable, mcp.INTERNAL_ERROR, "Too many sessions, try again later")
+			return
+		} else if err != nil {
+			slog.Error("Failed to create session", "error", err)
+			writeJSONRPCError(w, http.StatusInternalServerError, mcp.INTERNAL_ERROR, "Failed to create session")
+			return
+		}
+		w.Header().Set(SessionIDHeader, session.id)
+	} else if session = h.sessionFor(w, r, ctx); session == nil {
+		return
+	}
+
+	session.open.Add(1)
+	defer func() {
+		session.open.Add(-1)
+		session.touch(h.now())
+	}()
+
+	request := &requestSession{
+		streamableSession: session,
+		notifications:     make(chan mcp.JSONRPCNotification, 100),
+	}
+	ctx = h.server.WithContext(ctx, request)
+
+	done := make(chan []mcp.JSONRPCMessage, 1)
+	go func() {
+		var responses []mcp.JSONRPCMessage
+		for _, msg := range messages {
+			if resp := h.server.HandleMessage(ctx, msg); resp != nil {
+				responses = append(responses, resp)
+			}
+		}
+		done <- responses
+	}()
+
+	events := newEventWriter(w, acceptsEventStream(r))
+	for {
+		select {
+		case n := <-request.notifications:
+			if !events.write(n) {
+				session.forward(n)
+			}
+		case responses := <-done:
+			// Notifications sent right before the response belong to it.
+			for len(request.notifications) > 0 {
+				if n := <-request.notifications; !events.write(n) {
+					session.forward(n)
+				}
+			}
+			events.respond(responses, batch)
+			return
+		}
+	}
+}
+
+func (h *StreamableHTTPServer) handleGet(w http.ResponseWriter, r *http.Request) {
+	if !acceptsEventStream(r) {
+		w.Header().Set("Allow", "POST, DELETE")
+		http.Error(w, "GET requires Accept: text/event-stream", http.StatusMethodNotAllowed)
+		return
+	}
+
+	session := h.sessionFor(w, r, h.requestContext(r))
+	if session == nil {
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	cursor := int64(-1)
+	if lastEventID := r.Header.Get(lastEventIDHeader); lastEventID != "" {
+		id, err := strconv.ParseInt(lastEventID, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid Last-Event-ID", http.StatusBadRequest)
+			return
+		}
+		cursor = id
+	}
+
+	// Only one stream per session receives messages, so a reconnecting
+	// client takes over from its previous stream.
+	streamCtx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	session.open.Add(1)
+	defer func() {
+		session.open.Add(-1)
+		session.touch(h.now())
+	}()
+	if cursor < 0 {
+		cursor = session.attachStream(cancel)
+	} else {
+		session.attachStream(cancel)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		events, wait := session.eventsAfter(cursor)
+		for _, e := range events {
+			fmt.Fprintf(w, "id: %d\nevent: message\ndata: %s\n\n", e.id, e.data)
+			cursor = e.id
+		}
+		if len(events) > 0 {
+			flusher.Flush()
+			session.markDelivered(cursor)
+		}
+
+		select {
+		case <-wait:
+		case <-streamCtx.Done():
+			return
+		case <-session.ctx.Done():
+			return
+		}
+	}
+}
+
+func (h *StreamableHTTPServer) handleDelete(w http.ResponseWriter, r *http.Request) {
+	session := h.sessionFor(w, r, h.requestContext(r))
+	if session == nil {
+		return
+	}
+
+	h.mu.Lock()
+	delete(h.sessions, session.id)
+	h.mu.Unlock()
+	h.closeSession(session)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// requestContext returns the context of r with the values added by the HTTP
+// context function.
+func (h *StreamableHTTPServer) requestContext(r *http.Request) context.Context {
+	ctx := r.Context()
+	if h.contextFunc != nil {
+		ctx = h.contextFunc(ctx, r)
+	}
+	return ctx
+}
+
+// ownerOf identifies the caller of the request with context ctx.
+func (h *StreamableHTTPServer) ownerOf(ctx context.Context) string {
+	if h.owner == nil {
+		return ""
+	}
+	return h.owner(ctx)
+}
+
+// sessionFor returns the session named by the request's Mcp-Session-Id
+// header, or writes an error response and returns nil. Sessions of another
+// caller are reported as not found.
+func (h *StreamableHTTPServer) sessionFor(w http.ResponseWriter, r *http.Request, ctx context.Context) *streamableSession {
+	id := r.Header.Get(SessionIDHeader)
+	if id == "" {
+		http.Error(w, "Missing "+SessionIDHeader+" header", http.StatusBadRequest)
+		return nil
+	}
+
+	h.mu.Lock()
+	session, ok := h.sessions[id]
+	h.mu.Unlock()
+	if ok && session.owner != h.ownerOf(ctx) {
+		slog.Warn("Rejecting request for the session of another client", "session", id)
+		ok = false
+	}
+	if !ok {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return nil
+	}
+	session.touch(h.now())
+	return session
+}
+
+// newSession starts a session that only accepts requests from owner.
+func (h *StreamableHTTPServer) newSession(owner string) (*streamableSession, error) {
+	h.sweepOnce.Do(func() { go h.sweep() })
+
+	h.mu.Lock()
+	full := h.full()
+	h.mu.Unlock()
+	if full && h.expireIdle() == 0 {
+		return nil, errTooManySessions
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, fmt.Errorf("failed to generate session ID: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	session := &streamableSession{
+		id:            hex.EncodeToString(b),
+		owner:         owner,
+		notifications: make(chan mcp.JSONRPCNotification, 100),
+		ctx:           ctx,
+		cancel:        cancel,
+		bufferSize:    h.bufferSize,
+		delivered:     -1,
+		updated:       make(chan struct{}),
+	}
+	session.touch(h.now())
+
+	if err := h.server.RegisterSession(ctx, session); err != nil {
+		cancel()
+		return nil, err
+	}
+	go session.collectNotifications()
+
+	// Another request may have taken the last free slot meanwhile.
+	h.mu.Lock()
+	if h.full() {
+		h.mu.Unlock()
+		h.closeSession(session)
+		return nil, errTooManySessions
+	}
+	h.sessions[session.id] = session
+	h.mu.Unlock()
+
+	slog.Debug("Streamable HTTP session started", "session", session.id)
+	return session, nil
+}
+
+// full reports whether the session limit is reached. h.mu must be held.
+func (h *StreamableHTTPServer) full() bool {
+	return h.maxSessions > 0 && len(h.sessions) >= h.maxSessions
+}
+
+// sweep ends idle sessions periodically until Shutdown is called.
+func (h *StreamableHTTPServer) sweep() {
+	if h.idleTimeout <= 0 {
+		return
+	}
+	ticker := time.NewTicker(min(h.idleTimeout/2, time.Minute))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			h.expireIdle()
+		case <-h.sweepCtx.Done():
+			return
+		}
+	}
+}
+
+// expireIdle ends every session that has had no request and no open stream
+// for the idle timeout, and returns how many it ended.
+func (h *StreamableHTTPServer) expireIdle() int {
+	if h.idleTimeout <= 0 {
+		return 0
+	}
+	cutoff := h.now().Add(-h.idleTimeout)
+
+	h.mu.Lock()
+	var idle []*streamableSession
+	for id, session := range h.sessions {
+		if session.open.Load() == 0 && session.lastActive().Before(cutoff) {
+			idle = append(idle, session)
+			delete(h.sessions, id)
+		}
+	}
+	h.mu.Unlock()
+
+	for _, session := range idle {
+		slog.Debug("Streamable HTTP session expired", "session", session.id)
+		h.closeSession(session)
+	}
+	return len(idle)
+}
+
+func (h *StreamableHTTPServer) closeSession(session *streamableSession) {
+	h.server.UnregisterSession(session.id)
+	session.cancel()
+	slog.Debug("Streamable HTTP session ended", "session", session.id)
+}
+
+type event struct {
+	id   int64
+	data []byte
+}
+
+// streamableSession implements server.ClientSession. Notifications sent to it
+// are numbered and buffered until a GET stream delivers them.
+type streamableSession struct {
+	id            string
+	notifications chan mcp.JSONRPCNotification
+	initialized   atomic.Bool
+	ctx           context.Context
+	cancel        context.CancelFunc
+	bufferSize    int
+	// owner identifies the caller that created the session.
+	owner string
+	// active is the time the last request started or ended, in Unix
+	// nanoseconds, and open the number of POST requests being handled and
+	// GET streams.
+	active atomic.Int64
+	open   atomic.Int32
+
+	mu     sync.Mutex
+	events []event
+	nextID int64
+	// delivered is the ID of the last event written to a stream.
+	delivered    int64
+	updated      chan struct{}
+	cancelStream context.CancelFunc
+}
+
+func (s *streamableSession) SessionID() string { return s.id }
+
+func (s *streamableSession) NotificationChannel() chan<- mcp.JSONRPCNotification {
+	return s.notifications
+}
+
+func (s *streamableSession) Initialize()       { s.initialized.Store(true) }
+func (s *streamableSession) Initialized() bool { return s.initialized.Load() }
+
+// forward queues a notification for the GET stream.
+func (s *streamableSession) forward(n mcp.JSONRPCNotification) {
+	select {
+	case s.notifications <- n:
+	default:
+		slog.Warn("Dropping notification for blocked session", "session", s.id, "method", n.Method)
+	}
+}
+
+func (s *streamableSession) touch(now time.Time) { s.active.Store(now.UnixNano()) }
+
+func (s *streamableSession) lastActive() time.Time { return time.Unix(0, s.active.Load()) }
+
+func (s *streamableSession) collectNotifications() {
+	for {
+		select {
+		case n := <-s.notifications:
+			data, err := json.Marshal(n)
+			if err != nil {
+				slog.Warn("Failed to marshal notification", "session", s.id, "error", err)
+				continue
+			}
+			s.appendEvent(data)
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *streamableSession) appendEvent(data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.events = append(s.events, event{id: s.nextID, data: data})
+	s.nextID++
+	if len(s.events) > s.bufferSize {
+		s.events = s.events[len(s.events)-s.bufferSize:]
+	}
+
+	close(s.updated)
+	s.updated = make(chan struct{})
+}
+
+// eventsAfter returns the buffered events with IDs greater than cursor, and a
+// channel that is closed when another event arrives.
+func (s *streamableSession) eventsAfter(cursor int64) ([]event, <-chan struct{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var events []event
+	for _, e := range s.events {
+		if e.id > cursor {
+			events = append(events, e)
+		}
+	}
+	return events, s.updated
+}
+
+func (s *streamableSession) markDelivered(id int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.delivered = max(s.delivered, id)
+}
+
+// attachStream makes the caller the session's only stream, ending the
+// previous one, and returns the ID of the last event already delivered.
+func (s *streamableSession) attachStream(cancel context.CancelFunc) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cancelStream != nil {
+		s.cancelStream()
+	}
+	s.cancelStream = cancel
+	return s.delivered
+}
+
+// requestSession is the session as seen by the handlers of one POST
+// request, so the notifications they send can be delivered on its response.
+type requestSession struct {
+	*streamableSession
+	notifications chan mcp.JSONRPCNotification
+}
+
+func (s *requestSession) NotificationChannel() chan<- mcp.JSONRPCNotification {
+	return s.notifications
+}
+
+// eventWriter writes the response to a POST request. The response becomes an
+// event stream when the first notification is written to it.
+type eventWriter struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+	// streaming is set once the event stream has started.
+	streaming bool
+}
+
+// newEventWriter returns a writer for w that may stream events if the client
+// accepts them.
+func newEventWriter(w http.ResponseWriter, acceptsEvents bool) *eventWriter {
+	e := &eventWriter{w: w}
+	if acceptsEvents {
+		e.flusher, _ = w.(http.Flusher)
+	}
+	return e
+}
+
+// write sends n as an event, starting the stream if needed. It reports false
+// if the response cannot stream events.
+func (e *eventWriter) write(n mcp.JSONRPCNotification) bool {
+	if e.flusher == nil {
+		return false
+	}
+	if !e.streaming {
+		e.w.Header().Set("Content-Type", "text/event-stream")
+		e.w.Header().Set("Cache-Control", "no-cache")
+		e.w.WriteHeader(http.StatusOK)
+		e.streaming = true
+	}
+	e.event(n)
+	e.flusher.Flush()
+	return true
+}
+
+func (e *eventWriter) event(msg any) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		slog.Warn("Failed to marshal message", "error", err)
+		return
+	}
+	fmt.Fprintf(e.w, "event: message\ndata: %s\n\n", data)
+}
+
+// respond ends the response with the responses to the request's messages:
+// as events if the stream has started, otherwise as JSON.
+func (e *eventWriter) respond(responses []mcp.JSONRPCMessage, batch bool) {
+	if e.streaming {
+		for _, resp := range responses {
+			e.event(resp)
+		}
+		e.flusher.Flush()
+		return
+	}
+
+	if len(responses) == 0 {
+		e.w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	e.w.Header().Set("Content-Type", "application/json")
+	if batch {
+		json.NewEncoder(e.w).Encode(responses)
+	} else {
+		json.NewEncoder(e.w).Encode(responses[0])
+	}
+}
+
+// splitBatch returns the messages in body, which holds either one JSON-RPC
+// message or a batch of them.
+func splitBatch(body json.RawMessage) ([]json.RawMessage, bool, error) {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) == 0 || trimmed[0] != '[' {
+		return []json.RawMessage{body}, false, nil
+	}
+
+	var messages []json.RawMessage
+	if err := json.Unmarshal(trimmed, &messages); err != nil {
+		return nil, true, fmt.Errorf("invalid batch: %v", err)
+	}
+	if len(messages) == 0 {
+		return nil, true, errors.New("empty batch")
+	}
+	return messages, true, nil
+}
+
+func containsInitialize(messages []json.RawMessage) bool {
+	for _, msg := range messages {
+		var m struct {
+			Method string `json:"method"`
+		}
+		if json.Unmarshal(msg, &m) == nil && m.Method == string(mcp.MethodInitialize) {
+			return true
+		}
+	}
+	return false
+}
+
+func acceptsEventStream(r *http.Request) bool {
+	for _, accept := range r.Header.Values("Accept") {
+		for _, part := range strings.Split(accept, ",") {
+			mediaType, _, _ := mime.ParseMediaType(strings.TrimSpace(part))
+			if mediaType == "text/event-stream" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func writeJSONRPCError(w http.ResponseWriter, status, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(mcp.JSONRPCError{
+		JSONRPC: mcp.JSONRPC_VERSION,
+		Error: struct {
+			Code    int         `json:"code"`
+			Message string      `json:"message"`
+			Data    interface{} `json:"data,omitempty"`
+		}{Code: code, Message: message},
+	})
+}
@@ -0,0 +1,455 @@
+package transport
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+type userKey struct{}
+
+// newTestServer serves an MCP server with three tools: whoami, which returns
+// the X-User header of the request, sleep, which waits for ms milliseconds,
+// and notify, which sends a log message notification to the calling session.
+func newTestServer(t *testing.T, opts ...StreamableHTTPOption) *httptest.Server {
+	t.Helper()
+
+	s := server.NewMCPServer("test", "1.0.0", server.WithLogging())
+	s.AddTool(mcp.NewTool("whoami"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		user, _ := ctx.Value(userKey{}).(string)
+		return mcp.NewToolResultText(user), nil
+	})
+	s.AddTool(mcp.NewTool("sleep", mcp.WithNumber("ms")), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		ms, _ := request.Params.Arguments["ms"].(float64)
+		time.Sleep(time.Duration(ms) * time.Millisecond)
+		return mcp.NewToolResultText("slept"), nil
+	})
+	s.AddTool(mcp.NewTool("notify", mcp.WithString("message")), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		err := s.SendNotificationToClient(ctx, "notifications/message", map[string]any{
+			"level": "info",
+			"data":  request.Params.Arguments["message"],
+		})
+		if err != nil {
+			return nil, err
+		}
+		return mcp.NewToolResultText("sent"), nil
+	})
+
+	h := NewStreamableHTTPServer(s, append([]StreamableHTTPOption{
+		WithEventBufferSize(2),
+		WithHTTPContextFunc(func(ctx context.Context, r *http.Request) context.Context {
+			return context.WithValue(ctx, userKey{}, r.Header.Get("X-User"))
+		}),
+	}, opts...)...)
+	ts := httptest.NewServer(h)
+	t.Cleanup(func() {
+		h.Shutdown(context.Background())
+		ts.CloseClientConnections()
+		ts.Close()
+	})
+	return ts
+}
+
+func post(t *testing.T, url, sessionID string, body any, header ...string) *http.Response {
+	t.Helper()
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		t.Fatalf("failed to marshal request: %v", err)
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	if sessionID != "" {
+		req.Header.Set(SessionIDHeader, sessionID)
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func request(id int, method string, params any) map[string]any {
+	return map[string]any{"jsonrpc": "2.0", "id": id, "method": method, "params": params}
+}
+
+func callTool(id int, name string, args map[string]any) map[string]any {
+	return request(id, "tools/call", map[string]any{"name": name, "arguments": args})
+}
+
+func initialize(t *testing.T, url string, header ...string) string {
+	t.Helper()
+
+	resp := post(t, url+"/mcp", "", request(1, "initialize", map[string]any{
+		"protocolVersion": mcp.LATEST_PROTOCOL_VERSION,
+		"clientInfo":      map[string]any{"name": "test", "version": "1.0.0"},
+	}), header...)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("initialize failed with status %d", resp.StatusCode)
+	}
+	sessionID := resp.Header.Get(SessionIDHeader)
+	if sessionID == "" {
+		t.Fatal("expected session ID in initialize response")
+	}
+
+	resp = post(t, url+"/mcp", sessionID, map[string]any{"jsonrpc": "2.0", "method": "notifications/initialized"}, header...)
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("expected 202 for notification, got %d", resp.StatusCode)
+	}
+	return sessionID
+}
+
+func decodeText(t *testing.T, resp *http.Response) string {
+	t.Helper()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		t.Fatalf("unexpected status %d: %s", resp.StatusCode, body)
+	}
+	var msg struct {
+		Result struct {
+			Content []mcp.TextContent `json:"content"`
+		} `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&msg); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(msg.Result.Content) != 1 {
+		t.Fatalf("unexpected result: %+v", msg.Result)
+	}
+	return msg.Result.Content[0].Text
+}
+
+func TestSessions(t *testing.T) {
+	ts := newTestServer(t)
+	endpoint := ts.URL + "/mcp"
+	sessionID := initialize(t, ts.URL)
+
+	if got := decodeText(t, post(t, endpoint, sessionID, callTool(2, "whoami", nil), "X-User", "alice")); got != "alice" {
+		t.Errorf("expected per-request context value alice, got %q", got)
+	}
+	if got := decodeText(t, post(t, endpoint, sessionID, callTool(3, "whoami", nil), "X-User", "bob")); got != "bob" {
+		t.Errorf("expected per-request context value bob, got %q", got)
+	}
+
+	resp := post(t, endpoint, sessionID, []any{request(4, "ping", nil), request(5, "ping", nil)})
+	var batch []map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&batch); err != nil || len(batch) != 2 {
+		t.Errorf("expected 2 batch responses, got %v, %v", batch, err)
+	}
+
+	if resp := post(t, endpoint, "", request(6, "ping", nil)); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 without session ID, got %d", resp.StatusCode)
+	}
+	if resp := post(t, endpoint, "unknown", request(7, "ping", nil)); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown session, got %d", resp.StatusCode)
+	}
+
+	req, _ := http.NewRequest(http.MethodDelete, endpoint, nil)
+	req.Header.Set(SessionIDHeader, sessionID)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("expected 204 for delete, got %d", resp.StatusCode)
+	}
+	if resp := post(t, endpoint, sessionID, request(8, "ping", nil)); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 after delete, got %d", resp.StatusCode)
+	}
+}
+
+func TestRejectedRequests(t *testing.T) {
+	ts := newTestServer(t)
+	endpoint := ts.URL + "/mcp"
+
+	resp, err := http.Post(endpoint, "text/plain", strings.NewReader("{}"))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnsupportedMediaType {
+		t.Errorf("expected 415 for text/plain body, got %d", resp.StatusCode)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, endpoint, nil)
+	req.Header.Set("Accept", "application/json")
+	if resp, err = http.DefaultClient.Do(req); err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405 for GET without event stream, got %d", resp.StatusCode)
+	}
+
+	if resp := post(t, endpoint, "", request(1, "ping", nil), "Origin", "http://evil.example"); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected 403 for foreign origin, got %d", resp.StatusCode)
+	}
+
+	ts = newTestServer(t, WithMaxBodySize(64))
+	if resp := post(t, ts.URL+"/mcp", "", callTool(1, "notify", map[string]any{"message": strings.Repeat("a", 64)})); resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413 for a body over the limit, got %d", resp.StatusCode)
+	}
+}
+
+func TestSessionLifetime(t *testing.T) {
+	now := time.Now()
+	var mu sync.Mutex
+	clock := func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+	advance := func(d time.Duration) {
+		mu.Lock()
+		defer mu.Unlock()
+		now = now.Add(d)
+	}
+
+	ts := newTestServer(t, WithMaxSessions(1), WithSessionIdleTimeout(time.Minute), func(s *StreamableHTTPServer) {
+		s.now = clock
+	})
+	endpoint := ts.URL + "/mcp"
+	first := initialize(t, ts.URL)
+
+	initializeStatus := func() int {
+		resp := post(t, endpoint, "", request(1, "initialize", map[string]any{
+			"protocolVersion": mcp.LATEST_PROTOCOL_VERSION,
+			"clientInfo":      map[string]any{"name": "test", "version": "1.0.0"},
+		}))
+		return resp.StatusCode
+	}
+	if status := initializeStatus(); status != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 at the session limit, got %d", status)
+	}
+
+	// Requests keep a session alive.
+	advance(50 * time.Second)
+	if resp := post(t, endpoint, first, request(2, "ping", nil)); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected the active session to be kept, got %d", resp.StatusCode)
+	}
+	advance(50 * time.Second)
+	if status := initializeStatus(); status != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 while the session is active, got %d", status)
+	}
+
+	// An idle session makes room for a new one.
+	advance(20 * time.Second)
+	second := initialize(t, ts.URL)
+	if resp := post(t, endpoint, first, request(3, "ping", nil)); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected the idle session to be ended, got %d", resp.StatusCode)
+	}
+
+	// A request that is still being handled keeps a session alive.
+	slept := make(chan *http.Response, 1)
+	go func() {
+		slept <- post(t, endpoint, second, callTool(4, "sleep", map[string]any{"ms": 500}))
+	}()
+	time.Sleep(100 * time.Millisecond)
+	advance(time.Hour)
+	if status := initializeStatus(); status != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 while a request of the session is handled, got %d", status)
+	}
+	if got := decodeText(t, <-slept); got != "slept" {
+		t.Errorf("unexpected sleep result %q", got)
+	}
+
+	// An open stream keeps a session alive too.
+	openStream(t, endpoint, second, "")
+	advance(time.Hour)
+	if status := initializeStatus(); status != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 while the session has a stream, got %d", status)
+	}
+}
+
+type sseEvent struct {
+	id   string
+	data string
+}
+
+// openStream starts a GET stream and returns a channel of its events.
+func openStream(t *testing.T, endpoint, sessionID, lastEventID string) <-chan sseEvent {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set(SessionIDHeader, sessionID)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("failed to open stream: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected stream status %d", resp.StatusCode)
+	}
+
+	events := make(chan sseEvent, 10)
+	go func() {
+		defer close(events)
+		defer resp.Body.Close()
+		var e sseEvent
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case strings.HasPrefix(line, "id: "):
+				e.id = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "data: "):
+				e.data = strings.TrimPrefix(line, "data: ")
+			case line == "":
+				events <- e
+				e = sseEvent{}
+			}
+		}
+	}()
+	return events
+}
+
+func nextEvent(t *testing.T, events <-chan sseEvent) sseEvent {
+	t.Helper()
+	select {
+	case e := <-events:
+		return e
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for event")
+		return sseEvent{}
+	}
+}
+
+// notify calls the notify tool from a client that only accepts JSON
+// responses, so the notification is sent on the GET stream.
+func notify(t *testing.T, endpoint, sessionID, message string) {
+	t.Helper()
+	resp := post(t, endpoint, sessionID, callTool(10, "notify", map[string]any{"message": message}), "Accept", "application/json")
+	if got := decodeText(t, resp); got != "sent" {
+		t.Fatalf("unexpected notify result %q", got)
+	}
+}
+
+func TestStreamResumption(t *testing.T) {
+	ts := newTestServer(t)
+	endpoint := ts.URL + "/mcp"
+	sessionID := initialize(t, ts.URL)
+
+	// Notifications sent before a stream is open wait in the buffer.
+	notify(t, endpoint, sessionID, "first")
+	events := openStream(t, endpoint, sessionID, "")
+	first := nextEvent(t, events)
+	if first.id != "0" || !strings.Contains(first.data, `"first"`) {
+		t.Errorf("unexpected first event: %+v", first)
+	}
+
+	notify(t, endpoint, sessionID, "second")
+	if e := nextEvent(t, events); e.id != "1" || !strings.Contains(e.data, `"second"`) {
+		t.Errorf("unexpected second event: %+v", e)
+	}
+
+	notify(t, endpoint, sessionID, "third")
+	nextEvent(t, events)
+
+	// A client that only saw the first event gets the rest again on
+	// reconnect, and the previous stream is closed.
+	resumed := openStream(t, endpoint, sessionID, first.id)
+	for _, want := range []string{"second", "third"} {
+		if e := nextEvent(t, resumed); !strings.Contains(e.data, fmt.Sprintf("%q", want)) {
+			t.Errorf("expected replayed %s event, got %+v", want, e)
+		}
+	}
+	select {
+	case e, ok := <-events:
+		if ok {
+			t.Errorf("expected previous stream to be closed, got event %+v", e)
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("expected previous stream to be closed")
+	}
+}
+
+func TestPostStreamsNotifications(t *testing.T) {
+	ts := newTestServer(t)
+	endpoint := ts.URL + "/mcp"
+	sessionID := initialize(t, ts.URL)
+	events := openStream(t, endpoint, sessionID, "")
+
+	resp := post(t, endpoint, sessionID, callTool(2, "notify", map[string]any{"message": "progress"}))
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("expected an event stream response, got %q", ct)
+	}
+	var data []string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if line := scanner.Text(); strings.HasPrefix(line, "data: ") {
+			data = append(data, strings.TrimPrefix(line, "data: "))
+		}
+	}
+	if len(data) != 2 || !strings.Contains(data[0], `"progress"`) || !strings.Contains(data[1], `"sent"`) {
+		t.Fatalf("expected the notification and then the result, got %v", data)
+	}
+
+	select {
+	case e := <-events:
+		t.Errorf("expected the notification only on the POST response, got %+v on the GET stream", e)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestSessionOwner(t *testing.T) {
+	ts := newTestServer(t, WithSessionOwner(func(ctx context.Context) string {
+		user, _ := ctx.Value(userKey{}).(string)
+		return user
+	}))
+	endpoint := ts.URL + "/mcp"
+	sessionID := initialize(t, ts.URL, "X-User", "alice")
+
+	if resp := post(t, endpoint, sessionID, request(2, "ping", nil), "X-User", "alice"); resp.StatusCode != http.StatusOK {
+		t.Errorf("expected the owner to use the session, got %d", resp.StatusCode)
+	}
+	if resp := post(t, endpoint, sessionID, request(3, "ping", nil), "X-User", "mallory"); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 for another client's POST, got %d", resp.StatusCode)
+	}
+
+	for _, method := range []string{http.MethodGet, http.MethodDelete} {
+		req, _ := http.NewRequest(method, endpoint, nil)
+		req.Header.Set("Accept", "text/event-stream")
+		req.Header.Set(SessionIDHeader, sessionID)
+		req.Header.Set("X-User", "mallory")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("expected 404 for another client's %s, got %d", method, resp.StatusCode)
+		}
+	}
+
+	if resp := post(t, endpoint, sessionID, request(4, "ping", nil), "X-User", "alice"); resp.StatusCode != http.StatusOK {
+		t.Errorf("expected the session to survive, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,157 @@
+package usage
+
+import (
+	"context"
+	"mime"
+	"strconv"
+
+	"github.com/cochlearai/cochl-mcp-server/client"
+)
+
+// Client is a client.CochlSense that accounts the audio it submits in a
+// Meter and refuses sessions that would exceed its limits.
+type Client struct {
+	client.CochlSense
+	meter      *Meter
+	key        string
+	clientName string
+}
+
+// Client returns c accounting its sessions to key, the project key c was
+// created with, and to the named client, which may be empty.
+func (m *Meter) Client(c client.CochlSense, key, clientName string) *Client {
+	return &Client{CochlSense: c, meter: m, key: key, clientName: clientName}
+}
+
+// Report returns the usage of the project key of the client.
+func (c *Client) Report() Report {
+	return c.meter.Report(c.key)
+}
+
+// BaseURL returns the API base URL of the wrapped client, if it has one.
+func (c *Client) BaseURL() string {
+	if b, ok := c.CochlSense.(interface{ BaseURL() string }); ok {
+		return b.BaseURL()
+	}
+	return ""
+}
+
+// KeyID identifies the project key of the wrapped client, if it has one.
+func (c *Client) KeyID() string {
+	if k, ok := c.CochlSense.(interface{ KeyID() string }); ok {
+		return k.KeyID()
+	}
+	return ""
+}
+
+// CreateSession accounts a whole file when its session is created. The
+// audio is taken back if the session is not created or its upload fails.
+func (c *Client) CreateSession(ctx context.Context, fileName, contentType string, duration float64, fileSize int) (*client.RespCreateSession, error) {
+	m := c.meter
+	r, err := m.reserve(c.key, c.clientName, 1, duration, int64(fileSize))
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.CochlSense.CreateSession(ctx, fileName, contentType, duration, fileSize)
+	if err != nil {
+		m.release(r)
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.files[resp.SessionID] = r
+	return resp, nil
+}
+
+// CreateStreamSession is refused once a limit is reached. The audio of the
+// stream is accounted as its chunks are uploaded.
+func (c *Client) CreateStreamSession(ctx context.Context, contentType string) (*client.RespCreateSession, error) {
+	m := c.meter
+	r, err := m.reserve(c.key, c.clientName, 1, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.CochlSense.CreateStreamSession(ctx, contentType)
+	if err != nil {
+		m.release(r)
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.streams[resp.SessionID] = bytesPerSecond(contentType)
+	return resp, nil
+}
+
+// UploadChunk accounts the chunks of stream sessions. The audio of file
+// sessions was accounted when they were created, and is taken back if the
+// upload fails.
+func (c *Client) UploadChunk(ctx context.Context, sessionID string, chunkSequence int, chunk []byte) (*client.RespUploadChunk, error) {
+	m := c.meter
+	m.mu.Lock()
+	rate, stream := m.streams[sessionID]
+	file := m.files[sessionID]
+	delete(m.files, sessionID)
+	m.mu.Unlock()
+	if !stream {
+		resp, err := c.CochlSense.UploadChunk(ctx, sessionID, chunkSequence, chunk)
+		if err != nil && file != nil {
+			m.release(file)
+		}
+		return resp, err
+	}
+
+	var seconds float64
+	if rate > 0 {
+		seconds = float64(len(chunk)) / rate
+	}
+	r, err := m.reserve(c.key, c.clientName, 0, seconds, int64(len(chunk)))
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.CochlSense.UploadChunk(ctx, sessionID, chunkSequence, chunk)
+	if err != nil {
+		m.release(r)
+		return nil, err
+	}
+	return resp, nil
+}
+
+// DeleteSession forgets the rate of a stream session.
+func (c *Client) DeleteSession(ctx context.Context, sessionID string) error {
+	c.meter.mu.Lock()
+	delete(c.meter.streams, sessionID)
+	delete(c.meter.files, sessionID)
+	c.meter.mu.Unlock()
+	return c.CochlSense.DeleteSession(ctx, sessionID)
+}
+
+// bytesPerSecond returns the data rate of 16-bit PCM audio of the given
+// content type, such as "audio/x-raw; rate=22050; channels=1", or zero if the
+// rate is unknown.
+func bytesPerSecond(contentType string) float64 {
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return 0
+	}
+	rate, _ := strconv.Atoi(params["rate"])
+	channels, err := strconv.Atoi(params["channels"])
+	if err != nil {
+		channels = 1
+	}
+	return float64(rate * channels * 2)
+}
+
+// ReportFor returns the usage of the project key of c, if c accounts its
+// usage.
+func ReportFor(c client.CochlSense) (Report, bool) {
+	uc, ok := c.(*Client)
+	if !ok {
+		return Report{}, false
+	}
+	return uc.Report(), true
+}
@@ -0,0 +1,451 @@
+// Package usage accounts the audio submitted to Cochl Sense per project key,
+// broken down by the authenticated client that submitted it, so a team
+// sharing keys can see where its quota goes. Counters are kept per UTC day
+// and month, persisted in a JSON file, and checked against optional limits
+// before sessions are created.
+package usage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrLimitExceeded is wrapped by the error of a session refused because of a
+// limit.
+var ErrLimitExceeded = errors.New("usage limit exceeded")
+
+// Limits caps the audio submitted with each project key. Zero means no
+// limit.
+type Limits struct {
+	DailySeconds   float64 `json:"daily_seconds,omitempty"`
+	MonthlySeconds float64 `json:"monthly_seconds,omitempty"`
+	DailyBytes     int64   `json:"daily_bytes,omitempty"`
+	MonthlyBytes   int64   `json:"monthly_bytes,omitempty"`
+}
+
+// Counter is the audio submitted in a period.
+type Counter struct {
+	Sessions int     `json:"sessions"`
+	Seconds  float64 `json:"seconds"`
+	Bytes    int64   `json:"bytes"`
+}
+
+func (c *Counter) add(sessions int, seconds float64, bytes int64) {
+	c.Sessions += sessions
+	c.Seconds += seconds
+	c.Bytes += bytes
+}
+
+// Usage is the audio submitted today, this month and in total. Day and Month
+// name the periods the counters belong to; older counters are reset when a
+// new period starts.
+type Usage struct {
+	Day     string  `json:"day"`
+	Today   Counter `json:"today"`
+	Month   string  `json:"month"`
+	Monthly Counter `json:"this_month"`
+	Total   Counter `json:"total"`
+}
+
+// roll resets the counters of the periods that ended before now.
+func (u *Usage) roll(now time.Time) {
+	if day := now.Format(time.DateOnly); u.Day != day {
+		u.Day, u.Today = day, Counter{}
+	}
+	if month := now.Format("2006-01"); u.Month != month {
+		u.Month, u.Monthly = month, Counter{}
+	}
+}
+
+func (u *Usage) add(now time.Time, sessions int, seconds float64, bytes int64) {
+	u.roll(now)
+	u.Today.add(sessions, seconds, bytes)
+	u.Monthly.add(sessions, seconds, bytes)
+	u.Total.add(sessions, seconds, bytes)
+}
+
+// remove takes back audio added at the given time. The counters of periods
+// that ended since are left alone.
+func (u *Usage) remove(at time.Time, sessions int, seconds float64, bytes int64) {
+	if u.Day == at.Format(time.DateOnly) {
+		u.Today.add(-sessions, -seconds, -bytes)
+	}
+	if u.Month == at.Format("2006-01") {
+		u.Monthly.add(-sessions, -seconds, -bytes)
+	}
+	u.Total.add(-sessions, -seconds, -bytes)
+}
+
+// account is the usage of one project key.
+type account struct {
+	// Key is the redacted project key.
+	Key     string            `json:"key"`
+	Usage   Usage             `json:"usage"`
+	Clients map[string]*Usage `json:"clients,omitempty"`
+}
+
+// Meter accounts usage and enforces limits. It is safe for concurrent use.
+// Changes are written to the usage file in the background; Close writes the
+// last of them.
+type Meter struct {
+	mu       sync.Mutex
+	path     string
+	limits   Limits
+	now      func() time.Time
+	accounts map[string]*account
+	// streams holds the bytes per second of the audio of open stream
+	// sessions, by session ID.
+	streams map[string]float64
+	// files holds the reservations of file sessions whose audio is not
+	// uploaded yet, by session ID.
+	files map[string]*reservation
+
+	// changes wakes the goroutine saving the counters, which closes saved
+	// once changes is closed. Changes made while a save runs are coalesced
+	// into the next one.
+	changes chan struct{}
+	saved   chan struct{}
+	closed  bool
+}
+
+// Option configures a Meter.
+type Option func(*Meter)
+
+// WithLimits sets the limits enforced for each project key.
+func WithLimits(l Limits) Option {
+	return func(m *Meter) {
+		m.limits = l
+	}
+}
+
+// WithNow sets the clock that decides the current day and month.
+func WithNow(now func() time.Time) Option {
+	return func(m *Meter) {
+		m.now = now
+	}
+}
+
+// DefaultPath returns the file the counters are persisted in when none is
+// configured.
+func DefaultPath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "cochl-mcp-server", "usage.json"), nil
+}
+
+// Open returns a meter persisting its counters in the file at path, loading
+// those of earlier runs. With an empty path the counters are only kept in
+// memory.
+func Open(path string, opts ...Option) (*Meter, error) {
+	m := &Meter{
+		path:     path,
+		now:      time.Now,
+		accounts: make(map[string]*account),
+		streams:  make(map[string]float64),
+		files:    make(map[string]*reservation),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	if path == "" {
+		return m, nil
+	}
+	m.changes = make(chan struct{}, 1)
+	m.saved = make(chan struct{})
+
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("failed to read usage file: %w", err)
+	default:
+		if err := json.Unmarshal(data, &m.accounts); err != nil {
+			return nil, fmt.Errorf("failed to decode usage file %s: %w", path, err)
+		}
+		if m.accounts == nil {
+			m.accounts = make(map[string]*account)
+		}
+	}
+	go m.saveChanges()
+	return m, nil
+}
+
+// Close stops saving in the background and writes the counters to the usage
+// file. Later changes are only kept in memory.
+func (m *Meter) Close() error {
+	if m.path == "" {
+		return nil
+	}
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return nil
+	}
+	m.closed = true
+	close(m.changes)
+	m.mu.Unlock()
+
+	<-m.saved
+	return m.save()
+}
+
+// Limits returns the limits enforced for each project key.
+func (m *Meter) Limits() Limits {
+	return m.limits
+}
+
+// keyID identifies a project key in the usage file without storing it.
+func keyID(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:8])
+}
+
+// redact hides all but the last four characters of a project key.
+func redact(key string) string {
+	if len(key) < 12 {
+		return "****"
+	}
+	return "****" + key[len(key)-4:]
+}
+
+func (m *Meter) account(key string) *account {
+	id := keyID(key)
+	a, ok := m.accounts[id]
+	if !ok {
+		a = &account{Key: redact(key)}
+		m.accounts[id] = a
+	}
+	return a
+}
+
+// LimitError is the error of a session refused because of a limit.
+type LimitError struct {
+	Key string
+	// Period is "daily" or "monthly".
+	Period string
+	// Unit is "seconds" or "bytes".
+	Unit      string
+	Limit     float64
+	Used      float64
+	Requested float64
+	Resets    time.Time
+}
+
+func (e *LimitError) Error() string {
+	msg := fmt.Sprintf("the %s limit of %g %s of audio for project key %s is reached: %g used",
+		e.Period, e.Limit, e.Unit, e.Key, e.Used)
+	if e.Requested > 0 {
+		msg += fmt.Sprintf(", %g more requested", e.Requested)
+	}
+	return msg + fmt.Sprintf(". It resets at %s", e.Resets.Format(time.RFC3339))
+}
+
+func (e *LimitError) Unwrap() error {
+	return ErrLimitExceeded
+}
+
+// check returns a *LimitError if submitting seconds and bytes more with key
+// would exceed a limit. With no audio requested, it checks whether a limit
+// is already reached. m.mu must be held.
+func (m *Meter) check(key string, seconds float64, bytes int64) error {
+	now := m.now().UTC()
+	a := m.account(key)
+	a.Usage.roll(now)
+
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	checks := []struct {
+		period, unit string
+		limit, used  float64
+		requested    float64
+		resets       time.Time
+	}{
+		{"daily", "seconds", m.limits.DailySeconds, a.Usage.Today.Seconds, seconds, day.AddDate(0, 0, 1)},
+		{"monthly", "seconds", m.limits.MonthlySeconds, a.Usage.Monthly.Seconds, seconds, month.AddDate(0, 1, 0)},
+		{"daily", "bytes", float64(m.limits.DailyBytes), float64(a.Usage.Today.Bytes), float64(bytes), day.AddDate(0, 0, 1)},
+		{"monthly", "bytes", float64(m.limits.MonthlyBytes), float64(a.Usage.Monthly.Bytes), float64(bytes), month.AddDate(0, 1, 0)},
+	}
+	for _, c := range checks {
+		if c.limit <= 0 {
+			continue
+		}
+		if c.used+c.requested > c.limit || (c.requested == 0 && c.used >= c.limit) {
+			return &LimitError{
+				Key:       a.Key,
+				Period:    c.period,
+				Unit:      c.unit,
+				Limit:     c.limit,
+				Used:      c.used,
+				Requested: c.requested,
+				Resets:    c.resets,
+			}
+		}
+	}
+	return nil
+}
+
+// reservation is audio recorded before the request submitting it is made.
+type reservation struct {
+	key, clientName string
+	at              time.Time
+	sessions        int
+	seconds         float64
+	bytes           int64
+}
+
+// reserve records audio about to be submitted with key by the named client,
+// which may be empty, if it stays within the limits. Checking and recording
+// under one lock keeps concurrent requests from exceeding a limit together;
+// the reservation is released if the request fails.
+func (m *Meter) reserve(key, clientName string, sessions int, seconds float64, bytes int64) (*reservation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.check(key, seconds, bytes); err != nil {
+		return nil, err
+	}
+
+	r := &reservation{key: key, clientName: clientName, at: m.now().UTC(), sessions: sessions, seconds: seconds, bytes: bytes}
+	a := m.account(key)
+	a.Usage.add(r.at, sessions, seconds, bytes)
+	if clientName != "" {
+		if a.Clients == nil {
+			a.Clients = make(map[string]*Usage)
+		}
+		u, ok := a.Clients[clientName]
+		if !ok {
+			u = &Usage{}
+			a.Clients[clientName] = u
+		}
+		u.add(r.at, sessions, seconds, bytes)
+	}
+	m.changed()
+	return r, nil
+}
+
+// release takes back the audio of r after its request failed.
+func (m *Meter) release(r *reservation) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	a := m.account(r.key)
+	a.Usage.remove(r.at, r.sessions, r.seconds, r.bytes)
+	if u, ok := a.Clients[r.clientName]; ok {
+		u.remove(r.at, r.sessions, r.seconds, r.bytes)
+	}
+	m.changed()
+}
+
+// changed schedules a save of the counters. m.mu must be held.
+func (m *Meter) changed() {
+	if m.path == "" || m.closed {
+		return
+	}
+	select {
+	case m.changes <- struct{}{}:
+	default:
+		// A save is already scheduled.
+	}
+}
+
+// saveChanges saves the counters after each change until Close.
+func (m *Meter) saveChanges() {
+	defer close(m.saved)
+	for range m.changes {
+		if err := m.save(); err != nil {
+			slog.Warn("Failed to save usage", "error", err)
+		}
+	}
+}
+
+// save replaces the usage file atomically. Only encoding the counters holds
+// m.mu; calls must not overlap.
+func (m *Meter) save() error {
+	m.mu.Lock()
+	data, err := json.MarshalIndent(m.accounts, "", "  ")
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(m.path), 0o700); err != nil {
+		return fmt.Errorf("failed to create usage directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(m.path), ".usage-*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to save usage: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save usage: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save usage: %w", err)
+	}
+	return os.Rename(tmp.Name(), m.path)
+}
+
+// ClientUsage is the usage of one client in a Report.
+type ClientUsage struct {
+	Name string `json:"name"`
+	Usage
+}
+
+// Report is the usage of a project key, as returned by Meter.Report.
+type Report struct {
+	Key string `json:"project_key"`
+	Usage
+	Limits Limits `json:"limits"`
+	// Remaining is what the limits that are set still allow today and this
+	// month, keyed like the JSON fields of Limits.
+	Remaining map[string]float64 `json:"remaining,omitempty"`
+	Clients   []ClientUsage      `json:"clients,omitempty"`
+}
+
+// Report returns the usage of key, with the clients that used it sorted by
+// name. It does not change the counters; a key that was never used reports
+// no usage.
+func (m *Meter) Report(key string) Report {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now().UTC()
+	a, ok := m.accounts[keyID(key)]
+	if !ok {
+		a = &account{Key: redact(key)}
+	}
+	usage := a.Usage
+	usage.roll(now)
+	r := Report{Key: a.Key, Usage: usage, Limits: m.limits}
+	remaining := func(name string, limit, used float64) {
+		if limit > 0 {
+			if r.Remaining == nil {
+				r.Remaining = make(map[string]float64)
+			}
+			r.Remaining[name] = max(limit-used, 0)
+		}
+	}
+	remaining("daily_seconds", m.limits.DailySeconds, usage.Today.Seconds)
+	remaining("monthly_seconds", m.limits.MonthlySeconds, usage.Monthly.Seconds)
+	remaining("daily_bytes", float64(m.limits.DailyBytes), float64(usage.Today.Bytes))
+	remaining("monthly_bytes", float64(m.limits.MonthlyBytes), float64(usage.Monthly.Bytes))
+	for name, u := range a.Clients {
+		cu := ClientUsage{Name: name, Usage: *u}
+		cu.Usage.roll(now)
+		r.Clients = append(r.Clients, cu)
+	}
+	sort.Slice(r.Clients, func(i, j int) bool { return r.Clients[i].Name < r.Clients[j].Name })
+	return r
+}
@@ -0,0 +1,245 @@
+package usage
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cochlearai/cochl-mcp-server/client"
+	"github.com/cochlearai/cochl-mcp-server/client/fakesense"
+)
+
+const testKey = "test-project-key"
+
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time { return c.t }
+
+func newTestClient(t *testing.T) client.CochlSense {
+	t.Helper()
+	fake := fakesense.NewServer()
+	t.Cleanup(fake.Close)
+	return client.NewCochlSense(testKey, fake.URL, "test")
+}
+
+func TestMeterCountsAndRollsOver(t *testing.T) {
+	clk := &clock{time.Date(2026, 1, 31, 23, 0, 0, 0, time.UTC)}
+	m, err := Open("", WithNow(clk.now))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	c := newTestClient(t)
+
+	if _, err := m.Client(c, testKey, "alice").CreateSession(ctx, "a.wav", "audio/wav", 10, 1000); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Client(c, testKey, "bob").CreateSession(ctx, "b.wav", "audio/wav", 5, 500); err != nil {
+		t.Fatal(err)
+	}
+
+	r := m.Report(testKey)
+	if want := (Counter{Sessions: 2, Seconds: 15, Bytes: 1500}); r.Today != want || r.Monthly != want || r.Total != want {
+		t.Errorf("got %+v, want %+v in every period", r.Usage, want)
+	}
+	if r.Key != "****-key" {
+		t.Errorf("got key %q, want it redacted", r.Key)
+	}
+	if len(r.Clients) != 2 || r.Clients[0].Name != "alice" || r.Clients[0].Today.Seconds != 10 || r.Clients[1].Name != "bob" {
+		t.Errorf("got clients %+v, want alice then bob", r.Clients)
+	}
+
+	clk.t = clk.t.Add(2 * time.Hour)
+	r = m.Report(testKey)
+	if r.Day != "2026-02-01" || r.Today != (Counter{}) || r.Monthly != (Counter{}) || r.Total.Seconds != 15 {
+		t.Errorf("got %+v, want daily and monthly counters reset in February", r.Usage)
+	}
+	if r.Clients[0].Today != (Counter{}) || r.Clients[0].Total.Seconds != 10 {
+		t.Errorf("got client usage %+v, want it rolled over", r.Clients[0].Usage)
+	}
+}
+
+func TestMeterEnforcesLimits(t *testing.T) {
+	clk := &clock{time.Date(2026, 3, 14, 12, 0, 0, 0, time.UTC)}
+	m, err := Open("", WithNow(clk.now), WithLimits(Limits{DailySeconds: 60, MonthlyBytes: 10000}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	c := m.Client(newTestClient(t), testKey, "")
+
+	if _, err := c.CreateSession(ctx, "a.wav", "audio/wav", 50, 1000); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = c.CreateSession(ctx, "b.wav", "audio/wav", 20, 1000)
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) || !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("got error %v, want a limit error", err)
+	}
+	if limitErr.Period != "daily" || limitErr.Unit != "seconds" || limitErr.Used != 50 || limitErr.Requested != 20 {
+		t.Errorf("got %+v", limitErr)
+	}
+	if want := time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC); !limitErr.Resets.Equal(want) {
+		t.Errorf("got reset at %v, want %v", limitErr.Resets, want)
+	}
+
+	_, err = c.CreateSession(ctx, "c.wav", "audio/wav", 5, 9500)
+	if !errors.As(err, &limitErr) || limitErr.Period != "monthly" || limitErr.Unit != "bytes" {
+		t.Errorf("got error %v, want the monthly bytes limit", err)
+	}
+
+	r := c.Report()
+	if r.Total.Sessions != 1 {
+		t.Errorf("got %d sessions, want refused sessions not counted", r.Total.Sessions)
+	}
+	if r.Remaining["daily_seconds"] != 10 || r.Remaining["monthly_bytes"] != 9000 || len(r.Remaining) != 2 {
+		t.Errorf("got remaining %v", r.Remaining)
+	}
+
+	clk.t = clk.t.Add(24 * time.Hour)
+	if _, err := c.CreateSession(ctx, "b.wav", "audio/wav", 20, 1000); err != nil {
+		t.Errorf("expected the daily limit to reset, got %v", err)
+	}
+}
+
+func TestMeterHoldsLimitsConcurrently(t *testing.T) {
+	m, err := Open("", WithLimits(Limits{DailySeconds: 30}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake := fakesense.NewServer()
+	t.Cleanup(fake.Close)
+	fake.Script(fakesense.EndpointCreateSession, fakesense.Response{Status: http.StatusInternalServerError, Body: `{}`})
+	c := m.Client(client.NewCochlSense(testKey, fake.URL, "test"), testKey, "alice")
+	ctx := context.Background()
+
+	if _, err := c.CreateSession(ctx, "a.wav", "audio/wav", 10, 100); err == nil || errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("got error %v, want the scripted API failure", err)
+	}
+	if r := c.Report(); r.Total != (Counter{}) || r.Clients[0].Total != (Counter{}) {
+		t.Errorf("got %+v, want the failed session released", r)
+	}
+
+	var wg sync.WaitGroup
+	var created atomic.Int32
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := c.CreateSession(ctx, "a.wav", "audio/wav", 10, 100); err == nil {
+				created.Add(1)
+			} else if !errors.Is(err, ErrLimitExceeded) {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := created.Load(); n != 3 {
+		t.Errorf("got %d sessions, want 3 within the daily limit", n)
+	}
+	if r := c.Report(); r.Today.Seconds != 30 || len(fake.Sessions()) != 3 {
+		t.Errorf("got %+v and %d sessions, want the limit held", r.Today, len(fake.Sessions()))
+	}
+}
+
+func TestMeterCountsStreams(t *testing.T) {
+	m, err := Open("", WithLimits(Limits{DailySeconds: 1.5}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	c := m.Client(newTestClient(t), testKey, "")
+
+	resp, err := c.CreateStreamSession(ctx, "audio/x-raw; rate=22050; format=s16le; channels=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second := make([]byte, 22050*2)
+	if _, err := c.UploadChunk(ctx, resp.SessionID, 0, second); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.UploadChunk(ctx, resp.SessionID, 1, second); !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("got error %v, want the second chunk refused", err)
+	}
+	if err := c.DeleteSession(ctx, resp.SessionID); err != nil {
+		t.Fatal(err)
+	}
+
+	r := c.Report()
+	if want := (Counter{Sessions: 1, Seconds: 1, Bytes: int64(len(second))}); r.Today != want {
+		t.Errorf("got %+v, want %+v", r.Today, want)
+	}
+	if _, err := c.CreateStreamSession(ctx, "audio/x-raw; rate=22050; channels=1"); err != nil {
+		t.Errorf("expected a new stream while below the limit, got %v", err)
+	}
+}
+
+func TestMeterPersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cochl", "usage.json")
+	m, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Client(newTestClient(t), testKey, "alice").CreateSession(context.Background(), "a.wav", "audio/wav", 3, 300); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := reopened.Report(testKey)
+	if r.Total != (Counter{Sessions: 1, Seconds: 3, Bytes: 300}) || len(r.Clients) != 1 {
+		t.Errorf("got %+v after reopening", r)
+	}
+	if other := reopened.Report("another-project-key"); other.Total != (Counter{}) {
+		t.Errorf("got %+v for another key, want nothing", other.Total)
+	}
+	if len(reopened.accounts) != 1 {
+		t.Errorf("got %d accounts, want reports to leave the counters alone", len(reopened.accounts))
+	}
+}
+
+func TestMeterReleasesFailedUpload(t *testing.T) {
+	m, err := Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake := fakesense.NewServer()
+	defer fake.Close()
+	c := m.Client(client.NewCochlSense(testKey, fake.URL, "test"), testKey, "")
+	ctx := context.Background()
+
+	resp, err := c.CreateSession(ctx, "a.wav", "audio/wav", 3, 300)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake.Script(fakesense.EndpointUploadChunk, fakesense.Response{Status: http.StatusInternalServerError, Body: "boom"})
+	if _, err := c.UploadChunk(ctx, resp.SessionID, resp.ChunkSequence, make([]byte, 300)); err == nil {
+		t.Fatal("expected the scripted error")
+	}
+	if r := c.Report(); r.Total != (Counter{}) {
+		t.Errorf("got %+v after the upload failed, want nothing", r.Total)
+	}
+
+	resp, err = c.CreateSession(ctx, "b.wav", "audio/wav", 2, 200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.UploadChunk(ctx, resp.SessionID, resp.ChunkSequence, make([]byte, 200)); err != nil {
+		t.Fatal(err)
+	}
+	if r := c.Report(); r.Total != (Counter{Sessions: 1, Seconds: 2, Bytes: 200}) {
+		t.Errorf("got %+v, want the uploaded file counted", r.Total)
+	}
+}
@@ -1,18 +1,42 @@
 package audio
 
 import (
+	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
 )
 
+// SupportedFormats lists the file extensions GetAudioInfo understands.
+var SupportedFormats = []string{"mp3", "ogg", "wav"}
+
+// ErrUnsupportedFormat is returned by GetAudioInfo for files whose extension
+// is not one of SupportedFormats.
+var ErrUnsupportedFormat = errors.New("unsupported audio format")
+
+// ErrCorrupt is wrapped by the errors of GetAudioInfo for files that are
+// truncated or whose headers are invalid.
+var ErrCorrupt = errors.New("corrupt or truncated audio file")
+
 type AudioInfo struct {
 	Duration float64
 	Size     int
 	Format   string
 	FileName string
+	// SampleRate and Channels are zero if the format does not tell.
+	SampleRate int
+	Channels   int
+}
+
+// streamInfo is what the header of an audio file says about its audio.
+type streamInfo struct {
+	duration   float64
+	sampleRate int
+	channels   int
 }
 
 func GetRawAudioData(filePath string) ([]byte, error) {
@@ -22,7 +46,7 @@ func GetRawAudioData(filePath string) ([]byte, error) {
 func GetAudioInfo(filePath string) (*AudioInfo, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open file: %v", err)
+		return nil, fmt.Errorf("failed to open file: %w", err)
 	}
 	defer file.Close()
 
@@ -39,60 +63,95 @@ func GetAudioInfo(filePath string) (*AudioInfo, error) {
 		format = format[1:] // Remove the dot
 	}
 
-	var duration float64
+	var info *streamInfo
 
 	// Process based on file format
 	switch format {
 	case "wav":
-		duration, err = getWAVDuration(file)
+		info, err = getWAVInfo(file, fileInfo.Size())
 		if err != nil {
-			return nil, fmt.Errorf("failed to get WAV duration: %v", err)
+			return nil, fmt.Errorf("failed to read WAV header: %w", err)
 		}
 	case "mp3":
-		duration, err = getMP3Duration(file)
+		info, err = getMP3Info(file)
 		if err != nil {
-			return nil, fmt.Errorf("failed to get MP3 duration: %v", err)
+			return nil, fmt.Errorf("failed to get MP3 duration: %w", err)
 		}
 	case "ogg":
-		duration, err = getOggDuration(file)
+		info, err = getOggInfo(file)
 		if err != nil {
-			return nil, fmt.Errorf("failed to get OGG duration: %v", err)
+			return nil, fmt.Errorf("failed to get OGG duration: %w", err)
 		}
 	default:
-		return nil, fmt.Errorf("unsupported audio format: %s", format)
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedFormat, format)
 	}
 
 	return &AudioInfo{
-		Duration: duration,
-		Size:     size,
-		Format:   format,
-		FileName: filepath.Base(filePath),
+		Duration:   info.duration,
+		Size:       size,
+		Format:     format,
+		FileName:   filepath.Base(filePath),
+		SampleRate: info.sampleRate,
+		Channels:   info.channels,
 	}, nil
 }
 
-func getWAVDuration(file *os.File) (float64, error) {
-	// Read WAV header
-	header := make([]byte, 44)
-	if _, err := file.Read(header); err != nil {
-		return 0, fmt.Errorf("failed to read WAV header: %v", err)
+func getWAVInfo(file *os.File, size int64) (*streamInfo, error) {
+	header := make([]byte, 12)
+	if _, err := file.ReadAt(header, 0); err != nil {
+		return nil, fmt.Errorf("%w: the file is too short for a WAV header", ErrCorrupt)
 	}
-
-	// Check WAV signature
 	if string(header[0:4]) != "RIFF" || string(header[8:12]) != "WAVE" {
-		return 0, fmt.Errorf("invalid WAV file")
+		return nil, fmt.Errorf("%w: missing RIFF/WAVE signature", ErrCorrupt)
 	}
 
-	// Get audio format data
-	chunkSize := binary.LittleEndian.Uint32(header[4:8])
-	sampleRate := binary.LittleEndian.Uint32(header[24:28])
-	bitsPerSample := binary.LittleEndian.Uint16(header[34:36])
-	numChannels := binary.LittleEndian.Uint16(header[22:24])
+	var (
+		info       *streamInfo
+		blockAlign uint16
+		chunk      = make([]byte, 8)
+	)
+	for offset := int64(12); ; {
+		if _, err := file.ReadAt(chunk, offset); err != nil {
+			return nil, fmt.Errorf("%w: no data chunk", ErrCorrupt)
+		}
+		id := string(chunk[0:4])
+		chunkSize := int64(binary.LittleEndian.Uint32(chunk[4:8]))
+		body := offset + 8
+
+		switch id {
+		case "fmt ":
+			fmtChunk := make([]byte, 16)
+			if chunkSize < 16 {
+				return nil, fmt.Errorf("%w: fmt chunk of %d bytes is too short", ErrCorrupt, chunkSize)
+			}
+			if _, err := file.ReadAt(fmtChunk, body); err != nil {
+				return nil, fmt.Errorf("%w: fmt chunk is cut off", ErrCorrupt)
+			}
+			info = &streamInfo{
+				channels:   int(binary.LittleEndian.Uint16(fmtChunk[2:4])),
+				sampleRate: int(binary.LittleEndian.Uint32(fmtChunk[4:8])),
+			}
+			blockAlign = binary.LittleEndian.Uint16(fmtChunk[12:14])
+			if info.channels == 0 || info.sampleRate == 0 || blockAlign == 0 {
+				return nil, fmt.Errorf("%w: fmt chunk declares %d channels at %d Hz with %d-byte frames",
+					ErrCorrupt, info.channels, info.sampleRate, blockAlign)
+			}
 
-	// Calculate duration
-	bytesPerSample := bitsPerSample / 8
-	duration := float64(chunkSize) / float64(sampleRate*uint32(numChannels)*uint32(bytesPerSample))
+		case "data":
+			if info == nil {
+				return nil, fmt.Errorf("%w: data chunk before the fmt chunk", ErrCorrupt)
+			}
+			if available := size - body; chunkSize > available {
+				return nil, fmt.Errorf("%w: the data chunk declares %d bytes of audio but only %d follow",
+					ErrCorrupt, chunkSize, available)
+			}
+			info.duration = float64(chunkSize) / float64(info.sampleRate*int(blockAlign))
+			return info, nil
+		}
 
-	return duration, nil
+		// Chunks are padded to an even size.
+		offset = body + chunkSize + chunkSize%2
+	}
 }
 
 var mp3BitRates = map[int]int{
@@ -118,21 +177,21 @@ var mp3SampleRates = map[int]int{
 	2: 32000,
 }
 
-func getMP3Duration(file *os.File) (float64, error) {
+func getMP3Info(file *os.File) (*streamInfo, error) {
 	fileInfo, err := file.Stat()
 	if err != nil {
-		return 0, fmt.Errorf("failed to get file info: %v", err)
+		return nil, fmt.Errorf("failed to get file info: %v", err)
 	}
 
 	size := fileInfo.Size()
 	if size <= 128 {
-		return 0, fmt.Errorf("file too small to be a valid MP3")
+		return nil, fmt.Errorf("%w: file too small to be a valid MP3", ErrCorrupt)
 	}
 
 	header := make([]byte, 10)
 	_, err = file.ReadAt(header, 0)
 	if err != nil {
-		return 0, fmt.Errorf("failed to read header: %v", err)
+		return nil, fmt.Errorf("failed to read header: %v", err)
 	}
 
 	var offset int64 = 0
@@ -145,7 +204,7 @@ func getMP3Duration(file *os.File) (float64, error) {
 	}
 
 	frameHeader := make([]byte, 4)
-	var bitRate, sampleRate, frameSize int
+	var bitRate, sampleRate, frameSize, channels int
 	var totalFrames int64
 	var isVBR bool
 	var prevBitRate int
@@ -153,7 +212,7 @@ func getMP3Duration(file *os.File) (float64, error) {
 	for offset < size-4 {
 		_, err = file.ReadAt(frameHeader, offset)
 		if err != nil {
-			return 0, fmt.Errorf("failed to read frame header: %v", err)
+			return nil, fmt.Errorf("failed to read frame header: %v", err)
 		}
 
 		if frameHeader[0] == 0xff && (frameHeader[1]&0xe0) == 0xe0 {
@@ -166,6 +225,11 @@ func getMP3Duration(file *os.File) (float64, error) {
 			if version == 3 && layer == 1 {
 				bitRate = mp3BitRates[bitrateIndex] * 1000
 				sampleRate = mp3SampleRates[samplerateIndex]
+				// Channel mode 3 is mono, the others are stereo.
+				channels = 2
+				if frameHeader[3]>>6 == 3 {
+					channels = 1
+				}
 
 				if prevBitRate != 0 && prevBitRate != bitRate {
 					isVBR = true
@@ -184,59 +248,82 @@ func getMP3Duration(file *os.File) (float64, error) {
 	}
 
 	if totalFrames == 0 {
-		return 0, fmt.Errorf("no valid MP3 frames found")
+		return nil, fmt.Errorf("%w: no valid MP3 frames found", ErrCorrupt)
 	}
 
+	info := &streamInfo{sampleRate: sampleRate, channels: channels}
 	if isVBR {
-		duration := float64(size) / (float64(bitRate) / 8.0)
-		return duration, nil
+		info.duration = float64(size) / (float64(bitRate) / 8.0)
+		return info, nil
 	}
 
 	samplesPerFrame := 1152.0 // MPEG1 Layer3
-	duration := float64(totalFrames) * samplesPerFrame / float64(sampleRate)
-	return duration, nil
+	info.duration = float64(totalFrames) * samplesPerFrame / float64(sampleRate)
+	return info, nil
 }
 
-func getOggDuration(file *os.File) (float64, error) {
-	// Get file size
+func getOggInfo(file *os.File) (*streamInfo, error) {
 	fileInfo, err := file.Stat()
 	if err != nil {
-		return 0, fmt.Errorf("error getting file info: %w", err)
+		return nil, fmt.Errorf("error getting file info: %w", err)
 	}
+	size := fileInfo.Size()
 
-	// Read the entire file
-	data := make([]byte, fileInfo.Size())
-	_, err = file.Read(data)
+	// The identification header is the first packet of the stream, so the
+	// sample rate and channels are near the start of the file.
+	head, err := readAt(file, 0, min(size, oggHeadSize))
 	if err != nil {
-		return 0, fmt.Errorf("error reading Ogg file: %w", err)
+		return nil, fmt.Errorf("error reading Ogg file: %w", err)
+	}
+	var rate int64
+	var channels int
+	for i := 0; i < len(head)-14 && rate == 0; i++ {
+		if bytes.Equal(head[i:i+6], []byte("vorbis")) {
+			rate = int64(binary.LittleEndian.Uint32(head[i+11 : i+15]))
+			channels = int(head[i+10])
+		}
 	}
 
-	// Reset file position for future reads
-	_, err = file.Seek(0, 0)
+	// The granule position of the last page holds the length in samples.
+	// A page is at most oggMaxPageSize bytes, so the last one starts within
+	// that distance from the end.
+	tailStart := max(0, size-oggMaxPageSize)
+	tail, err := readAt(file, tailStart, size-tailStart)
 	if err != nil {
-		return 0, fmt.Errorf("error resetting file position: %w", err)
+		return nil, fmt.Errorf("error reading Ogg file: %w", err)
 	}
-
-	// Search for the "OggS" signature and calculate the length
 	var length int64
-	for i := len(data) - 14; i >= 0 && length == 0; i-- {
-		if data[i] == 'O' && data[i+1] == 'g' && data[i+2] == 'g' && data[i+3] == 'S' {
-			length = int64(binary.LittleEndian.Uint64(data[i+6 : i+14]))
-		}
-	}
-
-	// Search for the "vorbis" signature and calculate the rate
-	var rate int64
-	for i := 0; i < len(data)-14 && rate == 0; i++ {
-		if data[i] == 'v' && data[i+1] == 'o' && data[i+2] == 'r' && data[i+3] == 'b' && data[i+4] == 'i' && data[i+5] == 's' {
-			rate = int64(binary.LittleEndian.Uint32(data[i+11 : i+15]))
+	for i := len(tail) - 14; i >= 0 && length == 0; i-- {
+		if bytes.Equal(tail[i:i+4], []byte("OggS")) {
+			length = int64(binary.LittleEndian.Uint64(tail[i+6 : i+14]))
 		}
 	}
 
 	if length == 0 || rate == 0 {
-		return 0, fmt.Errorf("could not find necessary information in Ogg file")
+		return nil, fmt.Errorf("%w: could not find necessary information in Ogg file", ErrCorrupt)
 	}
 
-	duration := float64(length) / float64(rate)
-	return duration, nil
+	return &streamInfo{
+		duration:   float64(length) / float64(rate),
+		sampleRate: int(rate),
+		channels:   channels,
+	}, nil
+}
+
+const (
+	// oggHeadSize is how much of the start of an Ogg file is searched for
+	// the identification header.
+	oggHeadSize = 64 << 10
+	// oggMaxPageSize is the largest possible Ogg page: a 27-byte header,
+	// 255 lacing values and 255 segments of 255 bytes.
+	oggMaxPageSize = 27 + 255 + 255*255
+)
+
+// readAt reads n bytes of file starting at off.
+func readAt(file *os.File, off, n int64) ([]byte, error) {
+	data := make([]byte, n)
+	if _, err := file.ReadAt(data, off); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return data, nil
 }
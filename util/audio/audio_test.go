@@ -1,8 +1,11 @@
 package audio
 
 import (
+	"encoding/binary"
+	"errors"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -94,3 +97,80 @@ func TestGetAudioInfo(t *testing.T) {
 	// Cleanup unsupported format test file
 	os.Remove("testdata/test.xyz")
 }
+
+// wavFile returns a 16-bit PCM WAV file whose data chunk declares
+// declaredSize bytes and holds dataSize.
+func wavFile(sampleRate, channels, declaredSize, dataSize int) []byte {
+	blockAlign := channels * 2
+	b := []byte("RIFF")
+	b = binary.LittleEndian.AppendUint32(b, uint32(36+declaredSize))
+	b = append(b, "WAVEfmt "...)
+	b = binary.LittleEndian.AppendUint32(b, 16)
+	b = binary.LittleEndian.AppendUint16(b, 1)
+	b = binary.LittleEndian.AppendUint16(b, uint16(channels))
+	b = binary.LittleEndian.AppendUint32(b, uint32(sampleRate))
+	b = binary.LittleEndian.AppendUint32(b, uint32(sampleRate*blockAlign))
+	b = binary.LittleEndian.AppendUint16(b, uint16(blockAlign))
+	b = binary.LittleEndian.AppendUint16(b, 16)
+	b = append(b, "data"...)
+	b = binary.LittleEndian.AppendUint32(b, uint32(declaredSize))
+	return append(b, make([]byte, dataSize)...)
+}
+
+func TestGetAudioInfoWAV(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		wantInfo AudioInfo
+		wantErr  string
+	}{
+		{
+			name:     "Stereo",
+			data:     wavFile(16000, 2, 64000, 64000),
+			wantInfo: AudioInfo{Duration: 1, SampleRate: 16000, Channels: 2},
+		},
+		{
+			name:    "Truncated data chunk",
+			data:    wavFile(16000, 1, 64000, 1000),
+			wantErr: "the data chunk declares 64000 bytes of audio but only 1000 follow",
+		},
+		{
+			name:    "Missing data chunk",
+			data:    wavFile(16000, 1, 0, 0)[:36],
+			wantErr: "no data chunk",
+		},
+		{
+			name:    "Invalid fmt chunk",
+			data:    wavFile(0, 1, 100, 100),
+			wantErr: "fmt chunk declares 1 channels at 0 Hz",
+		},
+		{
+			name:    "Header only",
+			data:    []byte("RIFF"),
+			wantErr: "too short for a WAV header",
+		},
+	}
+
+	dir := t.TempDir()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, "test.wav")
+			if err := os.WriteFile(path, tt.data, 0644); err != nil {
+				t.Fatal(err)
+			}
+			info, err := GetAudioInfo(path)
+			if tt.wantErr != "" {
+				if !errors.Is(err, ErrCorrupt) || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("got error %v, want ErrCorrupt with %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if info.Duration != tt.wantInfo.Duration || info.SampleRate != tt.wantInfo.SampleRate || info.Channels != tt.wantInfo.Channels {
+				t.Errorf("got %+v, want %+v", info, tt.wantInfo)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package audio
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Limits are the properties an audio file must have to be sent to Cochl
+// Sense. Zero values and empty lists mean no limit.
+type Limits struct {
+	MaxSize int64
+	// MinDuration and MaxDuration are in seconds.
+	MinDuration float64
+	MaxDuration float64
+	SampleRates []int
+	Channels    []int
+}
+
+// DefaultMaxSize is the largest file uploaded unless configured otherwise,
+// as files are uploaded in a single request.
+const DefaultMaxSize = 100 << 20
+
+// DefaultLimits returns the limits applied unless configured otherwise.
+func DefaultLimits() Limits {
+	return Limits{MaxSize: DefaultMaxSize}
+}
+
+// LimitError reports an audio file that Limits rejects.
+type LimitError struct {
+	// Problem says what is wrong with the file, Fix what to do about it.
+	Problem string
+	Fix     string
+}
+
+func (e *LimitError) Error() string {
+	return e.Problem + ". " + e.Fix
+}
+
+// CheckSize returns a *LimitError if a file of size bytes is too large, so
+// callers can reject it before reading it.
+func (l Limits) CheckSize(size int64) error {
+	if l.MaxSize > 0 && size > l.MaxSize {
+		return &LimitError{
+			Problem: fmt.Sprintf("the file is %s, larger than the limit of %s", formatSize(size), formatSize(l.MaxSize)),
+			Fix:     "Split it into shorter recordings or compress it to mp3 or ogg",
+		}
+	}
+	return nil
+}
+
+// Check returns a *LimitError if the file described by info breaks a limit.
+// A file without audio is always rejected.
+func (l Limits) Check(info *AudioInfo) error {
+	if info.Duration <= 0 {
+		return &LimitError{
+			Problem: "the file holds no audio",
+			Fix:     "Check that the recording is not empty",
+		}
+	}
+	if err := l.CheckSize(int64(info.Size)); err != nil {
+		return err
+	}
+
+	switch {
+	case l.MinDuration > 0 && info.Duration < l.MinDuration:
+		return &LimitError{
+			Problem: fmt.Sprintf("the recording is %.2f s long, shorter than the minimum of %g s", info.Duration, l.MinDuration),
+			Fix:     "Provide a longer recording",
+		}
+
+	case l.MaxDuration > 0 && info.Duration > l.MaxDuration:
+		return &LimitError{
+			Problem: fmt.Sprintf("the recording is %.2f s long, longer than the maximum of %g s", info.Duration, l.MaxDuration),
+			Fix:     fmt.Sprintf("Split it into recordings of at most %g s", l.MaxDuration),
+		}
+	}
+	return l.CheckFormat(info.SampleRate, info.Channels)
+}
+
+// CheckFormat returns a *LimitError if audio with the given sample rate and
+// channels is not supported. Zero values are unknown and not checked.
+func (l Limits) CheckFormat(sampleRate, channels int) error {
+	switch {
+	case len(l.SampleRates) > 0 && sampleRate > 0 && !slices.Contains(l.SampleRates, sampleRate):
+		return &LimitError{
+			Problem: fmt.Sprintf("the sample rate of %d Hz is not supported", sampleRate),
+			Fix: fmt.Sprintf("Resample it to %s Hz, for example with ffmpeg -i input -ar %d output",
+				joinInts(l.SampleRates), l.SampleRates[0]),
+		}
+
+	case len(l.Channels) > 0 && channels > 0 && !slices.Contains(l.Channels, channels):
+		return &LimitError{
+			Problem: fmt.Sprintf("recordings with %d channels are not supported", channels),
+			Fix: fmt.Sprintf("Convert it to %s channels, for example with ffmpeg -i input -ac %d output",
+				joinInts(l.Channels), l.Channels[0]),
+		}
+	}
+	return nil
+}
+
+// ParseInts parses a comma-separated list of positive integers, such as
+// "16000,22050,44100". An empty string is an empty list.
+func ParseInts(s string) ([]int, error) {
+	var ints []int
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		n, err := strconv.Atoi(field)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid number %q, expected a positive integer", field)
+		}
+		ints = append(ints, n)
+	}
+	return ints, nil
+}
+
+func joinInts(ints []int) string {
+	s := make([]string, len(ints))
+	for i, n := range ints {
+		s[i] = strconv.Itoa(n)
+	}
+	if len(s) == 1 {
+		return s[0]
+	}
+	return "one of " + strings.Join(s, ", ")
+}
+
+func formatSize(bytes int64) string {
+	switch {
+	case bytes >= 1<<30:
+		return fmt.Sprintf("%.1f GB", float64(bytes)/(1<<30))
+	case bytes >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(bytes)/(1<<20))
+	case bytes >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(bytes)/(1<<10))
+	default:
+		return fmt.Sprintf("%d bytes", bytes)
+	}
+}
@@ -0,0 +1,72 @@
+package audio
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestLimitsCheck(t *testing.T) {
+	limits := Limits{
+		MaxSize:     1 << 20,
+		MinDuration: 1,
+		MaxDuration: 60,
+		SampleRates: []int{16000, 22050},
+		Channels:    []int{1},
+	}
+	ok := AudioInfo{Duration: 10, Size: 1000, SampleRate: 22050, Channels: 1}
+
+	tests := []struct {
+		name   string
+		modify func(info *AudioInfo)
+		// limits replaces the limits above if set.
+		limits  *Limits
+		wantErr string
+	}{
+		{name: "Within limits", modify: func(*AudioInfo) {}},
+		{name: "Empty", modify: func(i *AudioInfo) { i.Duration = 0 }, wantErr: "holds no audio"},
+		{name: "Empty without limits", modify: func(i *AudioInfo) { i.Duration = 0 }, limits: &Limits{}, wantErr: "holds no audio"},
+		{name: "Too large", modify: func(i *AudioInfo) { i.Size = 4 << 30 }, wantErr: "the file is 4.0 GB, larger than the limit of 1.0 MB"},
+		{name: "Too short", modify: func(i *AudioInfo) { i.Duration = 0.5 }, wantErr: "shorter than the minimum of 1 s"},
+		{name: "Too long", modify: func(i *AudioInfo) { i.Duration = 90 }, wantErr: "Split it into recordings of at most 60 s"},
+		{name: "Sample rate", modify: func(i *AudioInfo) { i.SampleRate = 8000 }, wantErr: "Resample it to one of 16000, 22050 Hz"},
+		{name: "Unknown sample rate", modify: func(i *AudioInfo) { i.SampleRate = 0 }},
+		{name: "Channels", modify: func(i *AudioInfo) { i.Channels = 2 }, wantErr: "Convert it to 1 channels, for example with ffmpeg -i input -ac 1 output"},
+		{name: "Unlimited", modify: func(i *AudioInfo) { i.Size, i.Channels = 4<<30, 6 }, limits: &Limits{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := ok
+			tt.modify(&info)
+			l := limits
+			if tt.limits != nil {
+				l = *tt.limits
+			}
+			err := l.Check(&info)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			var limitErr *LimitError
+			if !errors.As(err, &limitErr) || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("got error %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseInts(t *testing.T) {
+	got, err := ParseInts(" 16000, 22050,,")
+	if err != nil || len(got) != 2 || got[0] != 16000 || got[1] != 22050 {
+		t.Errorf("got %v, %v", got, err)
+	}
+	if got, err := ParseInts(""); err != nil || got != nil {
+		t.Errorf("got %v, %v for an empty list", got, err)
+	}
+	if _, err := ParseInts("44.1k"); err == nil {
+		t.Error("expected an error for a non-integer")
+	}
+}
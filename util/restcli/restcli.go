@@ -1,6 +1,15 @@
 package restcli
 
-import "resty.dev/v3"
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+	"resty.dev/v3"
+
+	"github.com/cochlearai/cochl-mcp-server/tracing"
+)
 
 type Params struct {
 	Header  map[string]string
@@ -10,29 +19,51 @@ type Params struct {
 	Formdata map[string]string
 }
 
-func Get(cli *resty.Client, url string, params *Params, result ...any) (*resty.Response, error) {
-	req := genReq(cli, params, result)
-	return req.Get(url)
+func Get(ctx context.Context, cli *resty.Client, url string, params *Params, result ...any) (*resty.Response, error) {
+	return do(ctx, cli, http.MethodGet, url, params, result)
 }
 
-func Post(cli *resty.Client, url string, params *Params, result ...any) (*resty.Response, error) {
-	req := genReq(cli, params, result)
-	return req.Post(url)
+func Post(ctx context.Context, cli *resty.Client, url string, params *Params, result ...any) (*resty.Response, error) {
+	return do(ctx, cli, http.MethodPost, url, params, result)
 }
 
-func Put(cli *resty.Client, url string, params *Params, result ...any) (*resty.Response, error) {
-	req := genReq(cli, params, result)
-	return req.Put(url)
+func Put(ctx context.Context, cli *resty.Client, url string, params *Params, result ...any) (*resty.Response, error) {
+	return do(ctx, cli, http.MethodPut, url, params, result)
 }
 
-func Patch(cli *resty.Client, url string, params *Params, result ...any) (*resty.Response, error) {
-	req := genReq(cli, params, result)
-	return req.Patch(url)
+func Patch(ctx context.Context, cli *resty.Client, url string, params *Params, result ...any) (*resty.Response, error) {
+	return do(ctx, cli, http.MethodPatch, url, params, result)
 }
 
-func Delete(cli *resty.Client, url string, params *Params, result ...any) (*resty.Response, error) {
-	req := genReq(cli, params, result)
-	return req.Delete(url)
+func Delete(ctx context.Context, cli *resty.Client, url string, params *Params, result ...any) (*resty.Response, error) {
+	return do(ctx, cli, http.MethodDelete, url, params, result)
+}
+
+// do sends a request in a client span, propagating the trace context of ctx
+// in the request headers.
+func do(ctx context.Context, cli *resty.Client, method, url string, params *Params, result []any) (*resty.Response, error) {
+	ctx, span := tracing.StartClient(ctx, "HTTP "+method,
+		attribute.String("http.request.method", method),
+		attribute.String("url.path", url))
+
+	req := genReq(cli, params, result).SetContext(ctx)
+	tracing.InjectHTTP(ctx, req.Header)
+	res, err := req.Execute(method, url)
+
+	spanErr := err
+	if res != nil && err == nil {
+		if raw := res.Request.RawRequest; raw != nil {
+			span.SetAttributes(attribute.Int64("http.request.body.size", raw.ContentLength))
+		}
+		span.SetAttributes(
+			attribute.Int("http.response.status_code", res.StatusCode()),
+			attribute.Int64("http.response.body.size", res.Size()))
+		if res.StatusCode() >= 400 {
+			spanErr = fmt.Errorf("HTTP status %d", res.StatusCode())
+		}
+	}
+	tracing.EndSpan(span, spanErr)
+	return res, err
 }
 
 func genReq(cli *resty.Client, params *Params, result []any) *resty.Request {
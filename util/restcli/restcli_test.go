@@ -0,0 +1,68 @@
+package restcli
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"resty.dev/v3"
+)
+
+func TestRequestSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider, propagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(provider)
+		otel.SetTextMapPropagator(propagator)
+	})
+
+	var traceparent string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error":"not found"}`))
+	}))
+	defer ts.Close()
+
+	res, err := Get(context.Background(), resty.New().SetBaseURL(ts.URL), "/items/1", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.StatusCode() != http.StatusNotFound {
+		t.Fatalf("unexpected status %d", res.StatusCode())
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("expected one span, got %d", len(spans))
+	}
+	span := spans[0]
+	if span.Name() != "HTTP GET" {
+		t.Errorf("unexpected span name %q", span.Name())
+	}
+	if traceparent == "" || traceparent[3:35] != span.SpanContext().TraceID().String() {
+		t.Errorf("expected traceparent of the span, got %q", traceparent)
+	}
+
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	if attrs["http.response.status_code"].AsInt64() != http.StatusNotFound {
+		t.Errorf("unexpected status attribute %v", attrs["http.response.status_code"])
+	}
+	if attrs["http.response.body.size"].AsInt64() != int64(len(`{"error":"not found"}`)) {
+		t.Errorf("unexpected size attribute %v", attrs["http.response.body.size"])
+	}
+	if span.Status().Description == "" {
+		t.Error("expected error status for 404 response")
+	}
+}
@@ -0,0 +1,80 @@
+//go:build linux
+
+package watch
+
+import (
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// inotifyNotifier reports files written or moved into a directory using
+// inotify.
+type inotifyNotifier struct {
+	f      *os.File
+	events chan string
+	closed chan struct{}
+}
+
+func newNotifier(dir string) (notifier, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+	if _, err := unix.InotifyAddWatch(fd, dir, unix.IN_CREATE|unix.IN_MODIFY|unix.IN_CLOSE_WRITE|unix.IN_MOVED_TO); err != nil {
+		unix.Close(fd)
+		return nil, os.NewSyscallError("inotify_add_watch", err)
+	}
+
+	// A non-blocking descriptor is handled by the runtime poller, so Close
+	// interrupts a pending Read.
+	n := &inotifyNotifier{
+		f:      os.NewFile(uintptr(fd), "inotify"),
+		events: make(chan string, 64),
+		closed: make(chan struct{}),
+	}
+	go n.read()
+	return n, nil
+}
+
+func (n *inotifyNotifier) read() {
+	defer close(n.events)
+
+	buf := make([]byte, 64*(unix.SizeofInotifyEvent+unix.NAME_MAX+1))
+	for {
+		size, err := n.f.Read(buf)
+		if err != nil {
+			return
+		}
+
+		for off := 0; off+unix.SizeofInotifyEvent <= size; {
+			event := (*unix.InotifyEvent)(unsafe.Pointer(&buf[off]))
+			nameBytes := buf[off+unix.SizeofInotifyEvent : off+unix.SizeofInotifyEvent+int(event.Len)]
+			off += unix.SizeofInotifyEvent + int(event.Len)
+
+			// The name is padded with NUL bytes.
+			name := string(nameBytes)
+			for len(name) > 0 && name[len(name)-1] == 0 {
+				name = name[:len(name)-1]
+			}
+			if name == "" {
+				continue
+			}
+			select {
+			case n.events <- name:
+			case <-n.closed:
+				return
+			}
+		}
+	}
+}
+
+func (n *inotifyNotifier) Events() <-chan string {
+	return n.events
+}
+
+func (n *inotifyNotifier) Close() error {
+	close(n.closed)
+	return n.f.Close()
+}
@@ -0,0 +1,80 @@
+package watch
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/cochlearai/cochl-mcp-server/client"
+)
+
+// SidecarSuffix is appended to the path of a recording to name its sidecar
+// result file.
+const SidecarSuffix = ".cochl.json"
+
+// Record is the outcome of analyzing one recording, as written to an Output.
+type Record struct {
+	File       string                   `json:"file"`
+	SHA256     string                   `json:"sha256"`
+	AnalyzedAt time.Time                `json:"analyzed_at"`
+	Results    []client.InferenceResult `json:"results,omitempty"`
+	Error      string                   `json:"error,omitempty"`
+}
+
+// Output receives the record of every recording the watcher is done with.
+type Output interface {
+	Write(r Record) error
+}
+
+type sidecarOutput struct{}
+
+// SidecarOutput writes each record as JSON next to its recording, in a file
+// named after it with SidecarSuffix.
+func SidecarOutput() Output {
+	return sidecarOutput{}
+}
+
+func (sidecarOutput) Write(r Record) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(r.File+SidecarSuffix, append(data, '\n'))
+}
+
+// JSONLOutput appends each record as a line of JSON to a log file.
+type JSONLOutput struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+// OpenJSONLOutput opens, or creates, the log file at path for appending.
+func OpenJSONLOutput(path string) (*JSONLOutput, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open result log: %w", err)
+	}
+	return &JSONLOutput{f: f}, nil
+}
+
+// Write appends r and syncs the log, so the record is on disk before the
+// recording is marked as done.
+func (o *JSONLOutput) Write(r Record) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if _, err := o.f.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	return o.f.Sync()
+}
+
+func (o *JSONLOutput) Close() error {
+	return o.f.Close()
+}
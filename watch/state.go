@@ -0,0 +1,100 @@
+package watch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// fileState is what the state file records about one recording.
+type fileState struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	SHA256  string    `json:"sha256,omitempty"`
+	// Done is set once the recording was analyzed, failed MaxAttempts
+	// times or turned out to duplicate another recording.
+	Done        bool   `json:"done"`
+	Attempts    int    `json:"attempts,omitempty"`
+	Error       string `json:"error,omitempty"`
+	DuplicateOf string `json:"duplicate_of,omitempty"`
+}
+
+// state is the set of recordings seen by a watcher, keyed by file name. It
+// is saved after every change so a restarted watcher skips what is done.
+type state struct {
+	path  string
+	Files map[string]*fileState `json:"files"`
+}
+
+func loadState(path string) (*state, error) {
+	s := &state{path: path, Files: make(map[string]*fileState)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read watch state: %w", err)
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("failed to decode watch state %s: %w", path, err)
+	}
+	if s.Files == nil {
+		s.Files = make(map[string]*fileState)
+	}
+	return s, nil
+}
+
+// save replaces the state file atomically, so a crash leaves either the old
+// or the new state.
+func (s *state) save() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, data)
+}
+
+// done reports whether the recording named name, as described by info, has
+// been dealt with. A recording that changed since is not done.
+func (s *state) done(name string, info fs.FileInfo) bool {
+	f, ok := s.Files[name]
+	return ok && f.Done && f.Size == info.Size() && f.ModTime.Equal(info.ModTime())
+}
+
+// duplicateOf returns the name of another recording with the given content
+// that was analyzed successfully, if any.
+func (s *state) duplicateOf(sha256, name string) string {
+	for other, f := range s.Files {
+		if other != name && f.Done && f.Error == "" && f.DuplicateOf == "" && f.SHA256 == sha256 {
+			return other
+		}
+	}
+	return ""
+}
+
+// writeFileAtomic writes data to a temporary file next to path, syncs it and
+// renames it over path.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
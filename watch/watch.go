@@ -0,0 +1,307 @@
+// Package watch analyzes recordings as they are dropped into a directory. New
+// files are noticed with inotify where available, or by scanning the
+// directory periodically, and analyzed once they stop changing. Each result
+// goes to an Output, and a state file records what is done so a restarted
+// watcher does not analyze the same recording twice.
+package watch
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cochlearai/cochl-mcp-server/client"
+	"github.com/cochlearai/cochl-mcp-server/util/audio"
+)
+
+const (
+	// DefaultStateFile is the name of the state file in the watched
+	// directory unless Config.StateFile is set.
+	DefaultStateFile = ".cochl-watch-state.json"
+
+	DefaultSettleTime   = 2 * time.Second
+	DefaultPollInterval = 5 * time.Second
+	DefaultMaxAttempts  = 3
+)
+
+// Analyzer analyzes the recording at path.
+type Analyzer func(ctx context.Context, path string) ([]client.InferenceResult, error)
+
+// Config configures a Watcher.
+type Config struct {
+	// Dir is the directory to watch. Subdirectories are not watched.
+	Dir     string
+	Analyze Analyzer
+	Output  Output
+	// StateFile records the recordings that are done.
+	StateFile string
+	// SettleTime is how long a file must stay unchanged before it is
+	// considered completely written.
+	SettleTime time.Duration
+	// PollInterval is how often the directory is scanned. With inotify,
+	// scans only retry failed analyses and catch missed events.
+	PollInterval time.Duration
+	// Polling disables inotify.
+	Polling bool
+	// MaxAttempts is how many times an analysis is tried before the
+	// recording is given up on.
+	MaxAttempts int
+}
+
+// notifier reports the names of files that changed in a directory. Events is
+// closed if notifications stop.
+type notifier interface {
+	Events() <-chan string
+	Close() error
+}
+
+// observation is how a file looked when it was first seen in its current
+// size and modification time.
+type observation struct {
+	size    int64
+	modTime time.Time
+	since   time.Time
+}
+
+// Watcher analyzes the recordings in a directory.
+type Watcher struct {
+	cfg     Config
+	state   *state
+	pending map[string]observation
+}
+
+// New returns a watcher for cfg.Dir, loading the state left by a previous
+// one.
+func New(cfg Config) (*Watcher, error) {
+	if cfg.Analyze == nil || cfg.Output == nil {
+		return nil, errors.New("watch: an analyzer and an output are required")
+	}
+	info, err := os.Stat(cfg.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open watched directory: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", cfg.Dir)
+	}
+
+	if cfg.StateFile == "" {
+		cfg.StateFile = filepath.Join(cfg.Dir, DefaultStateFile)
+	}
+	if cfg.SettleTime <= 0 {
+		cfg.SettleTime = DefaultSettleTime
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = DefaultPollInterval
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = DefaultMaxAttempts
+	}
+
+	s, err := loadState(cfg.StateFile)
+	if err != nil {
+		return nil, err
+	}
+	return &Watcher{cfg: cfg, state: s, pending: make(map[string]observation)}, nil
+}
+
+// Run watches the directory until ctx is done. An analysis interrupted by
+// ctx is not recorded, so it runs again after a restart.
+func (w *Watcher) Run(ctx context.Context) error {
+	var events <-chan string
+	if !w.cfg.Polling {
+		n, err := newNotifier(w.cfg.Dir)
+		if err != nil {
+			slog.Warn("File notifications unavailable, scanning the directory instead",
+				"interval", w.cfg.PollInterval, "error", err)
+		} else {
+			defer n.Close()
+			events = n.Events()
+		}
+	}
+	slog.Info("Watching for recordings", "dir", w.cfg.Dir, "inotify", events != nil)
+
+	for {
+		if err := w.scan(ctx); err != nil {
+			return err
+		}
+
+		// Files that are still being written are checked again once they
+		// could have settled.
+		interval := w.cfg.PollInterval
+		if len(w.pending) > 0 {
+			interval = min(interval, w.cfg.SettleTime)
+		}
+		timer := time.NewTimer(interval)
+
+	wait:
+		for {
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return nil
+			case <-timer.C:
+				break wait
+			case name, ok := <-events:
+				if !ok {
+					slog.Warn("File notifications stopped, scanning the directory instead", "interval", w.cfg.PollInterval)
+					events = nil
+					continue
+				}
+				// A file being written produces many events; the first
+				// one brings the next scan forward.
+				if w.candidate(name) && interval > w.cfg.SettleTime {
+					interval = w.cfg.SettleTime
+					timer.Reset(interval)
+				}
+			}
+		}
+	}
+}
+
+// candidate reports whether the file named name may be a recording.
+func (w *Watcher) candidate(name string) bool {
+	if strings.HasPrefix(name, ".") || filepath.Join(w.cfg.Dir, name) == w.cfg.StateFile {
+		return false
+	}
+	ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(name)), ".")
+	return slices.Contains(audio.SupportedFormats, ext)
+}
+
+// scan analyzes the recordings that have settled and tracks those that are
+// still changing.
+func (w *Watcher) scan(ctx context.Context) error {
+	entries, err := os.ReadDir(w.cfg.Dir)
+	if err != nil {
+		return fmt.Errorf("failed to scan watched directory: %w", err)
+	}
+
+	now := time.Now()
+	seen := make(map[string]bool)
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Type().IsRegular() || !w.candidate(name) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		if w.state.done(name, info) {
+			continue
+		}
+		seen[name] = true
+
+		o, ok := w.pending[name]
+		if !ok || o.size != info.Size() || !o.modTime.Equal(info.ModTime()) {
+			w.pending[name] = observation{size: info.Size(), modTime: info.ModTime(), since: now}
+			continue
+		}
+		if now.Sub(o.since) < w.cfg.SettleTime {
+			continue
+		}
+
+		delete(w.pending, name)
+		if err := w.process(ctx, name, info); err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+	}
+
+	for name := range w.pending {
+		if !seen[name] {
+			delete(w.pending, name)
+		}
+	}
+	return nil
+}
+
+// process analyzes a settled recording and records the outcome. It only
+// fails if the state cannot be saved.
+func (w *Watcher) process(ctx context.Context, name string, info fs.FileInfo) error {
+	path := filepath.Join(w.cfg.Dir, name)
+	sum, err := hashFile(path)
+	if err != nil {
+		slog.Warn("Failed to read recording", "file", path, "error", err)
+		return nil
+	}
+
+	f := w.state.Files[name]
+	if f == nil || f.SHA256 != sum {
+		f = &fileState{}
+		w.state.Files[name] = f
+	}
+	f.Size, f.ModTime, f.SHA256 = info.Size(), info.ModTime(), sum
+	if f.Done {
+		// Touched without changing its content.
+		return w.save()
+	}
+
+	if original := w.state.duplicateOf(sum, name); original != "" {
+		slog.Info("Skipping duplicate recording", "file", path, "duplicate_of", original)
+		f.Done, f.DuplicateOf, f.Error = true, original, ""
+		return w.save()
+	}
+
+	slog.Info("Analyzing recording", "file", path)
+	results, err := w.cfg.Analyze(ctx, path)
+	if ctx.Err() != nil {
+		return nil
+	}
+
+	record := Record{File: path, SHA256: sum, AnalyzedAt: time.Now().UTC(), Results: results}
+	f.Attempts++
+	if err != nil {
+		f.Error = err.Error()
+		if f.Attempts < w.cfg.MaxAttempts {
+			slog.Warn("Analysis failed, will retry", "file", path, "attempt", f.Attempts, "error", err)
+			return w.save()
+		}
+		slog.Error("Analysis failed, giving up", "file", path, "attempts", f.Attempts, "error", err)
+		record.Results, record.Error = nil, f.Error
+	} else {
+		f.Error = ""
+	}
+
+	// The record is written before the state, so a crash in between
+	// analyzes the recording again rather than losing its result.
+	if err := w.cfg.Output.Write(record); err != nil {
+		slog.Error("Failed to write result", "file", path, "error", err)
+		return w.save()
+	}
+	f.Done = true
+	slog.Info("Recording done", "file", path, "segments", len(results))
+	return w.save()
+}
+
+func (w *Watcher) save() error {
+	if err := w.state.save(); err != nil {
+		return fmt.Errorf("failed to save watch state: %w", err)
+	}
+	return nil
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
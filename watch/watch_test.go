@@ -0,0 +1,265 @@
+package watch
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cochlearai/cochl-mcp-server/client"
+)
+
+var testResults = []client.InferenceResult{
+	{StartTime: 0, EndTime: 1000, Tags: []client.Tags{{Name: "Dog_bark", Probability: 0.9}}},
+}
+
+// recorder is an Analyzer and Output that records its calls. The analyzer
+// fails for a file as many times as failures says.
+type recorder struct {
+	mu       sync.Mutex
+	analyzed []string
+	sizes    map[string]int64
+	failures map[string]int
+	records  chan Record
+}
+
+func newRecorder() *recorder {
+	return &recorder{sizes: make(map[string]int64), failures: make(map[string]int), records: make(chan Record, 16)}
+}
+
+func (r *recorder) analyze(ctx context.Context, path string) ([]client.InferenceResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	name := filepath.Base(path)
+	r.analyzed = append(r.analyzed, name)
+	if info, err := os.Stat(path); err == nil {
+		r.sizes[name] = info.Size()
+	}
+	if r.failures[name] > 0 {
+		r.failures[name]--
+		return nil, errors.New("analysis failed")
+	}
+	return testResults, nil
+}
+
+func (r *recorder) Write(record Record) error {
+	r.records <- record
+	return nil
+}
+
+func (r *recorder) calls() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.analyzed...)
+}
+
+// nextRecord waits for the next record written by the watcher.
+func (r *recorder) nextRecord(t *testing.T) Record {
+	t.Helper()
+	select {
+	case record := <-r.records:
+		return record
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a result")
+		return Record{}
+	}
+}
+
+// startWatcher runs a watcher of dir until the test ends or stop is called.
+func startWatcher(t *testing.T, dir string, r *recorder, polling bool) (stop func()) {
+	t.Helper()
+
+	w, err := New(Config{
+		Dir:          dir,
+		Analyze:      r.analyze,
+		Output:       r,
+		SettleTime:   50 * time.Millisecond,
+		PollInterval: 20 * time.Millisecond,
+		Polling:      polling,
+	})
+	if err != nil {
+		t.Fatalf("failed to create watcher: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- w.Run(ctx) }()
+
+	var once sync.Once
+	stop = func() {
+		once.Do(func() {
+			cancel()
+			if err := <-done; err != nil {
+				t.Errorf("watcher failed: %v", err)
+			}
+		})
+	}
+	t.Cleanup(stop)
+	return stop
+}
+
+func writeFile(t *testing.T, path string, data string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWatchAnalyzesSettledFiles(t *testing.T) {
+	for _, polling := range []bool{true, false} {
+		name := "inotify"
+		if polling {
+			name = "polling"
+		}
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			r := newRecorder()
+			startWatcher(t, dir, r, polling)
+
+			writeFile(t, filepath.Join(dir, "notes.txt"), "not audio")
+			writeFile(t, filepath.Join(dir, ".partial.wav"), "hidden")
+
+			// The recording is written in parts, faster than it settles.
+			path := filepath.Join(dir, "a.wav")
+			f, err := os.Create(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for range 5 {
+				f.WriteString("audio data")
+				time.Sleep(10 * time.Millisecond)
+			}
+			f.Close()
+
+			record := r.nextRecord(t)
+			if record.File != path || !reflect.DeepEqual(record.Results, testResults) || record.SHA256 == "" {
+				t.Errorf("unexpected record %+v", record)
+			}
+			time.Sleep(100 * time.Millisecond)
+			if calls := r.calls(); !reflect.DeepEqual(calls, []string{"a.wav"}) {
+				t.Errorf("got analyses %v, want only a.wav", calls)
+			}
+			if size := r.sizes["a.wav"]; size != 50 {
+				t.Errorf("analyzed a.wav at %d bytes, want the complete 50", size)
+			}
+		})
+	}
+}
+
+func TestWatchRestartSkipsDoneFiles(t *testing.T) {
+	dir := t.TempDir()
+	r := newRecorder()
+	stop := startWatcher(t, dir, r, true)
+	writeFile(t, filepath.Join(dir, "a.wav"), "first recording")
+	r.nextRecord(t)
+	stop()
+
+	// A restarted watcher only analyzes the new recording. The copy of
+	// an analyzed recording is skipped.
+	writeFile(t, filepath.Join(dir, "b.wav"), "second recording")
+	writeFile(t, filepath.Join(dir, "copy-of-a.wav"), "first recording")
+	startWatcher(t, dir, r, true)
+	if record := r.nextRecord(t); filepath.Base(record.File) != "b.wav" {
+		t.Errorf("got a record for %s, want b.wav", record.File)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if calls := r.calls(); !reflect.DeepEqual(calls, []string{"a.wav", "b.wav"}) {
+		t.Errorf("got analyses %v, want a.wav and b.wav", calls)
+	}
+
+	s, err := loadState(filepath.Join(dir, DefaultStateFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f := s.Files["copy-of-a.wav"]; f == nil || !f.Done || f.DuplicateOf != "a.wav" {
+		t.Errorf("expected the copy to be recorded as a duplicate, got %+v", f)
+	}
+}
+
+func TestWatchRetriesFailedAnalyses(t *testing.T) {
+	dir := t.TempDir()
+	r := newRecorder()
+	r.failures["flaky.wav"] = 2
+	r.failures["broken.wav"] = 10
+	startWatcher(t, dir, r, true)
+
+	writeFile(t, filepath.Join(dir, "flaky.wav"), "flaky")
+	writeFile(t, filepath.Join(dir, "broken.wav"), "broken")
+
+	records := make(map[string]Record)
+	for range 2 {
+		record := r.nextRecord(t)
+		records[filepath.Base(record.File)] = record
+	}
+	if record := records["flaky.wav"]; record.Error != "" || len(record.Results) != 1 {
+		t.Errorf("expected flaky.wav to succeed on the third attempt, got %+v", record)
+	}
+	if record := records["broken.wav"]; record.Error != "analysis failed" || record.Results != nil {
+		t.Errorf("expected broken.wav to be given up on, got %+v", record)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	attempts := make(map[string]int)
+	for _, name := range r.calls() {
+		attempts[name]++
+	}
+	if attempts["flaky.wav"] != 3 || attempts["broken.wav"] != DefaultMaxAttempts {
+		t.Errorf("got attempts %v, want 3 each", attempts)
+	}
+}
+
+func TestOutputs(t *testing.T) {
+	dir := t.TempDir()
+	record := Record{
+		File:       filepath.Join(dir, "a.wav"),
+		SHA256:     "abc",
+		AnalyzedAt: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		Results:    testResults,
+	}
+
+	if err := SidecarOutput().Write(record); err != nil {
+		t.Fatalf("failed to write sidecar: %v", err)
+	}
+	data, err := os.ReadFile(record.File + SidecarSuffix)
+	if err != nil {
+		t.Fatalf("failed to read sidecar: %v", err)
+	}
+	var got Record
+	if err := json.Unmarshal(data, &got); err != nil || !reflect.DeepEqual(got, record) {
+		t.Errorf("got sidecar %s, want %+v", data, record)
+	}
+
+	logPath := filepath.Join(dir, "results.jsonl")
+	out, err := OpenJSONLOutput(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out.Write(record)
+	out.Write(Record{File: "b.wav", Error: "failed"})
+	out.Close()
+
+	f, err := os.Open(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var lines []Record
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var r Record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			t.Fatalf("invalid line %q: %v", scanner.Text(), err)
+		}
+		lines = append(lines, r)
+	}
+	if len(lines) != 2 || !reflect.DeepEqual(lines[0], record) || lines[1].Error != "failed" {
+		t.Errorf("got log %+v", lines)
+	}
+}